@@ -7,23 +7,74 @@ import (
 	"github.com/badele/splitans/internal/types"
 )
 
+// ANSIOptions configures the flattened ANSI export
+type ANSIOptions struct {
+	UseVGAColors bool // True VGA colors (not affected by terminal themes)
+	Inline       bool // Flatten output on a single line
+	ICEColors    bool // Blink selects a bright background (100-107)
+	LegacyMode   bool // Reset + rebuild instead of 22/23/24... off codes
+	TabWidth     int  // Columns between tab stops (8 when 0)
+	Overstrike   bool // "a BS a" is bold, "_ BS a" underlined (nroff/man)
+	// Write unknown, DCS and unsupported OSC sequences verbatim where they occur
+	PreserveUnknown bool
+	// Replace blink by nothing, bold or a bright background (kept when zero)
+	Blink processor.BlinkMode
+	// Start each line with a reset and its full style, for copy-paste
+	LineIndependent bool
+	// Encode bright backgrounds as blink + 40-47 in legacy mode (DOS iCE art)
+	BlinkBrightBg bool
+}
+
+// DefaultANSIOptions returns the options used by ExportFlattenedANSI
+func DefaultANSIOptions() ANSIOptions {
+	return ANSIOptions{
+		LegacyMode: true,
+	}
+}
+
 func ExportFlattenedANSI(width, nblines int, tokens []types.Token, outputEncoding string, useVGAColors bool) (string, error) {
-	return exportFlattenedANSI(width, nblines, tokens, outputEncoding, useVGAColors, false)
+	opts := DefaultANSIOptions()
+	opts.UseVGAColors = useVGAColors
+	return ExportFlattenedANSIWithOptions(width, nblines, tokens, outputEncoding, opts)
+}
+
+// ExportFlattenedANSIICE flattens ANSI output in iCE colors mode, where blink
+// is rendered as a bright background (100-107).
+func ExportFlattenedANSIICE(width, nblines int, tokens []types.Token, outputEncoding string, useVGAColors bool, inline bool) (string, error) {
+	opts := DefaultANSIOptions()
+	opts.UseVGAColors = useVGAColors
+	opts.Inline = inline
+	opts.ICEColors = true
+	return ExportFlattenedANSIWithOptions(width, nblines, tokens, outputEncoding, opts)
 }
 
 // ExportFlattenedANSIInline flattens ANSI output on a single line.
 func ExportFlattenedANSIInline(width, nblines int, tokens []types.Token, outputEncoding string, useVGAColors bool) (string, error) {
-	return exportFlattenedANSI(width, nblines, tokens, outputEncoding, useVGAColors, true)
+	opts := DefaultANSIOptions()
+	opts.UseVGAColors = useVGAColors
+	opts.Inline = true
+	return ExportFlattenedANSIWithOptions(width, nblines, tokens, outputEncoding, opts)
 }
 
-func exportFlattenedANSI(width, nblines int, tokens []types.Token, outputEncoding string, useVGAColors bool, inline bool) (string, error) {
-	vt := processor.NewVirtualTerminal(width, nblines, outputEncoding, useVGAColors)
+// ExportFlattenedANSIWithOptions flattens ANSI output with explicit options.
+// Set LegacyMode to false to turn attributes off with the modern off codes
+// (ESC[22m for bold) rather than a reset followed by the remaining state.
+func ExportFlattenedANSIWithOptions(width, nblines int, tokens []types.Token, outputEncoding string, opts ANSIOptions) (string, error) {
+	vt := processor.NewVirtualTerminal(width, nblines, outputEncoding, opts.UseVGAColors)
+	vt.SetICEColors(opts.ICEColors)
+	vt.SetLegacyMode(opts.LegacyMode)
+	vt.SetBlinkBrightBg(opts.BlinkBrightBg)
+	vt.SetTabWidth(opts.TabWidth)
+	vt.SetOverstrike(opts.Overstrike)
+	vt.SetPreserveUnknown(opts.PreserveUnknown)
+	vt.SetLineIndependent(opts.LineIndependent)
 
 	if err := vt.ApplyTokens(tokens); err != nil {
 		return "", fmt.Errorf("error applying tokens: %w", err)
 	}
+	processor.StripBlink(vt, opts.Blink)
 
-	if inline {
+	if opts.Inline {
 		return vt.ExportFlattenedANSIInline(), nil
 	}
 
@@ -4,6 +4,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/badele/splitans/internal/processor"
 	"github.com/badele/splitans/internal/types"
 )
 
@@ -33,3 +34,193 @@ func TestExportFlattenedANSIInline(t *testing.T) {
 		t.Fatalf("inline output should equal standard output without newlines")
 	}
 }
+
+func TestExportFlattenedANSIICEColors(t *testing.T) {
+	tokens := []types.Token{
+		{Type: types.TokenSGR, Parameters: []string{"5", "44"}},
+		{Type: types.TokenText, Value: "X"},
+	}
+
+	standard, err := ExportFlattenedANSI(1, 1, tokens, "utf8", false)
+	if err != nil {
+		t.Fatalf("unexpected standard export error: %v", err)
+	}
+	if !strings.Contains(standard, "\x1b[5;") || !strings.Contains(standard, ";44m") {
+		t.Fatalf("expected blink and blue background in standard mode, got %q", standard)
+	}
+
+	ice, err := ExportFlattenedANSIICE(1, 1, tokens, "utf8", false, false)
+	if err != nil {
+		t.Fatalf("unexpected iCE export error: %v", err)
+	}
+	if !strings.Contains(ice, ";104m") || strings.Contains(ice, "\x1b[5;") {
+		t.Fatalf("expected bright blue background without blink in iCE mode, got %q", ice)
+	}
+}
+
+func TestExportFlattenedANSIBlinkOption(t *testing.T) {
+	tokens := []types.Token{
+		{Type: types.TokenSGR, Parameters: []string{"5", "44"}},
+		{Type: types.TokenText, Value: "X"},
+	}
+
+	tests := []struct {
+		name string
+		mode processor.BlinkMode
+		want string
+	}{
+		{"Remove", processor.BlinkRemove, "\x1b[37;44mX"},
+		{"ToBold", processor.BlinkToBold, "\x1b[1;37;44mX"},
+		{"ToBrightBg", processor.BlinkToBrightBg, "\x1b[37;104mX"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultANSIOptions()
+			opts.Blink = tt.mode
+			got, err := ExportFlattenedANSIWithOptions(1, 1, tokens, "utf8", opts)
+			if err != nil {
+				t.Fatalf("unexpected export error: %v", err)
+			}
+			if strings.Contains(got, "\x1b[5;") || !strings.HasPrefix(got, tt.want) {
+				t.Fatalf("expected %q without blink, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExportFlattenedANSILineIndependent(t *testing.T) {
+	tokens := []types.Token{
+		{Type: types.TokenSGR, Parameters: []string{"1", "31"}},
+		{Type: types.TokenText, Value: "AB"},
+		{Type: types.TokenSGR, Parameters: []string{"44"}},
+		{Type: types.TokenText, Value: "CD"},
+		{Type: types.TokenSGR, Parameters: []string{"0"}},
+		{Type: types.TokenText, Value: "EF"},
+	}
+
+	opts := DefaultANSIOptions()
+	opts.LineIndependent = true
+	got, err := ExportFlattenedANSIWithOptions(2, 3, tokens, "utf8", opts)
+	if err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+
+	want := []string{
+		"\x1b[0m\x1b[1;31mAB",
+		"\x1b[0m\x1b[1;31;44mCD",
+		"\x1b[0mEF",
+	}
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("expected %d lines, got %q", len(want), got)
+	}
+	for i, line := range lines {
+		if line != want[i] {
+			t.Errorf("line %d: expected %q, got %q", i, want[i], line)
+		}
+	}
+
+	inline := opts
+	inline.Inline = true
+	got, err = ExportFlattenedANSIWithOptions(2, 3, tokens, "utf8", inline)
+	if err != nil {
+		t.Fatalf("unexpected inline export error: %v", err)
+	}
+	if strings.Count(got, "\x1b[0m") != 1 {
+		t.Errorf("expected no line resets in inline mode, got %q", got)
+	}
+}
+
+func TestExportFlattenedANSILegacyAndModernMode(t *testing.T) {
+	tokens := []types.Token{
+		{Type: types.TokenSGR, Parameters: []string{"1", "31"}},
+		{Type: types.TokenText, Value: "A"},
+		{Type: types.TokenSGR, Parameters: []string{"22"}},
+		{Type: types.TokenText, Value: "B"},
+	}
+
+	legacy, err := ExportFlattenedANSI(2, 1, tokens, "utf8", false)
+	if err != nil {
+		t.Fatalf("unexpected legacy export error: %v", err)
+	}
+
+	opts := DefaultANSIOptions()
+	opts.LegacyMode = false
+	modern, err := ExportFlattenedANSIWithOptions(2, 1, tokens, "utf8", opts)
+	if err != nil {
+		t.Fatalf("unexpected modern export error: %v", err)
+	}
+
+	expectedLegacy := "\x1b[1;31;40mA\x1b[0;31;40mB\n\x1b[0m"
+	if legacy != expectedLegacy {
+		t.Fatalf("legacy output = %q, want %q", legacy, expectedLegacy)
+	}
+
+	expectedModern := "\x1b[1;31;40mA\x1b[22mB\n\x1b[0m"
+	if modern != expectedModern {
+		t.Fatalf("modern output = %q, want %q", modern, expectedModern)
+	}
+}
+
+func TestExportFlattenedANSIBlinkBrightBg(t *testing.T) {
+	tokens := []types.Token{
+		{Type: types.TokenSGR, Parameters: []string{"101"}},
+		{Type: types.TokenText, Value: "A"},
+	}
+
+	tests := []struct {
+		name          string
+		legacyMode    bool
+		blinkBrightBg bool
+		expected      string
+	}{
+		{"Default", true, false, "\x1b[37;101mA\n\x1b[0m"},
+		{"Blink bright background", true, true, "\x1b[37;5;41mA\n\x1b[0m"},
+		{"Modern ignores it", false, true, "\x1b[37;101mA\n\x1b[0m"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultANSIOptions()
+			opts.LegacyMode = tt.legacyMode
+			opts.BlinkBrightBg = tt.blinkBrightBg
+			got, err := ExportFlattenedANSIWithOptions(1, 1, tokens, "utf8", opts)
+			if err != nil {
+				t.Fatalf("unexpected export error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("output = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExportFlattenedANSIPreserveUnknown(t *testing.T) {
+	tokens := []types.Token{
+		{Type: types.TokenText, Value: "AB"},
+		{Type: types.TokenUnknown, Raw: "\x1b[5;1z"},
+		{Type: types.TokenText, Value: "CD"},
+		{Type: types.TokenDCS, Raw: "\x1bPq#0\x1b\\"},
+	}
+
+	dropped, err := ExportFlattenedANSI(4, 1, tokens, "utf8", false)
+	if err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+	if expected := "\x1b[37;40mABCD\n"; dropped != expected {
+		t.Fatalf("output = %q, want %q", dropped, expected)
+	}
+
+	opts := DefaultANSIOptions()
+	opts.PreserveUnknown = true
+	kept, err := ExportFlattenedANSIWithOptions(4, 1, tokens, "utf8", opts)
+	if err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+
+	// The DCS arrives with a wrap pending on the last column, it ends the line
+	if expected := "\x1b[37;40mAB\x1b[5;1zCD\x1bPq#0\x1b\\\n"; kept != expected {
+		t.Fatalf("output = %q, want %q", kept, expected)
+	}
+}
@@ -0,0 +1,126 @@
+package exporter
+
+import (
+	"strings"
+
+	"github.com/badele/splitans/internal/processor"
+	"github.com/badele/splitans/internal/types"
+)
+
+// sgrToBBCodeTags returns the BBCode tag names with their optional value,
+// ordered from outermost to innermost
+func sgrToBBCodeTags(sgr *types.SGR) [][2]string {
+	fg, bg := resolveSGRColors(sgr)
+
+	tags := [][2]string{
+		{"color", rgbToHex(fg)},
+		{"bgcolor", rgbToHex(bg)},
+	}
+
+	if sgr.Bold {
+		tags = append(tags, [2]string{"b", ""})
+	}
+	if sgr.Italic {
+		tags = append(tags, [2]string{"i", ""})
+	}
+	if sgr.Underline || sgr.DoubleUnderline {
+		tags = append(tags, [2]string{"u", ""})
+	}
+	if sgr.Strikethrough {
+		tags = append(tags, [2]string{"s", ""})
+	}
+
+	return tags
+}
+
+func openBBCodeTags(tags [][2]string) string {
+	var builder strings.Builder
+	for _, tag := range tags {
+		builder.WriteString("[" + tag[0])
+		if tag[1] != "" {
+			builder.WriteString("=" + tag[1])
+		}
+		builder.WriteString("]")
+	}
+	return builder.String()
+}
+
+func closeBBCodeTags(tags [][2]string) string {
+	var builder strings.Builder
+	for i := len(tags) - 1; i >= 0; i-- {
+		builder.WriteString("[/" + tags[i][0] + "]")
+	}
+	return builder.String()
+}
+
+// BBCodeOptions configures the BBCode export
+type BBCodeOptions struct {
+	// Tag wrapping the output, without brackets, none when empty. Most
+	// forums (phpBB, vBulletin) show the tags inside [code] verbatim.
+	Wrapper string
+}
+
+// DefaultBBCodeOptions returns options wrapping the output in a
+// [font=monospace] block, which forums render with its colors
+func DefaultBBCodeOptions() BBCodeOptions {
+	return BBCodeOptions{Wrapper: "font=monospace"}
+}
+
+// ExportBBCode exports the processor.VirtualTerminal buffer as forum BBCode
+// with DefaultBBCodeOptions. Each style run opens its tags and closes them in
+// reverse order, so tags are always balanced.
+func ExportBBCode(vt *processor.VirtualTerminal) (string, error) {
+	return ExportBBCodeWithOptions(vt, DefaultBBCodeOptions())
+}
+
+// ExportBBCodeWithOptions is ExportBBCode with explicit options
+func ExportBBCodeWithOptions(vt *processor.VirtualTerminal, opts BBCodeOptions) (string, error) {
+	lines := vt.ExportSplitTextAndSequences()
+
+	var builder strings.Builder
+	if opts.Wrapper != "" {
+		builder.WriteString("[" + opts.Wrapper + "]\n")
+	}
+
+	// SGR state carries over from previous lines
+	currentSGR := types.NewSGR()
+
+	for _, line := range lines {
+		textRunes := []rune(line.Text)
+
+		var openTags [][2]string
+		openKey := ""
+		seqIndex := 0
+		for x, r := range textRunes {
+			for seqIndex < len(line.Sequences) && line.Sequences[seqIndex].Position <= x {
+				currentSGR = line.Sequences[seqIndex].SGR
+				seqIndex++
+			}
+
+			// Coalesce runs rendering the same tags
+			tags := sgrToBBCodeTags(currentSGR)
+			key := openBBCodeTags(tags)
+			if key != openKey {
+				builder.WriteString(closeBBCodeTags(openTags))
+				builder.WriteString(key)
+				openTags = tags
+				openKey = key
+			}
+
+			if r == 0x0 {
+				r = ' '
+			}
+			builder.WriteRune(r)
+		}
+
+		builder.WriteString(closeBBCodeTags(openTags))
+		builder.WriteString("\n")
+	}
+
+	if opts.Wrapper != "" {
+		name, _, _ := strings.Cut(opts.Wrapper, "=")
+		builder.WriteString("[/" + name + "]\n")
+	}
+
+	return builder.String(), nil
+}
@@ -0,0 +1,65 @@
+package exporter
+
+import (
+	"testing"
+
+	"github.com/badele/splitans/internal/processor"
+	"github.com/badele/splitans/internal/types"
+)
+
+func TestExportBBCodeBalancedTags(t *testing.T) {
+	vt := processor.NewVirtualTerminal(4, 1, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenSGR, Parameters: []string{"1", "3", "31"}},
+		{Type: types.TokenText, Value: "AB"},
+		{Type: types.TokenSGR, Parameters: []string{"0", "32", "44"}},
+		{Type: types.TokenText, Value: "CD"},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	got, err := ExportBBCodeWithOptions(vt, BBCodeOptions{Wrapper: "code"})
+	if err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+
+	expected := "[code]\n" +
+		"[color=#ff5555][bgcolor=#000000][b][i]AB[/i][/b][/bgcolor][/color]" +
+		"[color=#00aa00][bgcolor=#0000aa]CD[/bgcolor][/color]\n" +
+		"[/code]\n"
+	if got != expected {
+		t.Fatalf("unexpected BBCode output:\n got: %q\nwant: %q", got, expected)
+	}
+}
+
+func TestExportBBCodeWrapper(t *testing.T) {
+	vt := processor.NewVirtualTerminal(2, 1, "utf8", false)
+	if err := vt.ApplyTokens([]types.Token{{Type: types.TokenText, Value: "AB"}}); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	body := "[color=#aaaaaa][bgcolor=#000000]AB[/bgcolor][/color]\n"
+	tests := []struct {
+		name     string
+		export   func() (string, error)
+		expected string
+	}{
+		{"default", func() (string, error) { return ExportBBCode(vt) }, "[font=monospace]\n" + body + "[/font]\n"},
+		{"none", func() (string, error) { return ExportBBCodeWithOptions(vt, BBCodeOptions{}) }, body},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.export()
+			if err != nil {
+				t.Fatalf("unexpected export error: %v", err)
+			}
+			if got != tt.expected {
+				t.Fatalf("unexpected BBCode output:\n got: %q\nwant: %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,351 @@
+package exporter
+
+import "golang.org/x/text/encoding/charmap"
+
+// Glyphs of the PNG exporter, on an 8x16 cell like the VGA text mode.
+// The built-in font is a placeholder, not the VGA ROM font: block elements,
+// shades and box drawing are computed from their shape, printable ASCII uses
+// a compact 5x7 font doubled vertically, and every other rune (CP437 accented
+// letters, ½, ¥...) is drawn as a hollow box. PNGOptions.Font replaces it with
+// a raw 8x16 CP437 font, for thumbnails that look like the art.
+
+const (
+	glyphWidth  = 8
+	glyphHeight = 16
+	// Size of a raw 8x16 font: 256 glyphs of 16 rows
+	fontSize = 256 * glyphHeight
+)
+
+// glyph is an 8x16 bitmap, bit 7 of each row is the leftmost pixel
+type glyph [glyphHeight]uint8
+
+// asciiGlyphs contains the 5x7 glyphs of ' ' to '~', bit 4 is the leftmost pixel
+var asciiGlyphs = [95][7]uint8{
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}, // ' '
+	{0x04, 0x04, 0x04, 0x04, 0x04, 0x00, 0x04}, // '!'
+	{0x0A, 0x0A, 0x00, 0x00, 0x00, 0x00, 0x00}, // '"'
+	{0x0A, 0x0A, 0x1F, 0x0A, 0x1F, 0x0A, 0x0A}, // '#'
+	{0x04, 0x0F, 0x14, 0x0E, 0x05, 0x1E, 0x04}, // '$'
+	{0x18, 0x19, 0x02, 0x04, 0x08, 0x13, 0x03}, // '%'
+	{0x0C, 0x12, 0x14, 0x08, 0x15, 0x12, 0x0D}, // '&'
+	{0x04, 0x04, 0x08, 0x00, 0x00, 0x00, 0x00}, // '\''
+	{0x02, 0x04, 0x08, 0x08, 0x08, 0x04, 0x02}, // '('
+	{0x08, 0x04, 0x02, 0x02, 0x02, 0x04, 0x08}, // ')'
+	{0x00, 0x04, 0x15, 0x0E, 0x15, 0x04, 0x00}, // '*'
+	{0x00, 0x04, 0x04, 0x1F, 0x04, 0x04, 0x00}, // '+'
+	{0x00, 0x00, 0x00, 0x00, 0x0C, 0x04, 0x08}, // ','
+	{0x00, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x00}, // '-'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x0C, 0x0C}, // '.'
+	{0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00}, // '/'
+	{0x0E, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0E}, // '0'
+	{0x04, 0x0C, 0x04, 0x04, 0x04, 0x04, 0x0E}, // '1'
+	{0x0E, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1F}, // '2'
+	{0x1F, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0E}, // '3'
+	{0x02, 0x06, 0x0A, 0x12, 0x1F, 0x02, 0x02}, // '4'
+	{0x1F, 0x10, 0x1E, 0x01, 0x01, 0x11, 0x0E}, // '5'
+	{0x06, 0x08, 0x10, 0x1E, 0x11, 0x11, 0x0E}, // '6'
+	{0x1F, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08}, // '7'
+	{0x0E, 0x11, 0x11, 0x0E, 0x11, 0x11, 0x0E}, // '8'
+	{0x0E, 0x11, 0x11, 0x0F, 0x01, 0x02, 0x0C}, // '9'
+	{0x00, 0x0C, 0x0C, 0x00, 0x0C, 0x0C, 0x00}, // ':'
+	{0x00, 0x0C, 0x0C, 0x00, 0x0C, 0x04, 0x08}, // ';'
+	{0x02, 0x04, 0x08, 0x10, 0x08, 0x04, 0x02}, // '<'
+	{0x00, 0x00, 0x1F, 0x00, 0x1F, 0x00, 0x00}, // '='
+	{0x08, 0x04, 0x02, 0x01, 0x02, 0x04, 0x08}, // '>'
+	{0x0E, 0x11, 0x01, 0x02, 0x04, 0x00, 0x04}, // '?'
+	{0x0E, 0x11, 0x01, 0x0D, 0x15, 0x15, 0x0E}, // '@'
+	{0x0E, 0x11, 0x11, 0x11, 0x1F, 0x11, 0x11}, // 'A'
+	{0x1E, 0x11, 0x11, 0x1E, 0x11, 0x11, 0x1E}, // 'B'
+	{0x0E, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0E}, // 'C'
+	{0x1C, 0x12, 0x11, 0x11, 0x11, 0x12, 0x1C}, // 'D'
+	{0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x1F}, // 'E'
+	{0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x10}, // 'F'
+	{0x0E, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0F}, // 'G'
+	{0x11, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11}, // 'H'
+	{0x0E, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0E}, // 'I'
+	{0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0C}, // 'J'
+	{0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11}, // 'K'
+	{0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1F}, // 'L'
+	{0x11, 0x1B, 0x15, 0x15, 0x11, 0x11, 0x11}, // 'M'
+	{0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11}, // 'N'
+	{0x0E, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E}, // 'O'
+	{0x1E, 0x11, 0x11, 0x1E, 0x10, 0x10, 0x10}, // 'P'
+	{0x0E, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0D}, // 'Q'
+	{0x1E, 0x11, 0x11, 0x1E, 0x14, 0x12, 0x11}, // 'R'
+	{0x0F, 0x10, 0x10, 0x0E, 0x01, 0x01, 0x1E}, // 'S'
+	{0x1F, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04}, // 'T'
+	{0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E}, // 'U'
+	{0x11, 0x11, 0x11, 0x11, 0x11, 0x0A, 0x04}, // 'V'
+	{0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0A}, // 'W'
+	{0x11, 0x11, 0x0A, 0x04, 0x0A, 0x11, 0x11}, // 'X'
+	{0x11, 0x11, 0x0A, 0x04, 0x04, 0x04, 0x04}, // 'Y'
+	{0x1F, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1F}, // 'Z'
+	{0x0E, 0x08, 0x08, 0x08, 0x08, 0x08, 0x0E}, // '['
+	{0x00, 0x10, 0x08, 0x04, 0x02, 0x01, 0x00}, // '\\'
+	{0x0E, 0x02, 0x02, 0x02, 0x02, 0x02, 0x0E}, // ']'
+	{0x04, 0x0A, 0x11, 0x00, 0x00, 0x00, 0x00}, // '^'
+	{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x1F}, // '_'
+	{0x08, 0x04, 0x02, 0x00, 0x00, 0x00, 0x00}, // '`'
+	{0x00, 0x00, 0x0E, 0x01, 0x0F, 0x11, 0x0F}, // 'a'
+	{0x10, 0x10, 0x16, 0x19, 0x11, 0x11, 0x1E}, // 'b'
+	{0x00, 0x00, 0x0E, 0x10, 0x10, 0x11, 0x0E}, // 'c'
+	{0x01, 0x01, 0x0D, 0x13, 0x11, 0x11, 0x0F}, // 'd'
+	{0x00, 0x00, 0x0E, 0x11, 0x1F, 0x10, 0x0E}, // 'e'
+	{0x06, 0x09, 0x08, 0x1C, 0x08, 0x08, 0x08}, // 'f'
+	{0x00, 0x0F, 0x11, 0x11, 0x0F, 0x01, 0x0E}, // 'g'
+	{0x10, 0x10, 0x16, 0x19, 0x11, 0x11, 0x11}, // 'h'
+	{0x04, 0x00, 0x0C, 0x04, 0x04, 0x04, 0x0E}, // 'i'
+	{0x02, 0x00, 0x06, 0x02, 0x02, 0x12, 0x0C}, // 'j'
+	{0x10, 0x10, 0x12, 0x14, 0x18, 0x14, 0x12}, // 'k'
+	{0x0C, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0E}, // 'l'
+	{0x00, 0x00, 0x1A, 0x15, 0x15, 0x11, 0x11}, // 'm'
+	{0x00, 0x00, 0x16, 0x19, 0x11, 0x11, 0x11}, // 'n'
+	{0x00, 0x00, 0x0E, 0x11, 0x11, 0x11, 0x0E}, // 'o'
+	{0x00, 0x00, 0x1E, 0x11, 0x1E, 0x10, 0x10}, // 'p'
+	{0x00, 0x00, 0x0D, 0x13, 0x0F, 0x01, 0x01}, // 'q'
+	{0x00, 0x00, 0x16, 0x19, 0x10, 0x10, 0x10}, // 'r'
+	{0x00, 0x00, 0x0E, 0x10, 0x0E, 0x01, 0x1E}, // 's'
+	{0x08, 0x08, 0x1C, 0x08, 0x08, 0x09, 0x06}, // 't'
+	{0x00, 0x00, 0x11, 0x11, 0x11, 0x13, 0x0D}, // 'u'
+	{0x00, 0x00, 0x11, 0x11, 0x11, 0x0A, 0x04}, // 'v'
+	{0x00, 0x00, 0x11, 0x11, 0x15, 0x15, 0x0A}, // 'w'
+	{0x00, 0x00, 0x11, 0x0A, 0x04, 0x0A, 0x11}, // 'x'
+	{0x00, 0x00, 0x11, 0x11, 0x0F, 0x01, 0x0E}, // 'y'
+	{0x00, 0x00, 0x1F, 0x02, 0x04, 0x08, 0x1F}, // 'z'
+	{0x02, 0x04, 0x04, 0x08, 0x04, 0x04, 0x02}, // '{'
+	{0x04, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04}, // '|'
+	{0x08, 0x04, 0x04, 0x02, 0x04, 0x04, 0x08}, // '}'
+	{0x00, 0x00, 0x08, 0x15, 0x02, 0x00, 0x00}, // '~'
+
+}
+
+// boxArms lists the box drawing lines leaving the center of the cell, in the
+// order up, down, left, right: 0 none, 1 single, 2 double
+var boxArms = map[rune][4]uint8{
+	'─': {0, 0, 1, 1}, '│': {1, 1, 0, 0},
+	'┌': {0, 1, 0, 1}, '┐': {0, 1, 1, 0}, '└': {1, 0, 0, 1}, '┘': {1, 0, 1, 0},
+	'├': {1, 1, 0, 1}, '┤': {1, 1, 1, 0}, '┬': {0, 1, 1, 1}, '┴': {1, 0, 1, 1},
+	'┼': {1, 1, 1, 1},
+	'═': {0, 0, 2, 2}, '║': {2, 2, 0, 0},
+	'╒': {0, 1, 0, 2}, '╓': {0, 2, 0, 1}, '╔': {0, 2, 0, 2},
+	'╕': {0, 1, 2, 0}, '╖': {0, 2, 1, 0}, '╗': {0, 2, 2, 0},
+	'╘': {1, 0, 0, 2}, '╙': {2, 0, 0, 1}, '╚': {2, 0, 0, 2},
+	'╛': {1, 0, 2, 0}, '╜': {2, 0, 1, 0}, '╝': {2, 0, 2, 0},
+	'╞': {1, 1, 0, 2}, '╟': {2, 2, 0, 1}, '╠': {2, 2, 0, 2},
+	'╡': {1, 1, 2, 0}, '╢': {2, 2, 1, 0}, '╣': {2, 2, 2, 0},
+	'╤': {0, 1, 2, 2}, '╥': {0, 2, 1, 1}, '╦': {0, 2, 2, 2},
+	'╧': {1, 0, 2, 2}, '╨': {2, 0, 1, 1}, '╩': {2, 0, 2, 2},
+	'╪': {1, 1, 2, 2}, '╫': {2, 2, 1, 1}, '╬': {2, 2, 2, 2},
+}
+
+// fontGlyph returns the bitmap of r in a raw 8x16 CP437 font, false when
+// there is no font or r has no CP437 code
+func fontGlyph(font []byte, r rune) (glyph, bool) {
+	var g glyph
+	if len(font) != fontSize {
+		return g, false
+	}
+
+	code, ok := charmap.CodePage437.EncodeRune(r)
+	if !ok {
+		return g, false
+	}
+	copy(g[:], font[int(code)*glyphHeight:])
+
+	return g, true
+}
+
+// glyphFor returns the bitmap of r in the built-in placeholder font
+func glyphFor(r rune) glyph {
+	var g glyph
+
+	switch {
+	case r == 0x0 || r == ' ':
+		return g
+
+	case r > ' ' && r <= '~':
+		for row, bits := range asciiGlyphs[r-' '] {
+			g[1+row*2] = bits << 2
+			g[2+row*2] = bits << 2
+		}
+		return g
+	}
+
+	switch r {
+	case '█':
+		fillRows(&g, 0, glyphHeight-1, 0xFF)
+	case '▀':
+		fillRows(&g, 0, 7, 0xFF)
+	case '▄':
+		fillRows(&g, 8, glyphHeight-1, 0xFF)
+	case '▌':
+		fillRows(&g, 0, glyphHeight-1, 0xF0)
+	case '▐':
+		fillRows(&g, 0, glyphHeight-1, 0x0F)
+	case '░':
+		shade(&g, 0x22, 0x88)
+	case '▒':
+		shade(&g, 0x55, 0xAA)
+	case '▓':
+		shade(&g, 0x77, 0xDD)
+	case '■':
+		fillRows(&g, 4, 10, 0x7E)
+	case '·', '∙':
+		fillRows(&g, 7, 8, 0x18)
+	default:
+		if arms, ok := boxArms[r]; ok {
+			return boxGlyph(arms)
+		}
+
+		// Hollow box for runes without glyph
+		g[2], g[13] = 0x7E, 0x7E
+		fillRows(&g, 3, 12, 0x42)
+	}
+
+	return g
+}
+
+func fillRows(g *glyph, from, to int, bits uint8) {
+	for row := from; row <= to; row++ {
+		g[row] = bits
+	}
+}
+
+func shade(g *glyph, even, odd uint8) {
+	for row := range g {
+		if row%2 == 0 {
+			g[row] = even
+		} else {
+			g[row] = odd
+		}
+	}
+}
+
+// boxGlyph draws box drawing lines. Single lines use row 7 and column 3,
+// double lines rows 6 and 8 and columns 2 and 4.
+func boxGlyph(arms [4]uint8) glyph {
+	var pixels [glyphHeight][glyphWidth]bool
+	up, down, left, right := arms[0], arms[1], arms[2], arms[3]
+
+	hLine := func(row, from, to int, on bool) {
+		for x := from; x <= to; x++ {
+			pixels[row][x] = on
+		}
+	}
+	vLine := func(col, from, to int, on bool) {
+		for y := from; y <= to; y++ {
+			pixels[y][col] = on
+		}
+	}
+
+	// Where a line stops when it meets the perpendicular lines
+	doubleV := up == 2 || down == 2
+	doubleH := left == 2 || right == 2
+	crossV := up == 1 && down == 1
+	crossH := left == 1 && right == 1
+
+	// Double lines are drawn as a 3 pixels band, then hollowed out from the
+	// center to the edge
+	if left == 2 {
+		end := 3
+		if doubleV {
+			end = 4
+		}
+		for row := 6; row <= 8; row++ {
+			hLine(row, 0, end, true)
+		}
+	}
+	if right == 2 {
+		start := 3
+		if doubleV {
+			start = 2
+		}
+		for row := 6; row <= 8; row++ {
+			hLine(row, start, glyphWidth-1, true)
+		}
+	}
+	if up == 2 {
+		end := 7
+		if doubleH {
+			end = 8
+		}
+		for col := 2; col <= 4; col++ {
+			vLine(col, 0, end, true)
+		}
+	}
+	if down == 2 {
+		start := 7
+		if doubleH {
+			start = 6
+		}
+		for col := 2; col <= 4; col++ {
+			vLine(col, start, glyphHeight-1, true)
+		}
+	}
+	if left == 2 {
+		hLine(7, 0, 3, false)
+	}
+	if right == 2 {
+		hLine(7, 3, glyphWidth-1, false)
+	}
+	if up == 2 {
+		vLine(3, 0, 7, false)
+	}
+	if down == 2 {
+		vLine(3, 7, glyphHeight-1, false)
+	}
+
+	// Single lines go to the center, to the near double line when crossing
+	// a double line on both sides, or to the far one at a corner
+	if left == 1 {
+		end := 3
+		switch {
+		case up == 2 && down == 2 && !crossH:
+			end = 2
+		case doubleV && !crossH:
+			end = 4
+		}
+		hLine(7, 0, end, true)
+	}
+	if right == 1 {
+		start := 3
+		switch {
+		case up == 2 && down == 2 && !crossH:
+			start = 4
+		case doubleV && !crossH:
+			start = 2
+		}
+		hLine(7, start, glyphWidth-1, true)
+	}
+	if up == 1 {
+		end := 7
+		switch {
+		case left == 2 && right == 2 && !crossV:
+			end = 6
+		case doubleH && !crossV:
+			end = 8
+		}
+		vLine(3, 0, end, true)
+	}
+	if down == 1 {
+		start := 7
+		switch {
+		case left == 2 && right == 2 && !crossV:
+			start = 8
+		case doubleH && !crossV:
+			start = 6
+		}
+		vLine(3, start, glyphHeight-1, true)
+	}
+
+	var g glyph
+	for y := range pixels {
+		for x := range pixels[y] {
+			if pixels[y][x] {
+				g[y] |= 0x80 >> x
+			}
+		}
+	}
+
+	return g
+}
@@ -0,0 +1,36 @@
+package exporter
+
+import (
+	"github.com/badele/splitans/internal/processor"
+	"github.com/badele/splitans/internal/types"
+)
+
+// HalfBlockRGB reads the used area of the buffer as pixels at twice the
+// vertical resolution: each cell gives its top and bottom colors. '▀' is
+// fg over bg, '▄' is bg over fg, '█' is fg and blanks are bg. Other glyphs
+// don't map to pixels and use bg. Reverse video and bold brightening
+// (standard colors 0-7) are applied, default colors are kept as is.
+func HalfBlockRGB(vt *processor.VirtualTerminal) [][][2]types.ColorValue {
+	cells := vt.Cells()
+
+	pixels := make([][][2]types.ColorValue, len(cells))
+	for y, line := range cells {
+		pixels[y] = make([][2]types.ColorValue, len(line))
+		for x, cell := range line {
+			fg, bg := cell.SGR.DisplayedColors()
+
+			switch cell.Char {
+			case '▀':
+				pixels[y][x] = [2]types.ColorValue{fg, bg}
+			case '▄':
+				pixels[y][x] = [2]types.ColorValue{bg, fg}
+			case '█':
+				pixels[y][x] = [2]types.ColorValue{fg, fg}
+			default:
+				pixels[y][x] = [2]types.ColorValue{bg, bg}
+			}
+		}
+	}
+
+	return pixels
+}
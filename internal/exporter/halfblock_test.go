@@ -0,0 +1,41 @@
+package exporter
+
+import (
+	"testing"
+
+	"github.com/badele/splitans/internal/processor"
+	"github.com/badele/splitans/internal/types"
+)
+
+func TestHalfBlockRGB(t *testing.T) {
+	vt := processor.NewVirtualTerminal(4, 1, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenSGR, Parameters: []string{"31", "44"}},
+		{Type: types.TokenText, Value: "▀▄█ "},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	red := types.ColorValue{Type: types.ColorStandard, Index: 1}
+	blue := types.ColorValue{Type: types.ColorStandard, Index: 4}
+	expected := [][2]types.ColorValue{
+		{red, blue},  // ▀ top=fg, bottom=bg
+		{blue, red},  // ▄
+		{red, red},   // █
+		{blue, blue}, // space
+	}
+
+	pixels := HalfBlockRGB(vt)
+	if len(pixels) != 1 || len(pixels[0]) != len(expected) {
+		t.Fatalf("expected 1x%d cells, got %dx%d", len(expected), len(pixels), len(pixels[0]))
+	}
+
+	for x, want := range expected {
+		if pixels[0][x] != want {
+			t.Errorf("cell %d: expected %v, got %v", x, want, pixels[0][x])
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package exporter
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/badele/splitans/internal/processor"
+	"github.com/badele/splitans/internal/types"
+)
+
+// HTMLOptions configures the HTML export
+type HTMLOptions struct {
+	Title bool // Write the window title (OSC 0/2) in a <title> before the <pre>, when set
+}
+
+// ExportHTML exports the processor.VirtualTerminal buffer as a <pre> block of
+// styled <span> runs, one run per consecutive cells sharing the same SGR.
+func ExportHTML(vt *processor.VirtualTerminal) (string, error) {
+	return ExportHTMLWithOptions(vt, HTMLOptions{})
+}
+
+// ExportHTMLWithOptions is ExportHTML with explicit options
+func ExportHTMLWithOptions(vt *processor.VirtualTerminal, opts HTMLOptions) (string, error) {
+	lines := vt.ExportSplitTextAndSequences()
+
+	var builder strings.Builder
+	if title := vt.GetTitle(); opts.Title && title != "" {
+		builder.WriteString("<title>" + html.EscapeString(title) + "</title>\n")
+	}
+	builder.WriteString(fmt.Sprintf("<pre style=\"background:%s;color:%s\">\n",
+		rgbToHex(types.VGAPalette[0]), rgbToHex(types.VGAPalette[7])))
+
+	// SGR state carries over from previous lines
+	currentSGR := types.NewSGR()
+
+	for _, line := range lines {
+		textRunes := []rune(line.Text)
+
+		seqIndex := 0
+		start := 0
+		for start < len(textRunes) {
+			for seqIndex < len(line.Sequences) && line.Sequences[seqIndex].Position <= start {
+				currentSGR = line.Sequences[seqIndex].SGR
+				seqIndex++
+			}
+
+			end := len(textRunes)
+			if seqIndex < len(line.Sequences) {
+				end = line.Sequences[seqIndex].Position
+			}
+
+			builder.WriteString(fmt.Sprintf("<span style=\"%s\">", sgrToCSS(currentSGR)))
+			builder.WriteString(escapeHTML(textRunes[start:end]))
+			builder.WriteString("</span>")
+
+			start = end
+		}
+
+		builder.WriteString("\n")
+	}
+
+	builder.WriteString("</pre>\n")
+
+	return builder.String(), nil
+}
+
+// sgrToCSS converts an SGR state to an inline CSS style
+func sgrToCSS(sgr *types.SGR) string {
+	fg, bg := resolveSGRColors(sgr)
+
+	styles := []string{
+		"color:" + rgbToHex(fg),
+		"background:" + rgbToHex(bg),
+	}
+
+	if sgr.Bold {
+		styles = append(styles, "font-weight:bold")
+	}
+	if sgr.Dim {
+		styles = append(styles, "opacity:0.5")
+	}
+	if sgr.Italic {
+		styles = append(styles, "font-style:italic")
+	}
+
+	var decorations []string
+	if sgr.Underline || sgr.DoubleUnderline {
+		decorations = append(decorations, "underline")
+	}
+	if sgr.Overline {
+		decorations = append(decorations, "overline")
+	}
+	if sgr.Strikethrough {
+		decorations = append(decorations, "line-through")
+	}
+	if sgr.Blink {
+		decorations = append(decorations, "blink")
+	}
+	if len(decorations) > 0 {
+		styles = append(styles, "text-decoration:"+strings.Join(decorations, " "))
+	}
+	if sgr.DoubleUnderline {
+		styles = append(styles, "text-decoration-style:double")
+	}
+
+	return strings.Join(styles, ";")
+}
+
+// escapeHTML escapes HTML special characters and renders blank cells as &nbsp;
+func escapeHTML(runes []rune) string {
+	var builder strings.Builder
+	for _, r := range runes {
+		switch r {
+		case '<':
+			builder.WriteString("&lt;")
+		case '>':
+			builder.WriteString("&gt;")
+		case '&':
+			builder.WriteString("&amp;")
+		case ' ', 0x0:
+			builder.WriteString("&nbsp;")
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,71 @@
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/badele/splitans/internal/processor"
+	"github.com/badele/splitans/internal/types"
+)
+
+func TestExportHTMLGolden(t *testing.T) {
+	vt := processor.NewVirtualTerminal(6, 2, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenSGR, Parameters: []string{"1", "31"}},
+		{Type: types.TokenText, Value: "A<B"},
+		{Type: types.TokenSGR, Parameters: []string{"0", "44"}},
+		{Type: types.TokenText, Value: " &"},
+		{Type: types.TokenC0, C0Code: 0x0A},
+		{Type: types.TokenSGR, Parameters: []string{"0", "3", "38", "5", "196"}},
+		{Type: types.TokenText, Value: "xy"},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	got, err := ExportHTML(vt)
+	if err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+
+	want, err := os.ReadFile(filepath.Join("testdata", "html_basic.golden"))
+	if err != nil {
+		t.Fatalf("unable to read golden file: %v", err)
+	}
+
+	if got != string(want) {
+		t.Fatalf("unexpected HTML output:\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestExportHTMLTitle(t *testing.T) {
+	vt := processor.NewVirtualTerminal(2, 1, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenOSC, Parameters: []string{"0", "My <Art>"}},
+		{Type: types.TokenText, Value: "Hi"},
+	}
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	got, err := ExportHTMLWithOptions(vt, HTMLOptions{Title: true})
+	if err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+	if !strings.HasPrefix(got, "<title>My &lt;Art&gt;</title>\n<pre ") {
+		t.Fatalf("expected the title before the <pre> block, got %q", got)
+	}
+
+	untitled, err := ExportHTML(vt)
+	if err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+	if strings.Contains(untitled, "<title>") {
+		t.Fatalf("expected no title by default, got %q", untitled)
+	}
+}
@@ -0,0 +1,160 @@
+package exporter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/badele/splitans/internal/processor"
+	"github.com/badele/splitans/internal/types"
+)
+
+// mIRC control codes
+const (
+	ircColor         = "\x03"
+	ircItalic        = "\x1D"
+	ircUnderline     = "\x1F"
+	ircStrikethrough = "\x1E"
+	ircReverse       = "\x16"
+	ircReset         = "\x0F"
+)
+
+// ircPalette contains the 16 standard mIRC colors
+var ircPalette = [16][3]uint8{
+	{0xFF, 0xFF, 0xFF}, // 0: White
+	{0x00, 0x00, 0x00}, // 1: Black
+	{0x00, 0x00, 0x7F}, // 2: Blue (navy)
+	{0x00, 0x93, 0x00}, // 3: Green
+	{0xFF, 0x00, 0x00}, // 4: Red
+	{0x7F, 0x00, 0x00}, // 5: Brown (maroon)
+	{0x9C, 0x00, 0x9C}, // 6: Purple
+	{0xFC, 0x7F, 0x00}, // 7: Orange
+	{0xFF, 0xFF, 0x00}, // 8: Yellow
+	{0x00, 0xFC, 0x00}, // 9: Light Green
+	{0x00, 0x93, 0x93}, // 10: Cyan (teal)
+	{0x00, 0xFF, 0xFF}, // 11: Light Cyan
+	{0x00, 0x00, 0xFC}, // 12: Light Blue
+	{0xFF, 0x00, 0xFF}, // 13: Pink
+	{0x7F, 0x7F, 0x7F}, // 14: Grey
+	{0xD2, 0xD2, 0xD2}, // 15: Light Grey
+}
+
+// ircStyle is the mIRC rendition of a SGR state
+type ircStyle struct {
+	fg, bg        int
+	italic        bool
+	underline     bool
+	strikethrough bool
+	reverse       bool
+}
+
+// nearestIRCColor returns the mIRC color index closest to the given RGB color
+func nearestIRCColor(rgb [3]uint8) int {
+	best := 0
+	bestDistance := -1
+	for i, c := range ircPalette {
+		dr := int(rgb[0]) - int(c[0])
+		dg := int(rgb[1]) - int(c[1])
+		db := int(rgb[2]) - int(c[2])
+		distance := dr*dr + dg*dg + db*db
+		if bestDistance < 0 || distance < bestDistance {
+			best = i
+			bestDistance = distance
+		}
+	}
+	return best
+}
+
+func sgrToIRCStyle(sgr *types.SGR) ircStyle {
+	// Reverse is rendered with the mIRC reverse code, not by swapping colors
+	colors := sgr.Copy()
+	colors.Reverse = false
+	fg, bg := resolveSGRColors(colors)
+
+	return ircStyle{
+		fg:            nearestIRCColor(fg),
+		bg:            nearestIRCColor(bg),
+		italic:        sgr.Italic,
+		underline:     sgr.Underline || sgr.DoubleUnderline,
+		strikethrough: sgr.Strikethrough,
+		reverse:       sgr.Reverse,
+	}
+}
+
+// diffIRCStyle returns the mIRC codes to transition from previous to current style.
+// Formatting toggles can't be turned off individually, so a reset (\x0F) is
+// emitted followed by the full current style when one of them clears.
+func diffIRCStyle(current ircStyle, previous *ircStyle) string {
+	var builder strings.Builder
+
+	if previous != nil {
+		if (previous.italic && !current.italic) || (previous.underline && !current.underline) ||
+			(previous.strikethrough && !current.strikethrough) || (previous.reverse && !current.reverse) {
+			builder.WriteString(ircReset)
+			previous = nil
+		}
+	}
+
+	if previous == nil {
+		previous = &ircStyle{fg: -1, bg: -1}
+	}
+
+	if current.italic && !previous.italic {
+		builder.WriteString(ircItalic)
+	}
+	if current.underline && !previous.underline {
+		builder.WriteString(ircUnderline)
+	}
+	if current.strikethrough && !previous.strikethrough {
+		builder.WriteString(ircStrikethrough)
+	}
+	if current.reverse && !previous.reverse {
+		builder.WriteString(ircReverse)
+	}
+
+	// Always emit both colors on two digits, so a digit in the following
+	// text can't be read as part of the color code
+	if current.fg != previous.fg || current.bg != previous.bg {
+		builder.WriteString(fmt.Sprintf("%s%02d,%02d", ircColor, current.fg, current.bg))
+	}
+
+	return builder.String()
+}
+
+// ExportIRC exports the processor.VirtualTerminal buffer as mIRC color codes.
+// IRC clients reset formatting on each message, so every line restates its style.
+func ExportIRC(vt *processor.VirtualTerminal) (string, error) {
+	lines := vt.ExportSplitTextAndSequences()
+
+	var builder strings.Builder
+
+	// SGR state carries over from previous lines
+	currentSGR := types.NewSGR()
+
+	for _, line := range lines {
+		textRunes := []rune(line.Text)
+
+		var previous *ircStyle
+		seqIndex := 0
+		for x, r := range textRunes {
+			for seqIndex < len(line.Sequences) && line.Sequences[seqIndex].Position <= x {
+				currentSGR = line.Sequences[seqIndex].SGR
+				seqIndex++
+			}
+
+			style := sgrToIRCStyle(currentSGR)
+			if previous == nil || style != *previous {
+				builder.WriteString(diffIRCStyle(style, previous))
+				previous = &style
+			}
+
+			if r == 0x0 {
+				r = ' '
+			}
+			builder.WriteRune(r)
+		}
+
+		builder.WriteString("\n")
+	}
+
+	return builder.String(), nil
+}
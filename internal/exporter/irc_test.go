@@ -0,0 +1,35 @@
+package exporter
+
+import (
+	"testing"
+
+	"github.com/badele/splitans/internal/processor"
+	"github.com/badele/splitans/internal/types"
+)
+
+func TestExportIRCColorBoundaries(t *testing.T) {
+	vt := processor.NewVirtualTerminal(6, 2, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenSGR, Parameters: []string{"31"}},
+		{Type: types.TokenText, Value: "A1"},
+		{Type: types.TokenSGR, Parameters: []string{"4", "32"}},
+		{Type: types.TokenText, Value: "B2"},
+		{Type: types.TokenSGR, Parameters: []string{"0"}},
+		{Type: types.TokenText, Value: "C3"},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	got, err := ExportIRC(vt)
+	if err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+
+	expected := "\x0305,01A1" + "\x1F\x0303,01B2" + "\x0F\x0315,01C3\n"
+	if got != expected {
+		t.Fatalf("unexpected IRC output: got %q, want %q", got, expected)
+	}
+}
@@ -5,19 +5,76 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/badele/splitans/internal/processor"
 	"github.com/badele/splitans/internal/types"
 )
 
+// TokensJSONVersion is the version of the TokenizerJSONOutput envelope,
+// checked by the tokens JSON importer. The major version changes when the
+// shape of tokens or stats is no longer backward compatible.
+const TokensJSONVersion = "1.0"
+
 type TokenizerJSONOutput struct {
-	Tokens []types.Token    `json:"tokens"`
-	Stats  types.TokenStats `json:"stats"`
+	Version   string           `json:"version,omitempty"` // Empty in files written before versioning
+	Tokens    []types.Token    `json:"tokens"`
+	Stats     types.TokenStats `json:"stats"`
+	Positions []TokenPosition  `json:"positions,omitempty"` // Only with TokensJSONWithPositions
+}
+
+// TokenPosition is the screen cell where a token takes effect, the first
+// cell written for a text token (0-indexed)
+type TokenPosition struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// TokenPositions runs tokens through a virtual terminal and returns the
+// cursor position at which each token starts, one per token.
+func TokenPositions(width, nblines int, tokens []types.Token) ([]TokenPosition, error) {
+	vt := processor.NewVirtualTerminal(width, nblines, "utf8", false)
+
+	positions := make([]TokenPosition, len(tokens))
+	for i := range tokens {
+		x, y := vt.CursorPosition()
+		positions[i] = TokenPosition{X: x, Y: y}
+
+		if err := vt.ApplyTokens(tokens[i : i+1]); err != nil {
+			return nil, fmt.Errorf("error applying token %d: %w", i, err)
+		}
+	}
+
+	return positions, nil
 }
 
 func TokensJSON(tok types.TokenizerWithStats) {
+	printTokensJSON(TokenizerJSONOutput{
+		Version: TokensJSONVersion,
+		Tokens:  tok.Tokenize(),
+		Stats:   tok.GetStats(),
+	})
+}
+
+// TokensJSONWithPositions is TokensJSON with the screen position of each
+// token, computed on a width x nblines virtual terminal. Nothing is printed
+// when the positions cannot be computed.
+func TokensJSONWithPositions(tok types.TokenizerWithStats, width, nblines int) error {
 	output := TokenizerJSONOutput{
-		Tokens: tok.Tokenize(),
-		Stats:  tok.GetStats(),
+		Version: TokensJSONVersion,
+		Tokens:  tok.Tokenize(),
+		Stats:   tok.GetStats(),
+	}
+
+	positions, err := TokenPositions(width, nblines, output.Tokens)
+	if err != nil {
+		return fmt.Errorf("token positions: %w", err)
 	}
+	output.Positions = positions
+
+	printTokensJSON(output)
+	return nil
+}
+
+func printTokensJSON(output TokenizerJSONOutput) {
 
 	data, err := json.MarshalIndent(output, "", "  ")
 	if err != nil {
@@ -0,0 +1,49 @@
+package exporter
+
+import (
+	"testing"
+
+	"github.com/badele/splitans/internal/types"
+)
+
+func TestTokenPositions(t *testing.T) {
+	tokens := []types.Token{
+		{Type: types.TokenText, Value: "abc"},
+		{Type: types.TokenCSI, Raw: "\x1b[5;10H", Parameters: []string{"5", "10"}},
+		{Type: types.TokenText, Value: "X"},
+		{Type: types.TokenC0, C0Code: 0x0D},
+		{Type: types.TokenC0, C0Code: 0x0A},
+		{Type: types.TokenText, Value: "Y"},
+	}
+
+	positions, err := TokenPositions(80, 25, tokens)
+	if err != nil {
+		t.Fatalf("unexpected positions error: %v", err)
+	}
+
+	expected := []TokenPosition{{0, 0}, {3, 0}, {9, 4}, {10, 4}, {0, 4}, {0, 5}}
+	if len(positions) != len(expected) {
+		t.Fatalf("expected %d positions, got %d", len(expected), len(positions))
+	}
+	for i := range expected {
+		if positions[i] != expected[i] {
+			t.Errorf("token %d: expected %+v, got %+v", i, expected[i], positions[i])
+		}
+	}
+}
+
+func TestTokenPositionsAfterPendingWrap(t *testing.T) {
+	tokens := []types.Token{
+		{Type: types.TokenText, Value: "abcd"},
+		{Type: types.TokenText, Value: "e"},
+	}
+
+	positions, err := TokenPositions(4, 5, tokens)
+	if err != nil {
+		t.Fatalf("unexpected positions error: %v", err)
+	}
+
+	if got := positions[1]; got != (TokenPosition{X: 0, Y: 1}) {
+		t.Fatalf("expected the text after a full line on the next line, got %+v", got)
+	}
+}
@@ -0,0 +1,34 @@
+package exporter
+
+import (
+	"strings"
+
+	"github.com/badele/splitans/internal/processor"
+)
+
+// ExportMarkdown exports the virtual terminal buffer as a Markdown ```ansi
+// fenced block, with minimal ANSI codes from the differential encoder.
+func ExportMarkdown(vt *processor.VirtualTerminal) (string, error) {
+	return fenceMarkdown("ansi", vt.ExportFlattenedANSI()), nil
+}
+
+// ExportMarkdownPlain exports the virtual terminal buffer as a Markdown ```text
+// fenced block without any color.
+func ExportMarkdownPlain(vt *processor.VirtualTerminal) (string, error) {
+	return fenceMarkdown("text", vt.ExportPlainText()), nil
+}
+
+// fenceMarkdown wraps body in a fenced code block, with a fence longer than
+// any backtick run found in body
+func fenceMarkdown(lang, body string) string {
+	fence := "```"
+	for strings.Contains(body, fence) {
+		fence += "`"
+	}
+
+	if !strings.HasSuffix(body, "\n") {
+		body += "\n"
+	}
+
+	return fence + lang + "\n" + body + fence + "\n"
+}
@@ -0,0 +1,56 @@
+package exporter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/badele/splitans/internal/processor"
+	"github.com/badele/splitans/internal/types"
+)
+
+func TestExportMarkdownFence(t *testing.T) {
+	vt := processor.NewVirtualTerminal(4, 2, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenSGR, Parameters: []string{"31"}},
+		{Type: types.TokenText, Value: "AB"},
+		{Type: types.TokenC0, C0Code: 0x0A},
+		{Type: types.TokenText, Value: "CD"},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	got, err := ExportMarkdown(vt)
+	if err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+
+	if !strings.HasPrefix(got, "```ansi\n") {
+		t.Fatalf("expected opening ansi fence, got %q", got)
+	}
+	if !strings.HasSuffix(got, "\n```\n") {
+		t.Fatalf("expected closing fence on its own line, got %q", got)
+	}
+
+	body := strings.TrimSuffix(strings.TrimPrefix(got, "```ansi\n"), "```\n")
+	if ansi := vt.ExportFlattenedANSI(); strings.TrimSuffix(body, "\n") != strings.TrimSuffix(ansi, "\n") {
+		t.Fatalf("expected body %q, got %q", ansi, body)
+	}
+
+	plain, err := ExportMarkdownPlain(vt)
+	if err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+	if expected := "```text\nAB  \nCD  \n```\n"; plain != expected {
+		t.Fatalf("expected %q, got %q", expected, plain)
+	}
+}
+
+func TestExportMarkdownLongerFence(t *testing.T) {
+	got := fenceMarkdown("text", "```go\n")
+	if !strings.HasPrefix(got, "````text\n") || !strings.HasSuffix(got, "\n````\n") {
+		t.Fatalf("expected a four backtick fence, got %q", got)
+	}
+}
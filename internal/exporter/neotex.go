@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/badele/splitans/internal/importer/neotex"
 	"github.com/badele/splitans/internal/processor"
 	"github.com/badele/splitans/internal/types"
 )
@@ -20,6 +21,7 @@ var neotexFgColors = []string{
 
 var neotexBgColors = []string{
 	"Bk", "Br", "Bg", "By", "Bb", "Bm", "Bc", "Bw", // 0-7: normal
+	"BK", "BR", "BG", "BY", "BB", "BM", "BC", "BW", // 8-15: bright (iCE colors)
 }
 
 // SGRToNeotex converts an types.SGR struct to neotex format strings
@@ -81,6 +83,12 @@ func SGRToNeotex(sgr *types.SGR) []string {
 	if sgr.Reverse {
 		codes = append(codes, "ER")
 	}
+	if sgr.DoubleUnderline {
+		codes = append(codes, "EW")
+	}
+	if sgr.Overline {
+		codes = append(codes, "EO")
+	}
 
 	return codes
 }
@@ -183,6 +191,12 @@ func DiffSGRToNeotex(current, previous *types.SGR) []string {
 	if previous.Reverse && !current.Reverse {
 		needsReset = true
 	}
+	if previous.DoubleUnderline && !current.DoubleUnderline {
+		needsReset = true
+	}
+	if previous.Overline && !current.Overline {
+		needsReset = true
+	}
 
 	// If reset needed, return R0 + full current state
 	if needsReset {
@@ -220,6 +234,14 @@ func DiffSGRToNeotex(current, previous *types.SGR) []string {
 		codes = append(codes, "ER")
 	}
 
+	if current.DoubleUnderline && !previous.DoubleUnderline {
+		codes = append(codes, "EW")
+	}
+
+	if current.Overline && !previous.Overline {
+		codes = append(codes, "EO")
+	}
+
 	// Handle foreground color (including bold which affects brightness)
 	// We need to check both FgColor and Bold changes since Bold affects color brightness
 	fgChanged := current.FgColor != previous.FgColor
@@ -365,6 +387,35 @@ func exportToNeotex(vt *processor.VirtualTerminal, inline bool) (string, string)
 	return textBuilder.String(), seqBuilder.String()
 }
 
+// JoinNeotex combines the text and sequences returned by the neotex exports
+// into the neotex file layout, each text line followed by separator
+// (neotex.DefaultSeparator when empty) and its sequences. Lines shorter than
+// width end the output.
+func JoinNeotex(text, sequences string, width int, separator string) string {
+	if separator == "" {
+		separator = neotex.DefaultSeparator
+	}
+
+	textLines := strings.Split(text, "\n")
+	seqLines := strings.Split(sequences, "\n")
+
+	result := []string{}
+	for i, textLine := range textLines {
+		if len(textLine) < width {
+			break
+		}
+
+		seqLine := ""
+		if i < len(seqLines) {
+			seqLine = seqLines[i]
+		}
+
+		result = append(result, textLine+separator+seqLine)
+	}
+
+	return strings.Join(result, "\n")
+}
+
 // ExportFlattenedNeotex exports tokens to neotex format (always UTF-8)
 func ExportFlattenedNeotex(width, nblines int, tokens []types.Token) (string, string, error) {
 	return exportFlattenedNeotex(width, nblines, tokens, false)
@@ -408,6 +459,8 @@ func getTokenTypeName(tokenType types.TokenType) string {
 		return "types.SGR"
 	case types.TokenDCS:
 		return "DCS"
+	case types.TokenAPC:
+		return "APC"
 	case types.TokenOSC:
 		return "OSC"
 	case types.TokenEscape:
@@ -3,6 +3,7 @@ package exporter
 import (
 	"testing"
 
+	"github.com/badele/splitans/internal/importer/neotex"
 	"github.com/badele/splitans/internal/processor"
 	"github.com/badele/splitans/internal/types"
 )
@@ -32,3 +33,67 @@ func TestExportToInlineNeotex(t *testing.T) {
 		t.Fatalf("unexpected inline sequences: got %q, want %q", sequences, expectedSequences)
 	}
 }
+
+func TestNeotexRoundTripWithTabSeparator(t *testing.T) {
+	tokens := []types.Token{
+		{Type: types.TokenSGR, Parameters: []string{"31"}},
+		{Type: types.TokenText, Value: "a | b"},
+		{Type: types.TokenC0, C0Code: 0x0D},
+		{Type: types.TokenC0, C0Code: 0x0A},
+		{Type: types.TokenSGR, Parameters: []string{"32"}},
+		{Type: types.TokenText, Value: "cd"},
+	}
+
+	text, sequences, err := ExportFlattenedNeotex(5, 4, tokens)
+	if err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+
+	joined := JoinNeotex(text, sequences, 5, "\t")
+	expected := "a | b\t!V1; !TW5/5; !NL2; 1:Fr, Bk\ncd   \t1:Fg; 3:R0"
+	if joined != expected {
+		t.Fatalf("joined = %q, want %q", joined, expected)
+	}
+
+	width, tok, err := neotex.NewNeotexTokenizerWithSeparator([]byte(joined), 5, "\t")
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	if width != 5 {
+		t.Fatalf("expected width 5, got %d", width)
+	}
+
+	text2, sequences2, err := ExportFlattenedNeotex(width, 4, tok.Tokenize())
+	if err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+	if text2 != text || sequences2 != sequences {
+		t.Fatalf("round trip changed the output: %q %q, want %q %q", text2, sequences2, text, sequences)
+	}
+
+	// The default separator is not found in a tab separated file
+	if _, _, err := neotex.NewNeotexTokenizer([]byte(joined), 5); err == nil {
+		t.Fatalf("expected an error with the default separator")
+	}
+}
+
+func TestNeotexBrightBackgroundRoundTrip(t *testing.T) {
+	sgr := types.NewSGR()
+	sgr.BgColor = types.ColorValue{Type: types.ColorStandard, Index: 12}
+
+	codes := SGRToNeotex(sgr)
+	if len(codes) != 2 || codes[1] != "BB" {
+		t.Fatalf("expected bright blue background BB, got %v", codes)
+	}
+	if diff := DiffSGRToNeotex(sgr, types.NewSGR()); len(diff) != 1 || diff[0] != "BB" {
+		t.Fatalf("expected diff BB, got %v", diff)
+	}
+
+	back := types.NewSGR()
+	for _, code := range codes {
+		neotex.ApplyNeotexCode(code, back)
+	}
+	if !back.Equals(sgr) {
+		t.Fatalf("expected background index 12 after round trip, got %v", back.BgColor)
+	}
+}
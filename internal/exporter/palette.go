@@ -0,0 +1,56 @@
+package exporter
+
+import (
+	"fmt"
+
+	"github.com/badele/splitans/internal/types"
+)
+
+// indexedToRGB resolves a 256-color palette index to RGB
+// 0-15: VGA colors, 16-231: 6x6x6 color cube, 232-255: grayscale ramp
+func indexedToRGB(index uint8) [3]uint8 {
+	r, g, b := types.ColorValue{Type: types.ColorIndexed, Index: index}.ToRGB(true)
+	return [3]uint8{r, g, b}
+}
+
+// colorToRGB resolves a color to RGB with the VGA or modern 16 colors palette,
+// using the standard color fallback for the default color
+func colorToRGB(color types.ColorValue, useVGA bool, fallback uint8) [3]uint8 {
+	if color.Type == types.ColorDefault {
+		color = types.ColorValue{Type: types.ColorStandard, Index: fallback}
+	}
+	r, g, b := color.ToRGB(useVGA)
+	return [3]uint8{r, g, b}
+}
+
+// resolveSGRColors returns the displayed foreground and background RGB colors,
+// applying VGA bold brightening, reverse video and hidden text
+func resolveSGRColors(sgr *types.SGR) (fg, bg [3]uint8) {
+	return resolveSGRColorsWithPalette(sgr, true)
+}
+
+// resolveSGRColorsWithPalette is like resolveSGRColors with the VGA or modern
+// 16 colors palette
+func resolveSGRColorsWithPalette(sgr *types.SGR, useVGA bool) (fg, bg [3]uint8) {
+	fgColor := sgr.FgColor
+	// In VGA terminals, bold + color 0-7 = bright color 8-15
+	if sgr.Bold && fgColor.Type == types.ColorStandard && fgColor.Index < 8 {
+		fgColor.Index += 8
+	}
+
+	fg = colorToRGB(fgColor, useVGA, 7)
+	bg = colorToRGB(sgr.BgColor, useVGA, 0)
+
+	if sgr.Reverse {
+		fg, bg = bg, fg
+	}
+	if sgr.Hidden {
+		fg = bg
+	}
+
+	return fg, bg
+}
+
+func rgbToHex(rgb [3]uint8) string {
+	return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2])
+}
@@ -16,7 +16,7 @@ func ExportPassthroughANSI(tokens []types.Token) (string, error) {
 			result.WriteString(token.Value)
 
 		case types.TokenSGR, types.TokenCSI, types.TokenC0, types.TokenC1,
-			types.TokenEscape, types.TokenDCS, types.TokenOSC:
+			types.TokenEscape, types.TokenDCS, types.TokenAPC, types.TokenOSC:
 			// Reconstruit la séquence originale telle quelle
 			result.WriteString(token.Raw)
 
@@ -0,0 +1,110 @@
+package exporter
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+
+	"github.com/badele/splitans/internal/processor"
+)
+
+// PNGOptions configures the PNG rendering
+type PNGOptions struct {
+	Scale         int  // Size in pixels of a font dot (1 for 8x16 cells)
+	NineDots      bool // 9 pixels wide cells, box drawing and blocks extend on the 9th column
+	UseVGAPalette bool // Use VGA colors for standard colors (xterm colors otherwise)
+	BlinkOff      bool // Render the blink off phase, where blinking glyphs are hidden
+	// Raw 8x16 CP437 font (4096 bytes, a .f16 file or a VGA BIOS dump); the
+	// built-in placeholder font is used when nil
+	Font []byte
+}
+
+// DefaultPNGOptions returns options matching an 8x16 VGA text cell
+func DefaultPNGOptions() PNGOptions {
+	return PNGOptions{
+		Scale:         1,
+		UseVGAPalette: true,
+	}
+}
+
+// ExportPNG renders the processor.VirtualTerminal buffer as a PNG image, one
+// 8x16 (or 9x16) block per cell. The image is sized to the used area of the
+// buffer, like ExportSVG. Without opts.Font, glyphs come from a placeholder
+// font where runes outside ASCII, blocks and box drawing are hollow boxes.
+func ExportPNG(vt *processor.VirtualTerminal, w io.Writer, opts PNGOptions) error {
+	if opts.Scale <= 0 {
+		opts.Scale = DefaultPNGOptions().Scale
+	}
+	if opts.Font != nil && len(opts.Font) != fontSize {
+		return fmt.Errorf("font must be %d bytes (256 glyphs of 8x16), got %d", fontSize, len(opts.Font))
+	}
+
+	cells := vt.Cells()
+	if len(cells) == 0 || len(cells[0]) == 0 {
+		return fmt.Errorf("nothing to render")
+	}
+
+	cellWidth := glyphWidth
+	if opts.NineDots {
+		cellWidth++
+	}
+	cellWidth *= opts.Scale
+	cellHeight := glyphHeight * opts.Scale
+
+	img := image.NewRGBA(image.Rect(0, 0, len(cells[0])*cellWidth, len(cells)*cellHeight))
+
+	for y, line := range cells {
+		for x, cell := range line {
+			fg, bg := resolveSGRColorsWithPalette(cell.SGR, opts.UseVGAPalette)
+			px, py := x*cellWidth, y*cellHeight
+
+			cellRect := image.Rect(px, py, px+cellWidth, py+cellHeight)
+			draw.Draw(img, cellRect, &image.Uniform{C: rgbaColor(bg)}, image.Point{}, draw.Src)
+
+			if cell.Continuation || (opts.BlinkOff && cell.SGR.Blink) {
+				continue
+			}
+
+			g, ok := fontGlyph(opts.Font, cell.Char)
+			if !ok {
+				g = glyphFor(cell.Char)
+			}
+			if cell.SGR.Underline || cell.SGR.DoubleUnderline {
+				g[14] = 0xFF
+			}
+			if cell.SGR.Strikethrough {
+				g[7] = 0xFF
+			}
+
+			// VGA repeats the 8th column for box drawing and block elements
+			extend := opts.NineDots && cell.Char >= 0x2500 && cell.Char <= 0x259F
+
+			ink := &image.Uniform{C: rgbaColor(fg)}
+			for row, bits := range g {
+				for col := 0; col*opts.Scale < cellWidth; col++ {
+					on := false
+					if col < glyphWidth {
+						on = bits&(0x80>>col) != 0
+					} else if extend {
+						on = bits&0x01 != 0
+					}
+					if !on {
+						continue
+					}
+
+					dot := image.Rect(px+col*opts.Scale, py+row*opts.Scale, px+(col+1)*opts.Scale, py+(row+1)*opts.Scale)
+					draw.Draw(img, dot, ink, image.Point{}, draw.Src)
+				}
+			}
+		}
+	}
+
+	return png.Encode(w, img)
+}
+
+func rgbaColor(rgb [3]uint8) color.RGBA {
+	return color.RGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 0xFF}
+}
@@ -0,0 +1,103 @@
+package exporter
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/badele/splitans/internal/processor"
+	"github.com/badele/splitans/internal/types"
+)
+
+func TestExportPNGDimensions(t *testing.T) {
+	vt := processor.NewVirtualTerminal(10, 3, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenSGR, Parameters: []string{"31"}},
+		{Type: types.TokenText, Value: "█" + strings.Repeat("░", 29)},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		opts           PNGOptions
+		expectedWidth  int
+		expectedHeight int
+	}{
+		{"default", DefaultPNGOptions(), 80, 48},
+		{"nine dots", PNGOptions{Scale: 1, NineDots: true, UseVGAPalette: true}, 90, 48},
+		{"scaled", PNGOptions{Scale: 2, UseVGAPalette: true}, 160, 96},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := ExportPNG(vt, &buf, tt.opts); err != nil {
+				t.Fatalf("unexpected export error: %v", err)
+			}
+
+			img, err := png.Decode(&buf)
+			if err != nil {
+				t.Fatalf("invalid PNG: %v", err)
+			}
+
+			bounds := img.Bounds()
+			if bounds.Dx() != tt.expectedWidth || bounds.Dy() != tt.expectedHeight {
+				t.Fatalf("expected %dx%d, got %dx%d", tt.expectedWidth, tt.expectedHeight, bounds.Dx(), bounds.Dy())
+			}
+
+			// The full block is drawn in the foreground color
+			red := types.VGAPalette[1]
+			expected := color.RGBA{R: red[0], G: red[1], B: red[2], A: 0xFF}
+			if got := color.RGBAModel.Convert(img.At(tt.opts.Scale, tt.opts.Scale)); got != expected {
+				t.Fatalf("expected %v in the full block, got %v", expected, got)
+			}
+		})
+	}
+}
+
+func TestExportPNGFont(t *testing.T) {
+	vt := processor.NewVirtualTerminal(2, 1, "utf8", false)
+	if err := vt.ApplyTokens([]types.Token{{Type: types.TokenText, Value: "é¥"}}); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	// Only the CP437 glyph of 'é' (0x82) is set, as a full block
+	font := make([]byte, fontSize)
+	for row := 0; row < glyphHeight; row++ {
+		font[0x82*glyphHeight+row] = 0xFF
+	}
+
+	var buf bytes.Buffer
+	opts := DefaultPNGOptions()
+	opts.Font = font
+	if err := ExportPNG(vt, &buf, opts); err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatalf("invalid PNG: %v", err)
+	}
+
+	gray := types.VGAPalette[7]
+	ink := color.RGBA{R: gray[0], G: gray[1], B: gray[2], A: 0xFF}
+	if got := color.RGBAModel.Convert(img.At(0, 0)); got != ink {
+		t.Errorf("expected the font glyph of 'é' in %v, got %v", ink, got)
+	}
+
+	// '¥' (0x9D) is blank in the font, not the placeholder hollow box
+	if got := color.RGBAModel.Convert(img.At(glyphWidth+1, 2)); got == ink {
+		t.Errorf("expected the blank font glyph of '¥', got ink")
+	}
+
+	opts.Font = font[:100]
+	if err := ExportPNG(vt, &buf, opts); err == nil {
+		t.Errorf("expected an error for a truncated font")
+	}
+}
@@ -3,6 +3,7 @@ package exporter
 import (
 	"fmt"
 	"sort"
+	"strings"
 
 	"github.com/badele/splitans/internal/importer/ansi"
 	"github.com/badele/splitans/internal/types"
@@ -20,6 +21,7 @@ func DisplayStats(tok types.TokenizerWithStats) {
 	fmt.Println("=== Token Statistics ===")
 	fmt.Printf("  File size: %d bytes\n", stats.FileSize)
 	fmt.Printf("  Total tokens: %d\n", stats.TotalTokens)
+	fmt.Printf("  Lines: %d (max width %d)\n", stats.LineCount, stats.MaxLineWidth)
 
 	fmt.Println("\n--- Tokens by Type")
 
@@ -40,6 +42,13 @@ func DisplayStats(tok types.TokenizerWithStats) {
 		displayTopN(stats.SGRCodes, 10)
 	}
 
+	if len(stats.ColorUsage) > 0 {
+		fmt.Println("\n--- Top Colors (displayed)")
+		for _, e := range DominantColors(stats, 10) {
+			fmt.Printf("  %-30s: %5d\n", e.Key+" ("+e.Name+")", e.Count)
+		}
+	}
+
 	if len(stats.CSISequences) > 0 {
 		fmt.Println("\n--- Most Used CSI Sequences")
 		displayTopN(stats.CSISequences, 10)
@@ -78,6 +87,38 @@ func DisplayStats(tok types.TokenizerWithStats) {
 	}
 }
 
+// DominantColors returns the n most displayed colors of the stats, named
+// after the VGA palette for standard colors and by hex value otherwise.
+func DominantColors(stats types.TokenStats, n int) []StatEntry {
+	entries := topNEntries(stats.ColorUsage, colorName)
+	if len(entries) > n {
+		entries = entries[:n]
+	}
+	return entries
+}
+
+// colorName names a "fg:std:1" style key
+func colorName(key string) string {
+	_, value, _ := strings.Cut(key, ":")
+
+	color, err := types.ParseColorValue(value)
+	if err != nil {
+		return ""
+	}
+
+	switch color.Type {
+	case types.ColorDefault:
+		return "default"
+	case types.ColorStandard:
+		return types.VGAColorNames[color.Index]
+	case types.ColorIndexed:
+		return rgbToHex(indexedToRGB(color.Index))
+	case types.ColorRGB:
+		return rgbToHex([3]uint8{color.R, color.G, color.B})
+	}
+	return ""
+}
+
 func displayTopN(data map[string]int, n int) {
 	type entry struct {
 		Key   string
@@ -0,0 +1,97 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/badele/splitans/internal/importer/ansi"
+	"github.com/badele/splitans/internal/types"
+)
+
+// statsTopN is the number of entries kept in each top list
+const statsTopN = 10
+
+// StatEntry is a counted code with its human readable name
+type StatEntry struct {
+	Key   string `json:"key"`
+	Name  string `json:"name,omitempty"`
+	Count int    `json:"count"`
+}
+
+// StatsJSONOutput is the machine-readable form of DisplayStats
+type StatsJSONOutput struct {
+	types.TokenStats
+	TopSGRCodes     []StatEntry `json:"top_sgr_codes"`
+	TopCSISequences []StatEntry `json:"top_csi_sequences"`
+	TopC0Codes      []StatEntry `json:"top_c0_codes"`
+	TopC1Codes      []StatEntry `json:"top_c1_codes"`
+	TopColors       []StatEntry `json:"top_colors"`
+}
+
+// StatsJSON marshals the tokenizer stats with the most used codes, sorted
+// by count and with names resolved (e.g. SGR 1 is "Bold", C0 0x0A is "LF").
+func StatsJSON(tok types.TokenizerWithStats) ([]byte, error) {
+	stats := tok.GetStats()
+
+	output := StatsJSONOutput{
+		TokenStats:      stats,
+		TopSGRCodes:     topNEntries(stats.SGRCodes, sgrCodeName),
+		TopCSISequences: topNEntries(stats.CSISequences, nil),
+		TopC1Codes:      topNEntries(stats.C1Codes, nil),
+		TopColors:       DominantColors(stats, statsTopN),
+	}
+
+	c0Codes := make(map[string]int, len(stats.C0Codes))
+	for code, count := range stats.C0Codes {
+		c0Codes[fmt.Sprintf("0x%02X", code)] = count
+	}
+	output.TopC0Codes = topNEntries(c0Codes, c0CodeName)
+
+	data, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("JSON serialization error: %w", err)
+	}
+
+	return data, nil
+}
+
+// topNEntries returns the statsTopN most used keys, ties sorted by key
+func topNEntries(data map[string]int, nameOf func(string) string) []StatEntry {
+	entries := make([]StatEntry, 0, len(data))
+	for key, count := range data {
+		entry := StatEntry{Key: key, Count: count}
+		if nameOf != nil {
+			entry.Name = nameOf(key)
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Count != entries[j].Count {
+			return entries[i].Count > entries[j].Count
+		}
+		return entries[i].Key < entries[j].Key
+	})
+
+	if len(entries) > statsTopN {
+		entries = entries[:statsTopN]
+	}
+
+	return entries
+}
+
+func sgrCodeName(key string) string {
+	if code, err := parseInt(key); err == nil {
+		return ansi.SGRCodes[code]
+	}
+	return ""
+}
+
+func c0CodeName(key string) string {
+	var code byte
+	if _, err := fmt.Sscanf(key, "0x%02X", &code); err == nil {
+		return types.C0Names[code]
+	}
+	return ""
+}
@@ -0,0 +1,42 @@
+package exporter
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/badele/splitans/internal/importer/ansi"
+)
+
+func TestStatsJSON(t *testing.T) {
+	tok := ansi.NewANSITokenizer([]byte("\x1b[1;31mHi\x1b[1m!\r\n\x1b[2J"))
+	tok.Tokenize()
+
+	data, err := StatsJSON(tok)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{`"total_tokens"`, `"Bold"`, `"LF"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %s in JSON output", want)
+		}
+	}
+
+	var output StatsJSONOutput
+	if err := json.Unmarshal(data, &output); err != nil {
+		t.Fatalf("unexpected unmarshal error: %v", err)
+	}
+
+	if output.TotalTokens != tok.GetStats().TotalTokens {
+		t.Errorf("expected total_tokens %d, got %d", tok.GetStats().TotalTokens, output.TotalTokens)
+	}
+
+	if len(output.TopSGRCodes) == 0 {
+		t.Fatalf("expected top SGR codes")
+	}
+	top := output.TopSGRCodes[0]
+	if top.Key != "1" || top.Name != "Bold" || top.Count != 2 {
+		t.Errorf("expected Bold used twice first, got %+v", top)
+	}
+}
@@ -0,0 +1,45 @@
+package exporter
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/badele/splitans/internal/importer/ansi"
+)
+
+func TestDisplayStatsTopColors(t *testing.T) {
+	tok := ansi.NewANSITokenizer([]byte("\x1b[31mredredred\x1b[1;7mX\x1b[0mok"))
+	tok.Tokenize()
+
+	top := DominantColors(tok.GetStats(), 3)
+	if len(top) == 0 || top[0].Key != "bg:std:0" || top[0].Name != "Black" || top[0].Count != 11 {
+		t.Fatalf("expected the black background first, got %+v", top)
+	}
+	if top[1].Key != "fg:std:1" || top[1].Name != "Red" || top[1].Count != 9 {
+		t.Fatalf("expected red foreground second, got %+v", top[1])
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("unexpected pipe error: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	DisplayStats(tok)
+	os.Stdout = stdout
+	w.Close()
+
+	output, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected read error: %v", err)
+	}
+
+	// Bold red in reverse video shows a bright red background
+	for _, want := range []string{"--- Top Colors (displayed)", "fg:std:1 (Red)", "bg:std:9 (Bright Red)"} {
+		if !strings.Contains(string(output), want) {
+			t.Errorf("expected %q in stats output:\n%s", want, output)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package exporter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/badele/splitans/internal/processor"
+	"github.com/badele/splitans/internal/types"
+)
+
+// SVGOptions configures the SVG rendering
+type SVGOptions struct {
+	CellWidth     int    // Cell width in pixels
+	CellHeight    int    // Cell height in pixels
+	FontSize      int    // Font size in pixels
+	FontFamily    string // Monospace font family
+	UseVGAPalette bool   // Use VGA colors for standard colors (xterm colors otherwise)
+}
+
+// DefaultSVGOptions returns options matching a 9x16 VGA text cell
+func DefaultSVGOptions() SVGOptions {
+	return SVGOptions{
+		CellWidth:     9,
+		CellHeight:    16,
+		FontSize:      16,
+		FontFamily:    "monospace",
+		UseVGAPalette: true,
+	}
+}
+
+// ExportSVG exports the processor.VirtualTerminal buffer as an SVG image.
+// Each cell is rendered as a background <rect>, plus a <text> glyph when not blank.
+// A wide char gets one <rect> spanning its two cells and combining marks
+// are drawn with their base char. The viewBox is sized
+// to the used area of the buffer.
+func ExportSVG(vt *processor.VirtualTerminal, opts SVGOptions) (string, error) {
+	defaults := DefaultSVGOptions()
+	if opts.CellWidth <= 0 {
+		opts.CellWidth = defaults.CellWidth
+	}
+	if opts.CellHeight <= 0 {
+		opts.CellHeight = defaults.CellHeight
+	}
+	if opts.FontSize <= 0 {
+		opts.FontSize = defaults.FontSize
+	}
+	if opts.FontFamily == "" {
+		opts.FontFamily = defaults.FontFamily
+	}
+
+	cells := vt.Cells()
+	rows := len(cells)
+	cols := 0
+	if rows > 0 {
+		cols = len(cells[0])
+	}
+
+	widthPx := cols * opts.CellWidth
+	heightPx := rows * opts.CellHeight
+	baseline := opts.CellHeight * 4 / 5
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n",
+		widthPx, heightPx, widthPx, heightPx))
+	builder.WriteString(fmt.Sprintf("<g font-family=\"%s\" font-size=\"%d\" xml:space=\"preserve\">\n",
+		escapeXML(opts.FontFamily), opts.FontSize))
+
+	for y, row := range cells {
+		for x, cell := range row {
+			// The right half of a wide char is covered by its left half
+			if cell.Continuation {
+				continue
+			}
+
+			cellWidth := opts.CellWidth
+			if x+1 < len(row) && row[x+1].Continuation {
+				cellWidth *= 2
+			}
+
+			fg, bg := resolveSGRColorsWithPalette(cell.SGR, opts.UseVGAPalette)
+			px := x * opts.CellWidth
+			py := y * opts.CellHeight
+
+			builder.WriteString(fmt.Sprintf("<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"%s\"/>\n",
+				px, py, cellWidth, opts.CellHeight, rgbToHex(bg)))
+
+			if cell.Char == ' ' || cell.Char == 0x0 {
+				continue
+			}
+
+			builder.WriteString(fmt.Sprintf("<text x=\"%d\" y=\"%d\" fill=\"%s\"%s>", px, py+baseline, rgbToHex(fg), svgTextAttributes(cell.SGR)))
+			builder.WriteString(escapeXML(string(cell.Char) + string(cell.Combining)))
+			if cell.SGR.Blink {
+				builder.WriteString("<animate attributeName=\"opacity\" values=\"1;0;1\" dur=\"1s\" repeatCount=\"indefinite\"/>")
+			}
+			builder.WriteString("</text>\n")
+		}
+	}
+
+	builder.WriteString("</g>\n</svg>\n")
+
+	return builder.String(), nil
+}
+
+// svgTextAttributes converts SGR effects to SVG text attributes
+func svgTextAttributes(sgr *types.SGR) string {
+	var attrs []string
+
+	if sgr.Bold {
+		attrs = append(attrs, "font-weight=\"bold\"")
+	}
+	if sgr.Dim {
+		attrs = append(attrs, "opacity=\"0.5\"")
+	}
+	if sgr.Italic {
+		attrs = append(attrs, "font-style=\"italic\"")
+	}
+
+	var decorations []string
+	if sgr.Underline || sgr.DoubleUnderline {
+		decorations = append(decorations, "underline")
+	}
+	if sgr.Overline {
+		decorations = append(decorations, "overline")
+	}
+	if sgr.Strikethrough {
+		decorations = append(decorations, "line-through")
+	}
+	if len(decorations) > 0 {
+		attrs = append(attrs, fmt.Sprintf("text-decoration=\"%s\"", strings.Join(decorations, " ")))
+	}
+
+	if len(attrs) == 0 {
+		return ""
+	}
+	return " " + strings.Join(attrs, " ")
+}
+
+// escapeXML escapes XML special characters
+func escapeXML(s string) string {
+	var builder strings.Builder
+	for _, r := range s {
+		switch r {
+		case '<':
+			builder.WriteString("&lt;")
+		case '>':
+			builder.WriteString("&gt;")
+		case '&':
+			builder.WriteString("&amp;")
+		case '"':
+			builder.WriteString("&quot;")
+		case '\'':
+			builder.WriteString("&apos;")
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	return builder.String()
+}
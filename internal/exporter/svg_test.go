@@ -0,0 +1,93 @@
+package exporter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/badele/splitans/internal/processor"
+	"github.com/badele/splitans/internal/types"
+)
+
+func TestExportSVGElementCount(t *testing.T) {
+	vt := processor.NewVirtualTerminal(4, 4, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenSGR, Parameters: []string{"31"}},
+		{Type: types.TokenText, Value: "AB C"},
+		{Type: types.TokenSGR, Parameters: []string{"5", "44"}},
+		{Type: types.TokenText, Value: "D"},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	svg, err := ExportSVG(vt, DefaultSVGOptions())
+	if err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+
+	// 2 rows of 4 cells, 4 non blank glyphs
+	if got := strings.Count(svg, "<rect "); got != 8 {
+		t.Errorf("expected 8 rect elements, got %d", got)
+	}
+	if got := strings.Count(svg, "<text "); got != 4 {
+		t.Errorf("expected 4 text elements, got %d", got)
+	}
+	if got := strings.Count(svg, "<animate "); got != 1 {
+		t.Errorf("expected 1 animate element, got %d", got)
+	}
+	if !strings.Contains(svg, "viewBox=\"0 0 36 32\"") {
+		t.Errorf("expected viewBox sized to 4x2 cells, got %q", svg[:strings.Index(svg, "\n")])
+	}
+}
+
+func TestExportSVGWideChar(t *testing.T) {
+	vt := processor.NewVirtualTerminal(10, 1, "utf8", false)
+	if err := vt.ApplyTokens([]types.Token{{Type: types.TokenText, Value: "a漢b"}}); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	svg, err := ExportSVG(vt, DefaultSVGOptions())
+	if err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+
+	// The wide char covers columns 1 and 2, b is drawn on column 3
+	for _, want := range []string{
+		"<text x=\"9\" y=\"12\" fill=\"#aaaaaa\">漢</text>",
+		"<rect x=\"9\" y=\"0\" width=\"18\" height=\"16\"",
+		"<text x=\"27\" y=\"12\" fill=\"#aaaaaa\">b</text>",
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("expected %q in:\n%s", want, svg)
+		}
+	}
+	if got := strings.Count(svg, "<rect "); got != 3 {
+		t.Errorf("expected 3 rect elements, got %d", got)
+	}
+}
+
+func TestExportSVGCombiningMark(t *testing.T) {
+	vt := processor.NewVirtualTerminal(10, 1, "utf8", false)
+	if err := vt.ApplyTokens([]types.Token{{Type: types.TokenText, Value: "e\u0301x"}}); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	svg, err := ExportSVG(vt, DefaultSVGOptions())
+	if err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+
+	for _, want := range []string{
+		"<text x=\"0\" y=\"12\" fill=\"#aaaaaa\">e\u0301</text>",
+		"<text x=\"9\" y=\"12\" fill=\"#aaaaaa\">x</text>",
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("expected %q in:\n%s", want, svg)
+		}
+	}
+	if got := strings.Count(svg, "<text "); got != 2 {
+		t.Errorf("expected 2 text elements, got %d", got)
+	}
+}
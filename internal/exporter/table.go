@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/badele/splitans/internal/importer/ansi"
+	"github.com/badele/splitans/internal/processor"
 	"github.com/badele/splitans/internal/types"
 )
 
@@ -49,6 +52,12 @@ func ExportTokensToTable(tokens []types.Token, writer io.Writer) error {
 			params = "-"
 			rawOrText = truncate(token.Raw, 36)
 
+		case types.TokenAPC:
+			csiSignification = "-"
+			signification = truncate(token.Signification, 36)
+			params = "-"
+			rawOrText = truncate(token.Raw, 36)
+
 		case types.TokenC0:
 			csiSignification = "-"
 			if name, ok := types.C0Names[token.C0Code]; ok {
@@ -82,8 +91,9 @@ func ExportTokensToTable(tokens []types.Token, writer io.Writer) error {
 			rawOrText = truncate(token.Raw, 36)
 		}
 
-		fmt.Fprintf(writer, "│ %-7d │ %-6d │ %-36s │ %-36s │ %-15s │ %-36s │\n",
-			i+1, token.Pos, csiSignification, signification, params, rawOrText)
+		fmt.Fprintf(writer, "│ %-7d │ %-6d │ %s │ %s │ %s │ %s │\n",
+			i+1, token.Pos, padDisplay(csiSignification, 36), padDisplay(signification, 36),
+			padDisplay(params, 15), padDisplay(rawOrText, 36))
 	}
 
 	fmt.Fprintln(writer, "└─────────┴────────┴──────────────────────────────────────┴──────────────────────────────────────┴─────────────────┴──────────────────────────────────────┘")
@@ -91,6 +101,8 @@ func ExportTokensToTable(tokens []types.Token, writer io.Writer) error {
 	return nil
 }
 
+// truncate quotes s like %q, so control chars and escapes become visible,
+// and cuts it to maxLen display columns
 func truncate(s string, maxLen int) string {
 	s = fmt.Sprintf("%q", s)
 
@@ -99,8 +111,105 @@ func truncate(s string, maxLen int) string {
 		s = s[1 : len(s)-1]
 	}
 
-	if len(s) > maxLen {
-		return s[:maxLen-3] + "..."
+	return truncateDisplay(s, maxLen)
+}
+
+// truncateDisplay cuts s to at most cols display columns, ending it with
+// "..." when cut. Wide runes use two columns and escape sequences none,
+// neither is split.
+func truncateDisplay(s string, cols int) string {
+	if displayWidth(s) <= cols {
+		return s
+	}
+
+	const ellipsis = "..."
+	if cols < len(ellipsis) {
+		return ellipsis[:max(cols, 0)]
+	}
+
+	limit := cols - len(ellipsis)
+	var builder strings.Builder
+	used := 0
+	for i := 0; i < len(s); {
+		if n := escapeLen(s[i:]); n > 0 {
+			builder.WriteString(s[i : i+n])
+			i += n
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if used+runeWidth(r) > limit {
+			break
+		}
+		builder.WriteString(s[i : i+size])
+		used += runeWidth(r)
+		i += size
+	}
+
+	return builder.String() + ellipsis
+}
+
+// padDisplay pads s with spaces to cols display columns
+func padDisplay(s string, cols int) string {
+	return s + strings.Repeat(" ", max(cols-displayWidth(s), 0))
+}
+
+// displayWidth returns the number of columns used by s on a terminal
+func displayWidth(s string) int {
+	columns := 0
+	for i := 0; i < len(s); {
+		if n := escapeLen(s[i:]); n > 0 {
+			i += n
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		columns += runeWidth(r)
+		i += size
+	}
+
+	return columns
+}
+
+// runeWidth returns the columns used by r, 0 for combining marks and
+// processor.RuneWidth otherwise
+func runeWidth(r rune) int {
+	if unicode.In(r, unicode.Mn, unicode.Me) {
+		return 0
+	}
+	return processor.RuneWidth(r)
+}
+
+// escapeLen returns the length of the escape sequence starting s (CSI up to
+// its final byte, OSC up to BEL or ST, other escapes on two bytes), 0 when
+// s does not start with ESC
+func escapeLen(s string) int {
+	if len(s) == 0 || s[0] != 0x1b {
+		return 0
 	}
-	return s
+	if len(s) == 1 {
+		return 1
+	}
+
+	switch s[1] {
+	case '[':
+		for i := 2; i < len(s); i++ {
+			if s[i] >= 0x40 && s[i] <= 0x7E {
+				return i + 1
+			}
+		}
+		return len(s)
+	case ']':
+		for i := 2; i < len(s); i++ {
+			if s[i] == 0x07 {
+				return i + 1
+			}
+			if s[i] == 0x1b && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		return len(s)
+	}
+
+	return 2
 }
@@ -0,0 +1,57 @@
+package exporter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/badele/splitans/internal/types"
+)
+
+func TestTruncateDisplay(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		cols     int
+		expected string
+	}{
+		{name: "fits", input: "hello", cols: 5, expected: "hello"},
+		{name: "ascii", input: "hello world", cols: 8, expected: "hello..."},
+		{name: "wide runes", input: "日本語のテキスト", cols: 8, expected: "日本..."},
+		{name: "wide rune not split", input: "a日本語", cols: 6, expected: "a日..."},
+		{name: "escape not counted", input: "\x1b[31mred\x1b[0m", cols: 3, expected: "\x1b[31mred\x1b[0m"},
+		{name: "escape not split", input: "ab\x1b[1;31mcdefgh", cols: 6, expected: "ab\x1b[1;31mc..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateDisplay(tt.input, tt.cols)
+			if got != tt.expected {
+				t.Fatalf("expected %q, got %q", tt.expected, got)
+			}
+			if displayWidth(got) > tt.cols {
+				t.Fatalf("expected at most %d columns, got %d", tt.cols, displayWidth(got))
+			}
+		})
+	}
+}
+
+func TestExportTokensToTableAlignsWideText(t *testing.T) {
+	tokens := []types.Token{
+		{Type: types.TokenText, Value: "ascii"},
+		{Type: types.TokenText, Value: strings.Repeat("漢字", 12)},
+	}
+
+	var buf bytes.Buffer
+	if err := ExportTokensToTable(tokens, &buf); err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+
+	lines := strings.Split(strings.Trim(buf.String(), "\n"), "\n")
+	expected := displayWidth(lines[0])
+	for i, line := range lines {
+		if got := displayWidth(line); got != expected {
+			t.Fatalf("line %d: expected %d columns, got %d: %q", i, expected, got, line)
+		}
+	}
+}
@@ -10,15 +10,48 @@ import (
 // ExportFlattenedText exports tokens to flattened plain text without styles
 // using a virtual terminal buffer to resolve cursor positioning
 func ExportFlattenedText(width, nblines int, tokens []types.Token, outputEncoding string) (string, error) {
-	return exportFlattenedText(width, nblines, tokens, outputEncoding, false)
+	return exportFlattenedText(width, nblines, tokens, outputEncoding, false, false)
+}
+
+// ExportFlattenedTextTrimmed exports tokens to flattened plain text with
+// trailing spaces removed from each line.
+func ExportFlattenedTextTrimmed(width, nblines int, tokens []types.Token, outputEncoding string) (string, error) {
+	return exportFlattenedText(width, nblines, tokens, outputEncoding, false, true)
 }
 
 // ExportFlattenedTextInline exports tokens to flattened plain text on a single line.
 func ExportFlattenedTextInline(width, nblines int, tokens []types.Token, outputEncoding string) (string, error) {
-	return exportFlattenedText(width, nblines, tokens, outputEncoding, true)
+	return exportFlattenedText(width, nblines, tokens, outputEncoding, true, false)
+}
+
+// ExportFlattenedTextWithOptions exports tokens to flattened plain text with a
+// custom line ending and optional trailing newline.
+func ExportFlattenedTextWithOptions(width, nblines int, tokens []types.Token, outputEncoding string, opts processor.PlainTextOptions) (string, error) {
+	vt := processor.NewVirtualTerminal(width, nblines, outputEncoding, false)
+	vt.SetTabWidth(opts.TabWidth)
+	vt.SetOverstrike(opts.Overstrike)
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		return "", fmt.Errorf("error applying tokens: %w", err)
+	}
+
+	return vt.ExportPlainTextWithOptions(opts), nil
 }
 
-func exportFlattenedText(width, nblines int, tokens []types.Token, outputEncoding string, inline bool) (string, error) {
+// ExportFlattenedLayout exports tokens to plain text keeping the layout
+// resolved by the virtual terminal: styles are dropped, blanks are written
+// as spaces and lines are not trimmed.
+func ExportFlattenedLayout(width, nblines int, tokens []types.Token, outputEncoding string) (string, error) {
+	vt := processor.NewVirtualTerminal(width, nblines, outputEncoding, false)
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		return "", fmt.Errorf("error applying tokens: %w", err)
+	}
+
+	return vt.ExportLayout(), nil
+}
+
+func exportFlattenedText(width, nblines int, tokens []types.Token, outputEncoding string, inline bool, trim bool) (string, error) {
 	vt := processor.NewVirtualTerminal(width, nblines, outputEncoding, false)
 
 	if err := vt.ApplyTokens(tokens); err != nil {
@@ -29,5 +62,9 @@ func exportFlattenedText(width, nblines int, tokens []types.Token, outputEncodin
 		return vt.ExportPlainTextInline(), nil
 	}
 
+	if trim {
+		return vt.ExportPlainTextTrimmed(), nil
+	}
+
 	return vt.ExportPlainText(), nil
 }
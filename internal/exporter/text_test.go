@@ -4,6 +4,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/badele/splitans/internal/processor"
 	"github.com/badele/splitans/internal/types"
 )
 
@@ -32,3 +33,94 @@ func TestExportFlattenedTextInline(t *testing.T) {
 		t.Fatalf("inline output should equal standard output without newlines")
 	}
 }
+
+func TestExportFlattenedTextTrimmed(t *testing.T) {
+	tokens := []types.Token{
+		{Type: types.TokenText, Value: "a  b"},
+		{Type: types.TokenC0, C0Code: 0x0D},
+		{Type: types.TokenC0, C0Code: 0x0A},
+		{Type: types.TokenText, Value: "cd  "},
+		{Type: types.TokenC0, C0Code: 0x0D},
+		{Type: types.TokenC0, C0Code: 0x0A},
+		{Type: types.TokenSGR, Parameters: []string{"44"}},
+		{Type: types.TokenText, Value: "  "},
+	}
+
+	standard, err := ExportFlattenedText(6, 3, tokens, "utf8")
+	if err != nil {
+		t.Fatalf("unexpected standard export error: %v", err)
+	}
+
+	trimmed, err := ExportFlattenedTextTrimmed(6, 3, tokens, "utf8")
+	if err != nil {
+		t.Fatalf("unexpected trimmed export error: %v", err)
+	}
+
+	if expected := "a  b  \ncd    \n      \n"; standard != expected {
+		t.Fatalf("expected untrimmed %q, got %q", expected, standard)
+	}
+
+	// Internal spacing is kept, the blue blanks on the last line too
+	if expected := "a  b\ncd\n  \n"; trimmed != expected {
+		t.Fatalf("expected trimmed %q, got %q", expected, trimmed)
+	}
+}
+
+func TestExportFlattenedTextLineEndings(t *testing.T) {
+	tokens := []types.Token{
+		{Type: types.TokenOSC, Parameters: []string{"0", "My Art"}},
+		{Type: types.TokenText, Value: "AB"},
+		{Type: types.TokenC0, C0Code: 0x0D},
+		{Type: types.TokenC0, C0Code: 0x0A},
+		{Type: types.TokenText, Value: "CD"},
+	}
+
+	tests := []struct {
+		name     string
+		opts     processor.PlainTextOptions
+		expected string
+	}{
+		{"default", processor.DefaultPlainTextOptions(), "AB\nCD\n"},
+		{"crlf", processor.PlainTextOptions{LineEnding: "\r\n", TrailingNewline: true}, "AB\r\nCD\r\n"},
+		{"no trailing newline", processor.PlainTextOptions{LineEnding: "\n"}, "AB\nCD"},
+		{"crlf without trailing newline", processor.PlainTextOptions{LineEnding: "\r\n"}, "AB\r\nCD"},
+		{"title", processor.PlainTextOptions{LineEnding: "\r\n", Title: true}, "My Art\r\n\r\nAB\r\nCD"},
+		{"inline", processor.PlainTextOptions{LineEnding: "\r\n", TrailingNewline: true, Inline: true}, "ABCD"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExportFlattenedTextWithOptions(2, 2, tokens, "utf8", tt.opts)
+			if err != nil {
+				t.Fatalf("unexpected export error: %v", err)
+			}
+			if got != tt.expected {
+				t.Fatalf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestExportFlattenedLayoutKeepsSpacing(t *testing.T) {
+	tokens := []types.Token{
+		{Type: types.TokenSGR, Parameters: []string{"31"}},
+		{Type: types.TokenText, Value: "a"},
+		{Type: types.TokenCSI, Raw: "\x1b[3C", Parameters: []string{"3"}},
+		{Type: types.TokenText, Value: "b"},
+		{Type: types.TokenC0, C0Code: 0x0D},
+		{Type: types.TokenC0, C0Code: 0x0A},
+		{Type: types.TokenCSI, Raw: "\x1b[2C", Parameters: []string{"2"}},
+		{Type: types.TokenText, Value: "c"},
+	}
+
+	for _, encoding := range []string{"utf8", "cp437"} {
+		layout, err := ExportFlattenedLayout(6, 3, tokens, encoding)
+		if err != nil {
+			t.Fatalf("unexpected layout export error: %v", err)
+		}
+
+		if expected := "a   b \n  c   \n"; layout != expected {
+			t.Fatalf("%s: expected %q, got %q", encoding, expected, layout)
+		}
+	}
+}
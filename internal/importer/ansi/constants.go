@@ -10,6 +10,7 @@ var C1Sequences = map[string]string{
 	"[":  "CSI", // Control Sequence Introducer
 	"\\": "ST",  // String Terminator
 	"]":  "OSC", // Operating System Command
+	"_":  "APC", // Application Program Command
 }
 
 // SGR codes descriptions
@@ -52,6 +53,9 @@ var SGRCodes = map[int]string{
 	49:  "BackgroundDefault",
 	53:  "OverlineOn",
 	55:  "OverlineOff",
+	73:  "Superscript",
+	74:  "Subscript",
+	75:  "SuperscriptSubscriptOff",
 	59:  "UnderlineColorDefault",
 	90:  "ForegroundBrightBlack",
 	91:  "ForegroundBrightRed",
@@ -0,0 +1,130 @@
+package ansi
+
+import (
+	"errors"
+	"io"
+	"slices"
+
+	"github.com/badele/splitans/internal/types"
+)
+
+const streamChunkSize = 4096
+
+// StreamTokenizer tokenizes ANSI data read incrementally from an io.Reader,
+// so large inputs never need to be held in memory as a whole.
+type StreamTokenizer struct {
+	reader    io.Reader
+	tokenizer *Tokenizer
+	buffer    []byte // Read bytes, may end with a partial sequence
+	start     int    // Index in buffer of the first unconsumed byte
+	base      int64  // Stream offset of buffer[0]
+	readSize  int    // Bytes asked by the next read, doubled while a token stays partial
+	eof       bool
+	badSeen   bool // An interrupted CSI was already found
+	err       error
+}
+
+// NewStreamTokenizer creates a tokenizer reading UTF-8 ANSI data from r.
+func NewStreamTokenizer(r io.Reader) *StreamTokenizer {
+	return &StreamTokenizer{
+		reader:    r,
+		tokenizer: NewANSITokenizer(nil),
+		buffer:    make([]byte, 0, streamChunkSize),
+		readSize:  streamChunkSize,
+	}
+}
+
+// Next returns the next token, or io.EOF when the input is exhausted.
+// Like Tokenize, tokenization stops after a CSI interrupted by a C0 control,
+// unless RecoverMode is enabled. Token positions are relative to the stream.
+func (s *StreamTokenizer) Next() (types.Token, error) {
+	if s.err != nil {
+		return types.Token{}, s.err
+	}
+
+	t := s.tokenizer
+	startRunePos := t.runePos
+
+	for {
+		if s.start >= len(s.buffer) {
+			if s.eof {
+				s.err = io.EOF
+				return types.Token{}, s.err
+			}
+			if err := s.fill(); err != nil {
+				return types.Token{}, err
+			}
+			continue
+		}
+
+		// Parse a token from the first unconsumed byte
+		t.input = s.buffer
+		t.pos = s.start
+		t.runePos = startRunePos
+		t.Tokens = t.Tokens[:0]
+		t.nextToken()
+
+		// A token reaching the buffer end may continue in the next read
+		// (text run, split escape sequence or split UTF-8 rune). It is
+		// parsed again from its start, reading more each time so a long
+		// token costs linear time.
+		if t.pos >= len(s.buffer) && !s.eof {
+			if err := s.fill(); err != nil {
+				return types.Token{}, err
+			}
+			s.readSize *= 2
+			continue
+		}
+
+		token := t.Tokens[0]
+		switch token.Type {
+		case types.TokenSauce:
+			// Its position is a byte index in the buffer
+			token.Pos += int(s.base)
+		case types.TokenCSIInterupted:
+			if !s.badSeen {
+				t.Stats.PosFirstBadSequence += s.base
+				s.badSeen = true
+			}
+			if !t.RecoverMode {
+				s.err = io.EOF
+			}
+		}
+
+		s.start = t.pos
+		s.readSize = streamChunkSize
+
+		return token, nil
+	}
+}
+
+// SetRecoverMode keeps parsing after a CSI interrupted by a C0 control.
+func (s *StreamTokenizer) SetRecoverMode(recover bool) {
+	s.tokenizer.RecoverMode = recover
+}
+
+// fill drops the consumed bytes from the buffer and appends up to readSize
+// bytes read from the reader
+func (s *StreamTokenizer) fill() error {
+	if s.start > 0 {
+		n := copy(s.buffer, s.buffer[s.start:])
+		s.buffer = s.buffer[:n]
+		s.base += int64(s.start)
+		s.start = 0
+	}
+
+	size := len(s.buffer)
+	s.buffer = slices.Grow(s.buffer, s.readSize)
+	n, err := s.reader.Read(s.buffer[size : size+s.readSize])
+	s.buffer = s.buffer[:size+n]
+
+	if errors.Is(err, io.EOF) {
+		s.eof = true
+		return nil
+	}
+	if err != nil {
+		s.err = err
+		return err
+	}
+	return nil
+}
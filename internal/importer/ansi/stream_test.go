@@ -0,0 +1,80 @@
+package ansi
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"testing/iotest"
+
+	"github.com/badele/splitans/internal/types"
+)
+
+func TestStreamTokenizerOneByteReader(t *testing.T) {
+	input := []byte("Héllo\x1b[1;31mWorld\x1b]0;title\x07\r\n\x1b[10;5Hé")
+
+	expected := NewANSITokenizer(input).Tokenize()
+
+	stream := NewStreamTokenizer(iotest.OneByteReader(bytes.NewReader(input)))
+	var got []types.Token
+	for {
+		token, err := stream.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected stream error: %v", err)
+		}
+		got = append(got, token)
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("Expected tokens %v, got %v", expected, got)
+	}
+}
+
+func TestStreamTokenizerReaderError(t *testing.T) {
+	readErr := errors.New("read failure")
+	stream := NewStreamTokenizer(iotest.ErrReader(readErr))
+
+	if _, err := stream.Next(); !errors.Is(err, readErr) {
+		t.Fatalf("Expected read error, got %v", err)
+	}
+}
+
+func TestStreamTokenizerPositionsAcrossReads(t *testing.T) {
+	// Several chunks of text, then a SAUCE record after SUB
+	var input []byte
+	for i := 0; i < 2000; i++ {
+		input = append(input, "\x1b[1;31mab\x1b[0m cd\r\n"...)
+	}
+	input = append(input, "\x1bM"...)
+	input = append(input, make([]byte, 3*streamChunkSize)...)
+	input = append(input, 0x1A)
+	input = append(input, "SAUCE00"...)
+
+	expected := NewANSITokenizer(input).Tokenize()
+
+	stream := NewStreamTokenizer(iotest.HalfReader(bytes.NewReader(input)))
+	var got []types.Token
+	for {
+		token, err := stream.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected stream error: %v", err)
+		}
+		got = append(got, token)
+	}
+
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %d tokens, got %d", len(expected), len(got))
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(got[i], expected[i]) {
+			t.Fatalf("Token %d: expected %v, got %v", i, expected[i], got[i])
+		}
+	}
+}
@@ -8,11 +8,14 @@ package ansi
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 
+	"github.com/badele/splitans/internal/processor"
 	"github.com/badele/splitans/internal/types"
 )
 
@@ -22,8 +25,37 @@ type Tokenizer struct {
 	runePos int              // Position en runes (caractères Unicode)
 	Tokens  []types.Token    `json:"tokens"`
 	Stats   types.TokenStats `json:"stats"`
+
+	// RecoverMode keeps parsing after a CSI interrupted by a C0 control
+	// instead of stopping at the first bad sequence
+	RecoverMode bool `json:"-"`
+	badBytes    int  // Bytes of interrupted sequences, in RecoverMode
+
+	// DeleteAsControl tokenizes DEL (0x7F) as a TokenC0, which renders
+	// nothing, instead of a char of the text
+	DeleteAsControl bool `json:"-"`
+
+	// ScreenWidth is the number of columns where text wraps while counting
+	// LineCount and MaxLineWidth, DefaultScreenWidth by default
+	ScreenWidth int `json:"-"`
+
+	// TabWidth is the number of columns between the tab stops used while
+	// counting LineCount and MaxLineWidth, DefaultTabWidth by default
+	TabWidth int `json:"-"`
+
+	statsSGR     *types.SGR // Style in effect while accumulating ColorUsage
+	statsX       int        // Cursor while accumulating LineCount and MaxLineWidth
+	statsY       int
+	statsWrap    bool // The last column was written, the next char wraps
+	statsWrapped bool // The cursor just wrapped, a CR or a LF is ignored
 }
 
+// DefaultScreenWidth is the ScreenWidth of a new tokenizer
+const DefaultScreenWidth = 80
+
+// DefaultTabWidth is the TabWidth of a new tokenizer
+const DefaultTabWidth = 8
+
 func NewANSITokenizer(input []byte) *Tokenizer {
 	stats := types.TokenStats{
 		TokensByType:        make(map[types.TokenType]int),
@@ -31,6 +63,7 @@ func NewANSITokenizer(input []byte) *Tokenizer {
 		CSISequences:        make(map[string]int),
 		C0Codes:             make(map[byte]int),
 		C1Codes:             make(map[string]int),
+		ColorUsage:          make(map[string]int),
 		FileSize:            int64(len(input)),
 		ParsedPercent:       0.0,
 		PosFirstBadSequence: 0,
@@ -42,25 +75,80 @@ func NewANSITokenizer(input []byte) *Tokenizer {
 		runePos: 0,
 		Tokens:  make([]types.Token, 0),
 		Stats:   stats,
+
+		ScreenWidth: DefaultScreenWidth,
+		TabWidth:    DefaultTabWidth,
+		statsSGR:    types.NewSGR(),
 	}
 }
 
+// Reset prepares t to tokenize input, keeping RecoverMode, DeleteAsControl,
+// ScreenWidth and TabWidth. The stats maps
+// are cleared in place rather than reallocated, to reuse one tokenizer over
+// many files: copy them first to keep the stats of the previous run. The
+// Tokens returned by a previous run are left untouched.
+func (t *Tokenizer) Reset(input []byte) {
+	t.input = input
+	t.pos = 0
+	t.runePos = 0
+	t.Tokens = make([]types.Token, 0)
+	t.badBytes = 0
+
+	stats := &t.Stats
+	clear(stats.TokensByType)
+	clear(stats.SGRCodes)
+	clear(stats.CSISequences)
+	clear(stats.C0Codes)
+	clear(stats.C1Codes)
+	clear(stats.ColorUsage)
+	stats.TotalTokens = 0
+	stats.TotalTextLength = 0
+	stats.LineCount = 0
+	stats.MaxLineWidth = 0
+	stats.FileSize = int64(len(input))
+	stats.ParsedPercent = 0.0
+	stats.PosFirstBadSequence = 0
+
+	t.statsSGR.Reset()
+	t.statsX = 0
+	t.statsY = 0
+	t.statsWrap = false
+	t.statsWrapped = false
+}
+
+// contextCheckInterval is the number of tokens parsed between two checks
+// of the context in TokenizeContext
+const contextCheckInterval = 4096
+
 func (t *Tokenizer) Tokenize() []types.Token {
-	for t.pos < len(t.input) {
+	tokens, _ := t.TokenizeContext(context.Background())
+	return tokens
+}
+
+// TokenizeContext is Tokenize stopping early when ctx is done: the tokens
+// parsed so far are returned with ctx.Err(), and the stats are not computed.
+func (t *Tokenizer) TokenizeContext(ctx context.Context) ([]types.Token, error) {
+	for n := 0; t.pos < len(t.input); n++ {
+		if n%contextCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return t.Tokens, err
+			}
+		}
+
 		t.nextToken()
 
 		// Verify if parsing was interrupted by bad CSI
-		if len(t.Tokens) > 0 && t.Tokens[len(t.Tokens)-1].Type == types.TokenCSIInterupted {
+		if !t.RecoverMode && len(t.Tokens) > 0 && t.Tokens[len(t.Tokens)-1].Type == types.TokenCSIInterupted {
 			t.Stats.ParsedPercent = float64(t.Stats.PosFirstBadSequence) / float64(t.Stats.FileSize) * 100
-			return t.Tokens
+			return t.Tokens, nil
 		}
 	}
 
-	t.Stats.ParsedPercent = 100
+	t.Stats.ParsedPercent = t.coveragePercent()
 
 	t.calculateStats()
 
-	return t.Tokens
+	return t.Tokens, nil
 }
 
 func (t *Tokenizer) nextToken() {
@@ -72,6 +160,10 @@ func (t *Tokenizer) nextToken() {
 
 	// C0 (0x00-0x1F)
 	// not printable characters
+	if c == 0x7F && t.DeleteAsControl {
+		t.parseC0(t.pos, c)
+		return
+	}
 	if c < 0x20 {
 		if c == 0x1B { // ESC
 			t.parseEscape(t.pos)
@@ -125,6 +217,8 @@ func (t *Tokenizer) parseEscape(start int) {
 			t.parseDCS(startBytePos, startRunePos)
 		case "OSC":
 			t.parseOSC(startBytePos, startRunePos)
+		case "APC":
+			t.parseAPC(startBytePos, startRunePos)
 		case "ST":
 			t.Tokens = append(t.Tokens, types.Token{
 				Type:   types.TokenC1,
@@ -162,13 +256,15 @@ func (t *Tokenizer) parseSauce(start int) {
 }
 
 func (t *Tokenizer) parseCSI(startBytePos int, startRunePos int) {
-	params := t.collectParams()
+	params, prefix, intermediates := t.collectParams()
 
 	if t.pos >= len(t.input) {
 		t.Tokens = append(t.Tokens, types.Token{
-			Type: types.TokenCSI,
-			Pos:  startRunePos,
-			Raw:  string(t.input[startBytePos:t.pos]),
+			Type:          types.TokenCSI,
+			Pos:           startRunePos,
+			Raw:           string(t.input[startBytePos:t.pos]),
+			Prefix:        prefix,
+			Intermediates: intermediates,
 		})
 		t.runePos += (t.pos - startBytePos)
 		return
@@ -178,18 +274,32 @@ func (t *Tokenizer) parseCSI(startBytePos int, startRunePos int) {
 	t.pos++
 
 	token := types.Token{
-		Type:       types.TokenCSI,
-		Pos:        startRunePos,
-		Raw:        string(t.input[startBytePos:t.pos]),
-		Parameters: params,
+		Type:          types.TokenCSI,
+		Pos:           startRunePos,
+		Raw:           string(t.input[startBytePos:t.pos]),
+		Parameters:    params,
+		Prefix:        prefix,
+		Intermediates: intermediates,
 	}
 
 	// if final is C0 control character, the sequence is invalid/interrupted
 	if final < 0x20 {
 		token.Type = types.TokenCSIInterupted
 		token.CSINotation = fmt.Sprintf("CSI interrupted by C0 control (0x%02X)", final)
+
+		if t.RecoverMode {
+			// Give the C0 control back so it is parsed as its own token
+			t.pos--
+			token.Raw = string(t.input[startBytePos:t.pos])
+			t.badBytes += t.pos - startBytePos
+			if t.Stats.PosFirstBadSequence == 0 {
+				t.Stats.PosFirstBadSequence = int64(t.pos + 1)
+			}
+		} else {
+			t.Stats.PosFirstBadSequence = int64(t.pos)
+		}
+
 		t.Tokens = append(t.Tokens, token)
-		t.Stats.PosFirstBadSequence = int64(t.pos)
 		t.runePos += (t.pos - startBytePos)
 		return
 	}
@@ -232,6 +342,24 @@ func (t *Tokenizer) parseCSI(startBytePos int, startRunePos int) {
 			}
 			token.Signification = fmt.Sprintf("Cursor Left %d times", number)
 		}
+	case 'E':
+		{
+			token.CSINotation = "CSI Ps E"
+			number := 1
+			if len(params) > 0 {
+				number = ParseNumberParam(params[0], 1)
+			}
+			token.Signification = fmt.Sprintf("Cursor Next Line %d times", number)
+		}
+	case 'F':
+		{
+			token.CSINotation = "CSI Ps F"
+			number := 1
+			if len(params) > 0 {
+				number = ParseNumberParam(params[0], 1)
+			}
+			token.Signification = fmt.Sprintf("Cursor Previous Line %d times", number)
+		}
 	case 'H':
 		// ESC [ H 	Moves the cursor to line 1, column 1 (Home).
 		// ESC [ 6 H 	Moves the cursor to line 6, column 1.
@@ -240,7 +368,7 @@ func (t *Tokenizer) parseCSI(startBytePos int, startRunePos int) {
 		// ESC [ 99 ; 99 H 	Moves the cursor to end of Page.
 		{
 			token.CSINotation = "CSI Ps H"
-			numbers := ParseDoubleNumbersParam(params, []int{1, 1})
+			numbers := types.ParseDoubleNumbersParam(params, []int{1, 1})
 			token.Signification = fmt.Sprintf("Cursor Position %d", numbers)
 		}
 	case 'J':
@@ -248,6 +376,15 @@ func (t *Tokenizer) parseCSI(startBytePos int, startRunePos int) {
 			token.CSINotation = "CSI Ps J"
 			token.Signification = strings.Join(ParseEDParams(params), ", ")
 		}
+	case 'X':
+		{
+			token.CSINotation = "CSI Ps X"
+			number := 1
+			if len(params) > 0 {
+				number = ParseNumberParam(params[0], 1)
+			}
+			token.Signification = fmt.Sprintf("Erase %d characters", number)
+		}
 	case 'b':
 		{
 			token.CSINotation = "CSI Ps b"
@@ -257,6 +394,63 @@ func (t *Tokenizer) parseCSI(startBytePos int, startRunePos int) {
 			}
 			token.Signification = fmt.Sprintf("Repeat previous character %d times", number)
 		}
+	case 'h', 'l':
+		{
+			action := "Set"
+			if final == 'l' {
+				action = "Reset"
+			}
+			token.CSINotation = fmt.Sprintf("CSI %sPm %c", prefix, final)
+			if prefix == "?" {
+				token.Signification = fmt.Sprintf("DEC Private Mode %s %s", action, strings.Join(params, ", "))
+			} else {
+				token.Signification = fmt.Sprintf("%s Mode %s", action, strings.Join(params, ", "))
+			}
+		}
+	case 'g':
+		{
+			token.CSINotation = "CSI Ps g"
+			mode := 0
+			if len(params) > 0 {
+				mode = ParseNumberParam(params[0], 0)
+			}
+			if mode == 3 {
+				token.Signification = "Clear all tab stops"
+			} else {
+				token.Signification = "Clear tab stop at cursor"
+			}
+		}
+	case 'n':
+		{
+			token.CSINotation = fmt.Sprintf("CSI %sPs n", prefix)
+			mode := 0
+			if len(params) > 0 {
+				mode = ParseNumberParam(params[0], 0)
+			}
+			switch mode {
+			case 5:
+				token.Signification = "Device Status Report request"
+			case 6:
+				token.Signification = "Cursor Position Report request"
+			default:
+				token.Signification = fmt.Sprintf("Device Status Report %d", mode)
+			}
+			if prefix == "?" {
+				token.Signification = "DEC " + token.Signification
+			}
+		}
+	case 'c':
+		{
+			token.CSINotation = fmt.Sprintf("CSI %sPs c", prefix)
+			switch prefix {
+			case ">":
+				token.Signification = "Secondary Device Attributes request"
+			case "=":
+				token.Signification = "Tertiary Device Attributes request"
+			default:
+				token.Signification = "Primary Device Attributes request"
+			}
+		}
 	case 's':
 		{
 			token.CSINotation = "CSI s"
@@ -269,6 +463,11 @@ func (t *Tokenizer) parseCSI(startBytePos int, startRunePos int) {
 		}
 	case 'm':
 		{
+			// Private sequences (e.g. ESC[>4;1m) are not SGR
+			if prefix != "" {
+				token.Type = types.TokenUnknown
+				break
+			}
 			token.Type = types.TokenSGR
 			token.CSINotation = "CSI Ps... m"
 		}
@@ -291,13 +490,14 @@ func (t *Tokenizer) parseDCS(startBytePos int, startRunePos int) {
 			t.pos += 2
 			break
 		}
-		if t.input[t.pos] == 0x9C {
-			// Trouvé ST (8-bit)
-			t.pos++
+		// The C1 ST is a whole rune, its 0x9C byte also ends other runes
+		r, size := utf8.DecodeRune(t.input[t.pos:])
+		if r == 0x9C {
+			t.pos += size
 			break
 		}
-		data = append(data, t.input[t.pos])
-		t.pos++
+		data = append(data, t.input[t.pos:t.pos+size]...)
+		t.pos += size
 	}
 
 	t.Tokens = append(t.Tokens, types.Token{
@@ -306,7 +506,44 @@ func (t *Tokenizer) parseDCS(startBytePos int, startRunePos int) {
 		Raw:   string(t.input[startBytePos:t.pos]),
 		Value: string(data),
 	})
-	t.runePos += (t.pos - startBytePos)
+	t.runePos += utf8.RuneCount(t.input[startBytePos:t.pos])
+}
+
+// parseAPC consumes an APC string up to ST (ESC \ or U+009C). Its payload,
+// a Kitty graphics command when it starts with "G", is kept as is.
+func (t *Tokenizer) parseAPC(startBytePos int, startRunePos int) {
+	dataStart := t.pos
+	dataEnd := len(t.input)
+	for t.pos < len(t.input) {
+		if t.input[t.pos] == 0x1B && t.pos+1 < len(t.input) && t.input[t.pos+1] == '\\' {
+			dataEnd = t.pos
+			t.pos += 2
+			break
+		}
+		// The C1 ST is a whole rune, its 0x9C byte also ends other runes
+		r, size := utf8.DecodeRune(t.input[t.pos:])
+		if r == 0x9C {
+			dataEnd = t.pos
+			t.pos += size
+			break
+		}
+		t.pos += size
+	}
+
+	payload := string(t.input[dataStart:dataEnd])
+	signification := "Application Program Command"
+	if strings.HasPrefix(payload, "G") {
+		signification = "Kitty graphics"
+	}
+
+	t.Tokens = append(t.Tokens, types.Token{
+		Type:          types.TokenAPC,
+		Pos:           startRunePos,
+		Raw:           string(t.input[startBytePos:t.pos]),
+		Value:         payload,
+		Signification: signification,
+	})
+	t.runePos += utf8.RuneCount(t.input[startBytePos:t.pos])
 }
 
 func (t *Tokenizer) parseOSC(startBytePos int, startRunePos int) {
@@ -320,12 +557,14 @@ func (t *Tokenizer) parseOSC(startBytePos int, startRunePos int) {
 			t.pos += 2
 			break
 		}
-		if t.input[t.pos] == 0x9C {
-			t.pos++
+		// The C1 ST is a whole rune, its 0x9C byte also ends other runes
+		r, size := utf8.DecodeRune(t.input[t.pos:])
+		if r == 0x9C {
+			t.pos += size
 			break
 		}
-		data = append(data, t.input[t.pos])
-		t.pos++
+		data = append(data, t.input[t.pos:t.pos+size]...)
+		t.pos += size
 	}
 
 	parts := strings.SplitN(string(data), ";", 2)
@@ -337,14 +576,29 @@ func (t *Tokenizer) parseOSC(startBytePos int, startRunePos int) {
 		}
 	}
 
-	t.Tokens = append(t.Tokens, types.Token{
+	token := types.Token{
 		Type:       types.TokenOSC,
 		Pos:        startRunePos,
 		Raw:        string(t.input[startBytePos:t.pos]),
 		Value:      string(data),
 		Parameters: params,
-	})
-	t.runePos += (t.pos - startBytePos)
+	}
+
+	// Hyperlink: OSC 8 ; params ; URI ST, an empty URI closes the link
+	if len(parts) == 2 && parts[0] == "8" {
+		link := strings.SplitN(parts[1], ";", 2)
+		if len(link) == 2 {
+			token.Parameters = []string{"8", link[0], link[1]}
+			if link[1] == "" {
+				token.Signification = "Hyperlink end"
+			} else {
+				token.Signification = fmt.Sprintf("Hyperlink %s", link[1])
+			}
+		}
+	}
+
+	t.Tokens = append(t.Tokens, token)
+	t.runePos += utf8.RuneCount(t.input[startBytePos:t.pos])
 }
 
 func (t *Tokenizer) parseOtherEscape(startBytePos int, startRunePos int) {
@@ -377,13 +631,16 @@ func (t *Tokenizer) parseOtherEscape(startBytePos int, startRunePos int) {
 	t.runePos += (t.pos - startBytePos) // ASCII: 1 byte = 1 rune
 }
 
-func (t *Tokenizer) collectParams() []string {
+func (t *Tokenizer) collectParams() (params []string, prefix string, intermediates string) {
 	// [] == ESC [ H
 	// [6,1] == ESC [ 6 H
 	// [1,12]  == ESC [ ; 12 H
 	// [6,12] == ESC [ 6 ; 12 H
-	params := make([]string, 0)
+	// prefix "?" == ESC [ ? 25 h
+	// intermediates " " == ESC [ 2 SP q
+	params = make([]string, 0)
 	var current bytes.Buffer
+	var prefixBuf, intermediatesBuf bytes.Buffer
 
 	for t.pos < len(t.input) {
 		b := t.input[t.pos]
@@ -398,8 +655,13 @@ func (t *Tokenizer) collectParams() []string {
 				current.WriteByte(b)
 				t.pos++
 			}
-		} else if b == '?' || b == '>' || b == '!' || b == '$' || b == '\'' || b == '"' || b == ' ' {
-			// Ignore these characters in parameters
+		} else if b == '?' || b == '>' || b == '<' || b == '=' {
+			// Private parameter prefix (0x3C-0x3F)
+			prefixBuf.WriteByte(b)
+			t.pos++
+		} else if b >= 0x20 && b <= 0x2F {
+			// Intermediate bytes (space, !, ", $, ', ...)
+			intermediatesBuf.WriteByte(b)
 			t.pos++
 		} else {
 			// CSI or SGR Final byte or invalid character
@@ -411,17 +673,24 @@ func (t *Tokenizer) collectParams() []string {
 		params = append(params, current.String())
 	}
 
-	return params
+	return params, prefixBuf.String(), intermediatesBuf.String()
 }
 
 func (t *Tokenizer) parseText(startByte int, startRune int) {
 	for t.pos < len(t.input) {
 		b := t.input[t.pos]
 
-		if b < 0x20 {
+		if b < 0x20 || (b == 0x7F && t.DeleteAsControl) {
 			break
 		}
 
+		// ASCII fast path, one byte is one rune
+		if b < utf8.RuneSelf {
+			t.pos++
+			t.runePos++
+			continue
+		}
+
 		_, size := utf8.DecodeRune(t.input[t.pos:])
 		t.pos += size
 		t.runePos++ // Incrémente la position en runes
@@ -438,32 +707,192 @@ func (t *Tokenizer) parseText(startByte int, startRune int) {
 	}
 }
 
-func (t *Tokenizer) calculateStats() {
-	t.Stats.TotalTokens = len(t.Tokens)
+// ForEach tokenizes the input lazily, calling fn for each token without
+// accumulating them in Tokens. It stops at the first error returned by fn.
+// Statistics are updated as tokens are produced, so GetStats works afterward.
+func (t *Tokenizer) ForEach(fn func(types.Token) error) error {
+	for t.pos < len(t.input) {
+		t.nextToken()
+
+		token := t.Tokens[len(t.Tokens)-1]
+		t.Tokens = t.Tokens[:len(t.Tokens)-1]
+		t.accumulateStats(token)
+
+		// Stop if parsing was interrupted by bad CSI
+		if !t.RecoverMode && token.Type == types.TokenCSIInterupted {
+			t.Stats.ParsedPercent = float64(t.Stats.PosFirstBadSequence) / float64(t.Stats.FileSize) * 100
+			return fn(token)
+		}
 
+		if err := fn(token); err != nil {
+			return err
+		}
+	}
+
+	t.Stats.ParsedPercent = t.coveragePercent()
+
+	return nil
+}
+
+// coveragePercent returns the percentage of input bytes that were not part
+// of an interrupted sequence
+func (t *Tokenizer) coveragePercent() float64 {
+	if t.badBytes == 0 || t.Stats.FileSize == 0 {
+		return 100
+	}
+	return float64(t.Stats.FileSize-int64(t.badBytes)) / float64(t.Stats.FileSize) * 100
+}
+
+func (t *Tokenizer) calculateStats() {
 	for _, token := range t.Tokens {
-		t.Stats.TokensByType[token.Type]++
+		t.accumulateStats(token)
+	}
+}
 
-		switch token.Type {
-		case types.TokenText:
-			t.Stats.TotalTextLength += len(token.Value)
+func (t *Tokenizer) accumulateStats(token types.Token) {
+	t.Stats.TotalTokens++
+	t.Stats.TokensByType[token.Type]++
 
-		case types.TokenSGR:
-			for _, param := range token.Parameters {
-				t.Stats.SGRCodes[param]++
-			}
+	// Like in the virtual terminal, controls and cursor moves see the
+	// wrapped cursor
+	if t.statsWrap && (token.Type == types.TokenC0 || token.Type == types.TokenCSI) {
+		t.wrapStats()
+	}
+
+	switch token.Type {
+	case types.TokenText:
+		t.Stats.TotalTextLength += len(token.Value)
 
-		case types.TokenCSI:
-			if token.CSINotation != "" {
-				t.Stats.CSISequences[token.CSINotation]++
+		cells := 0
+		for _, r := range token.Value {
+			// Combining marks attach to the previous char
+			if unicode.In(r, unicode.Mn, unicode.Me) {
+				continue
 			}
+			width := processor.RuneWidth(r)
+			t.advanceStats(width)
+			cells += width
+		}
+
+		fg, bg := t.statsSGR.DisplayedColors()
+		t.Stats.ColorUsage["fg:"+fg.String()] += cells
+		t.Stats.ColorUsage["bg:"+bg.String()] += cells
 
-		case types.TokenC0:
-			t.Stats.C0Codes[token.C0Code]++
+	case types.TokenSGR:
+		for _, param := range token.Parameters {
+			t.Stats.SGRCodes[param]++
+		}
+		t.statsSGR = token.ToSGR(t.statsSGR)
+
+	case types.TokenCSI:
+		if token.CSINotation != "" {
+			t.Stats.CSISequences[token.CSINotation]++
+		}
+		t.moveStatsCursor(token)
+
+	case types.TokenC0:
+		t.Stats.C0Codes[token.C0Code]++
 
-		case types.TokenC1:
-			t.Stats.C1Codes[token.C1Code]++
+		// The CR LF ending a line that just wrapped does not start another
+		if t.statsWrapped && token.C0Code == 0x0D {
+			break
+		}
+		if t.statsWrapped && token.C0Code == 0x0A {
+			t.statsWrapped = false
+			break
 		}
+		t.statsWrapped = false
+
+		switch token.C0Code {
+		case 0x08: // BS
+			t.statsX = max(t.statsX-1, 0)
+		case 0x09: // TAB
+			tab := t.tabWidth()
+			t.statsX = min((t.statsX/tab+1)*tab, t.screenWidth()-1)
+		case 0x0A: // LF, also a CR like in the virtual terminal
+			t.statsX = 0
+			t.statsY++
+		case 0x0B, 0x0C: // VT, FF
+			t.statsY++
+		case 0x0D: // CR
+			t.statsX = 0
+		}
+
+	case types.TokenC1:
+		t.Stats.C1Codes[token.C1Code]++
+	}
+}
+
+// screenWidth returns ScreenWidth, at least one column
+func (t *Tokenizer) screenWidth() int {
+	return max(t.ScreenWidth, 1)
+}
+
+// tabWidth returns TabWidth, 8 columns when it is not set
+func (t *Tokenizer) tabWidth() int {
+	if t.TabWidth < 1 {
+		return DefaultTabWidth
+	}
+	return t.TabWidth
+}
+
+// advanceStats writes a char of the given cells at the stats cursor,
+// wrapping at ScreenWidth
+func (t *Tokenizer) advanceStats(cells int) {
+	width := t.screenWidth()
+	cells = min(cells, width)
+
+	// A wide char does not fit on the last column
+	if t.statsWrap || t.statsX+cells > width {
+		t.wrapStats()
+	}
+
+	t.statsX += cells
+	t.statsWrap = t.statsX >= width
+	t.statsWrapped = false
+	t.Stats.LineCount = max(t.Stats.LineCount, t.statsY+1)
+	t.Stats.MaxLineWidth = max(t.Stats.MaxLineWidth, t.statsX)
+}
+
+// wrapStats moves the stats cursor to the start of the next line
+func (t *Tokenizer) wrapStats() {
+	t.statsX = 0
+	t.statsY++
+	t.statsWrap = false
+	t.statsWrapped = true
+}
+
+// moveStatsCursor follows the cursor moves of a CSI sequence, the column
+// stays on the screen
+func (t *Tokenizer) moveStatsCursor(token types.Token) {
+	if token.Raw == "" {
+		return
+	}
+
+	n := 1
+	if len(token.Parameters) > 0 {
+		n = max(ParseNumberParam(token.Parameters[0], 1), 1)
+	}
+
+	switch token.Raw[len(token.Raw)-1] {
+	case 'A':
+		t.statsY = max(t.statsY-n, 0)
+	case 'B':
+		t.statsY += n
+	case 'C':
+		t.statsX = min(t.statsX+n, t.screenWidth()-1)
+	case 'D':
+		t.statsX = max(t.statsX-n, 0)
+	case 'E':
+		t.statsX = 0
+		t.statsY += n
+	case 'F':
+		t.statsX = 0
+		t.statsY = max(t.statsY-n, 0)
+	case 'H', 'f':
+		position := types.ParseDoubleNumbersParam(token.Parameters, []int{1, 1})
+		t.statsY = max(position[0]-1, 0)
+		t.statsX = min(max(position[1]-1, 0), t.screenWidth()-1)
 	}
 }
 
@@ -530,6 +959,133 @@ func ParseSGRParams(params []string) []string {
 	return result
 }
 
+// SGROpKind is the kind of change made by an SGR operation
+type SGROpKind int
+
+const (
+	SGROpReset          SGROpKind = iota // 0 or an empty parameter
+	SGROpAttributeOn                     // 1-9, 21, 53, 73, 74
+	SGROpAttributeOff                    // 22-29, 55, 75
+	SGROpForeground                      // 30-39, 90-97
+	SGROpBackground                      // 40-49, 100-107
+	SGROpUnderlineColor                  // 58, 59
+	SGROpUnknown                         // Unknown code or malformed parameters
+)
+
+// SGROp is one operation of an SGR sequence, a typed view of what
+// ParseSGRParams describes
+type SGROp struct {
+	Kind  SGROpKind
+	Code  int              // SGR code, -1 when the parameter is not a number
+	Name  string           // Name of the code in SGRCodes, empty for extended colors
+	Color types.ColorValue // Color of the color kinds, ColorDefault for 39, 49 and 59
+	Raw   []string         // Parameters consumed by the operation
+}
+
+// ParseSGRStructured returns the operations of the SGR parameters, extended
+// colors (38;5;n, 38;2;r;g;b, 38;6;r;g;b;a and the 48/58 variants) being a
+// single operation.
+func ParseSGRStructured(params []string) []SGROp {
+	ops := make([]SGROp, 0, len(params))
+
+	for i := 0; i < len(params); i++ {
+		if params[i] == "" {
+			ops = append(ops, SGROp{Kind: SGROpReset, Name: SGRCodes[0], Raw: params[i : i+1]})
+			continue
+		}
+
+		code, err := strconv.Atoi(params[i])
+		if err != nil {
+			ops = append(ops, SGROp{Kind: SGROpUnknown, Code: -1, Raw: params[i : i+1]})
+			continue
+		}
+
+		op := SGROp{Code: code, Name: SGRCodes[code], Raw: params[i : i+1]}
+		switch {
+		case code == 0:
+			op.Kind = SGROpReset
+		case code >= 1 && code <= 9, code == 21, code == 53, code == 73, code == 74:
+			op.Kind = SGROpAttributeOn
+		case code >= 22 && code <= 29 && code != 26, code == 55, code == 75:
+			op.Kind = SGROpAttributeOff
+		case code >= 30 && code <= 37:
+			op.Kind = SGROpForeground
+			op.Color = types.ColorValue{Type: types.ColorStandard, Index: uint8(code - 30)}
+		case code >= 90 && code <= 97:
+			op.Kind = SGROpForeground
+			op.Color = types.ColorValue{Type: types.ColorStandard, Index: uint8(code - 90 + 8)}
+		case code >= 40 && code <= 47:
+			op.Kind = SGROpBackground
+			op.Color = types.ColorValue{Type: types.ColorStandard, Index: uint8(code - 40)}
+		case code >= 100 && code <= 107:
+			op.Kind = SGROpBackground
+			op.Color = types.ColorValue{Type: types.ColorStandard, Index: uint8(code - 100 + 8)}
+		case code == 38, code == 39:
+			op.Kind = SGROpForeground
+		case code == 48, code == 49:
+			op.Kind = SGROpBackground
+		case code == 58, code == 59:
+			op.Kind = SGROpUnderlineColor
+		default:
+			op.Kind = SGROpUnknown
+		}
+
+		if code == 38 || code == 48 || code == 58 {
+			color, n := parseExtendedColor(params[i+1:])
+			if n == 0 {
+				op.Kind = SGROpUnknown
+			}
+			op.Color = color
+			op.Raw = params[i : i+1+n]
+			i += n
+		}
+
+		ops = append(ops, op)
+	}
+
+	return ops
+}
+
+// parseExtendedColor parses the parameters following 38, 48 or 58 and
+// returns the color and the number of parameters used, 0 when malformed
+func parseExtendedColor(params []string) (types.ColorValue, int) {
+	if len(params) == 0 {
+		return types.ColorValue{}, 0
+	}
+
+	var length int
+	switch params[0] {
+	case "5":
+		length = 2
+	case "2":
+		length = 4
+	case "6":
+		length = 5
+	default:
+		return types.ColorValue{}, 0
+	}
+	if len(params) < length {
+		return types.ColorValue{}, 0
+	}
+
+	values := make([]uint8, length-1)
+	for j := range values {
+		v, err := strconv.Atoi(params[j+1])
+		if err != nil || v < 0 || v > 255 {
+			return types.ColorValue{}, 0
+		}
+		values[j] = uint8(v)
+	}
+
+	switch length {
+	case 2:
+		return types.ColorValue{Type: types.ColorIndexed, Index: values[0]}, length
+	case 4:
+		return types.ColorValue{Type: types.ColorRGB, R: values[0], G: values[1], B: values[2], A: 255}, length
+	}
+	return types.ColorValue{Type: types.ColorRGB, R: values[0], G: values[1], B: values[2], A: values[3], HasAlpha: true}, length
+}
+
 func ParseEDParams(params []string) []string {
 	result := make([]string, 0)
 
@@ -571,21 +1127,6 @@ func ParseNumberParam(param string, defaultValue int) int {
 	return num
 }
 
-func ParseDoubleNumbersParam(params []string, defaultValue []int) []int {
-	result := defaultValue
-
-	for i := 0; i < len(params); i++ {
-		num, err := strconv.Atoi(params[i])
-		if err != nil {
-			return defaultValue
-		}
-
-		result[i] = num
-	}
-
-	return result
-}
-
 // GetStats retourne les statistiques de tokenization
 func (t *Tokenizer) GetStats() types.TokenStats {
 	return t.Stats
@@ -1,7 +1,11 @@
 package ansi
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/badele/splitans/internal/types"
@@ -131,6 +135,12 @@ func TestTokenizeOSC(t *testing.T) {
 		{"WindowTitle", "\x1b]2;My Title\x07", []string{"2", "My Title"}},
 		{"IconTitle", "\x1b]1;Icon\x1b\\", []string{"1", "Icon"}},
 		{"Both", "\x1b]0;Title\x07", []string{"0", "Title"}},
+		{"HyperlinkOpen", "\x1b]8;;https://example.com\x1b\\", []string{"8", "", "https://example.com"}},
+		{"HyperlinkClose", "\x1b]8;;\x1b\\", []string{"8", "", ""}},
+		{"HyperlinkWithID", "\x1b]8;id=xyz123;https://example.com/a;b\x07", []string{"8", "id=xyz123", "https://example.com/a;b"}},
+		{"NonASCIITitle", "\x1b]2;Über alles\x07", []string{"2", "Über alles"}},
+		{"NonASCIIHyperlink", "\x1b]8;;https://example.com/Übersicht\x1b\\", []string{"8", "", "https://example.com/Übersicht"}},
+		{"C1StringTerminator", "\x1b]2;霜\u009c", []string{"2", "霜"}},
 	}
 
 	for _, tt := range tests {
@@ -153,6 +163,32 @@ func TestTokenizeOSC(t *testing.T) {
 	}
 }
 
+func TestTokenizeOSCHyperlinkSignification(t *testing.T) {
+	tokens := NewANSITokenizer([]byte("\x1b]8;;https://example.com\x07link\x1b]8;;\x07")).Tokenize()
+
+	if len(tokens) != 3 {
+		t.Fatalf("Expected 3 tokens, got %d", len(tokens))
+	}
+
+	if tokens[0].Signification != "Hyperlink https://example.com" {
+		t.Errorf("Unexpected opening signification %q", tokens[0].Signification)
+	}
+	if tokens[2].Signification != "Hyperlink end" {
+		t.Errorf("Unexpected closing signification %q", tokens[2].Signification)
+	}
+}
+
+func TestTokenizeOSCNonASCIIPositions(t *testing.T) {
+	tokens := NewANSITokenizer([]byte("\x1b]2;Über\x07Hi")).Tokenize()
+
+	if len(tokens) != 2 {
+		t.Fatalf("Expected 2 tokens, got %d: %v", len(tokens), tokens)
+	}
+	if tokens[1].Type != types.TokenText || tokens[1].Value != "Hi" || tokens[1].Pos != 9 {
+		t.Errorf("Expected the text \"Hi\" at rune 9, got %v", tokens[1])
+	}
+}
+
 func TestTokenizeDCS(t *testing.T) {
 	input := "\x1bP1$qm\x1b\\"
 	tokenizer := NewANSITokenizer([]byte(input))
@@ -169,6 +205,55 @@ func TestTokenizeDCS(t *testing.T) {
 	if tokens[0].Value != "1$qm" {
 		t.Errorf("Expected value '1$qm', got %q", tokens[0].Value)
 	}
+
+	// "Ü" holds a 0x9C byte, only the U+009C rune ends the DCS
+	tokens = NewANSITokenizer([]byte("\x1bPÜ\u009cHi")).Tokenize()
+	if len(tokens) != 2 || tokens[0].Value != "Ü" || tokens[1].Value != "Hi" || tokens[1].Pos != 4 {
+		t.Errorf("Expected the DCS to end at the C1 ST, got %v", tokens)
+	}
+}
+
+func TestTokenizeAPC(t *testing.T) {
+	payload := "Ga=T,f=100,m=0;iVBORw0KGgo="
+	input := "ab\x1b_" + payload + "\x1b\\cd"
+	tokenizer := NewANSITokenizer([]byte(input))
+	tokens := tokenizer.Tokenize()
+
+	if len(tokens) != 3 {
+		t.Fatalf("Expected 3 tokens, got %d: %v", len(tokens), tokens)
+	}
+
+	if tokens[0].Value != "ab" || tokens[2].Value != "cd" {
+		t.Errorf("Expected text around the APC, got %q and %q", tokens[0].Value, tokens[2].Value)
+	}
+
+	apc := tokens[1]
+	if apc.Type != types.TokenAPC {
+		t.Fatalf("Expected types.TokenAPC, got %v", apc.Type)
+	}
+	if apc.Value != payload {
+		t.Errorf("Expected payload %q, got %q", payload, apc.Value)
+	}
+	if apc.Raw != "\x1b_"+payload+"\x1b\\" {
+		t.Errorf("Unexpected raw %q", apc.Raw)
+	}
+	if apc.Signification != "Kitty graphics" {
+		t.Errorf("Unexpected signification %q", apc.Signification)
+	}
+	if tokens[2].Pos != len(input)-2 {
+		t.Errorf("Expected trailing text at %d, got %d", len(input)-2, tokens[2].Pos)
+	}
+
+	// "蜜" holds a 0x9C byte, only the U+009C rune ends the APC
+	c1 := NewANSITokenizer([]byte("\x1b_G蜜\u009cé")).Tokenize()
+	if len(c1) != 2 || c1[0].Value != "G蜜" || c1[1].Value != "é" || c1[1].Pos != 5 {
+		t.Errorf("Expected the APC to end at the C1 ST, got %v", c1)
+	}
+
+	unterminated := NewANSITokenizer([]byte("\x1b_Gi=1")).Tokenize()
+	if len(unterminated) != 1 || unterminated[0].Type != types.TokenAPC || unterminated[0].Value != "Gi=1" {
+		t.Errorf("Expected an APC token up to the end of input, got %v", unterminated)
+	}
 }
 
 func TestTokenizeC1(t *testing.T) {
@@ -281,6 +366,66 @@ func TestParseSGRParams(t *testing.T) {
 	}
 }
 
+func TestParseSGRStructured(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   []string
+		expected []SGROp
+	}{
+		{
+			name:   "Bold",
+			params: []string{"1", "22"},
+			expected: []SGROp{
+				{Kind: SGROpAttributeOn, Code: 1, Name: "Bold", Raw: []string{"1"}},
+				{Kind: SGROpAttributeOff, Code: 22, Name: "NormalIntensity", Raw: []string{"22"}},
+			},
+		},
+		{
+			name:   "Standard and indexed",
+			params: []string{"91", "48", "5", "123"},
+			expected: []SGROp{
+				{Kind: SGROpForeground, Code: 91, Name: "ForegroundBrightRed", Color: types.ColorValue{Type: types.ColorStandard, Index: 9}, Raw: []string{"91"}},
+				{Kind: SGROpBackground, Code: 48, Color: types.ColorValue{Type: types.ColorIndexed, Index: 123}, Raw: []string{"48", "5", "123"}},
+			},
+		},
+		{
+			name:   "RGB",
+			params: []string{"38", "2", "255", "100", "50", "0"},
+			expected: []SGROp{
+				{Kind: SGROpForeground, Code: 38, Color: types.ColorValue{Type: types.ColorRGB, R: 255, G: 100, B: 50, A: 255}, Raw: []string{"38", "2", "255", "100", "50"}},
+				{Kind: SGROpReset, Code: 0, Name: "Reset", Raw: []string{"0"}},
+			},
+		},
+		{
+			name:   "Underline color",
+			params: []string{"4", "58", "6", "1", "2", "3", "128", "59"},
+			expected: []SGROp{
+				{Kind: SGROpAttributeOn, Code: 4, Name: "Underline", Raw: []string{"4"}},
+				{Kind: SGROpUnderlineColor, Code: 58, Color: types.ColorValue{Type: types.ColorRGB, R: 1, G: 2, B: 3, A: 128, HasAlpha: true}, Raw: []string{"58", "6", "1", "2", "3", "128"}},
+				{Kind: SGROpUnderlineColor, Code: 59, Name: "UnderlineColorDefault", Raw: []string{"59"}},
+			},
+		},
+		{
+			name:   "Truncated extended color",
+			params: []string{"38", "2", "255"},
+			expected: []SGROp{
+				{Kind: SGROpUnknown, Code: 38, Raw: []string{"38"}},
+				{Kind: SGROpAttributeOn, Code: 2, Name: "Dim", Raw: []string{"2"}},
+				{Kind: SGROpUnknown, Code: 255, Raw: []string{"255"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ParseSGRStructured(tt.params)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, result)
+			}
+		})
+	}
+}
+
 func TestParseEDParams(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -342,43 +487,6 @@ func TestParseNumberParam(t *testing.T) {
 	}
 }
 
-func TestParseDoubleNumbersParam(t *testing.T) {
-	tests := []struct {
-		name         string
-		params       []string
-		defaultValue []int
-		expected     []int
-	}{
-		{
-			name:         "Two valid numbers",
-			params:       []string{"10", "5"},
-			defaultValue: []int{1, 1},
-			expected:     []int{10, 5},
-		},
-		{
-			name:         "Empty params returns default",
-			params:       []string{},
-			defaultValue: []int{1, 1},
-			expected:     []int{1, 1},
-		},
-		{
-			name:         "Invalid number returns default",
-			params:       []string{"abc", "5"},
-			defaultValue: []int{1, 1},
-			expected:     []int{1, 1},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			result := ParseDoubleNumbersParam(tt.params, tt.defaultValue)
-			if !reflect.DeepEqual(result, tt.expected) {
-				t.Errorf("Expected %v, got %v", tt.expected, result)
-			}
-		})
-	}
-}
-
 func TestCSIWithSignification(t *testing.T) {
 	tests := []struct {
 		name                  string
@@ -394,6 +502,41 @@ func TestCSIWithSignification(t *testing.T) {
 			expectedNotation:      "CSI Ps A",
 			expectedSignification: "Cursor Up 5 times",
 		},
+		{
+			name:                  "Cursor Position Report request",
+			input:                 "\x1b[6n",
+			expectedType:          types.TokenCSI,
+			expectedNotation:      "CSI Ps n",
+			expectedSignification: "Cursor Position Report request",
+		},
+		{
+			name:                  "DEC Cursor Position Report request",
+			input:                 "\x1b[?6n",
+			expectedType:          types.TokenCSI,
+			expectedNotation:      "CSI ?Ps n",
+			expectedSignification: "DEC Cursor Position Report request",
+		},
+		{
+			name:                  "Primary Device Attributes request",
+			input:                 "\x1b[0c",
+			expectedType:          types.TokenCSI,
+			expectedNotation:      "CSI Ps c",
+			expectedSignification: "Primary Device Attributes request",
+		},
+		{
+			name:                  "Tab Clear all",
+			input:                 "\x1b[3g",
+			expectedType:          types.TokenCSI,
+			expectedNotation:      "CSI Ps g",
+			expectedSignification: "Clear all tab stops",
+		},
+		{
+			name:                  "Tab Clear at cursor",
+			input:                 "\x1b[g",
+			expectedType:          types.TokenCSI,
+			expectedNotation:      "CSI Ps g",
+			expectedSignification: "Clear tab stop at cursor",
+		},
 		{
 			name:                  "Cursor Down",
 			input:                 "\x1b[3B",
@@ -415,6 +558,20 @@ func TestCSIWithSignification(t *testing.T) {
 			expectedNotation:      "CSI Ps D",
 			expectedSignification: "Cursor Left 4 times",
 		},
+		{
+			name:                  "Cursor Next Line",
+			input:                 "\x1b[2E",
+			expectedType:          types.TokenCSI,
+			expectedNotation:      "CSI Ps E",
+			expectedSignification: "Cursor Next Line 2 times",
+		},
+		{
+			name:                  "Cursor Previous Line",
+			input:                 "\x1b[3F",
+			expectedType:          types.TokenCSI,
+			expectedNotation:      "CSI Ps F",
+			expectedSignification: "Cursor Previous Line 3 times",
+		},
 		{
 			name:                  "Erase Display",
 			input:                 "\x1b[2J",
@@ -422,6 +579,13 @@ func TestCSIWithSignification(t *testing.T) {
 			expectedNotation:      "CSI Ps J",
 			expectedSignification: "EraseAll",
 		},
+		{
+			name:                  "Erase Character",
+			input:                 "\x1b[3X",
+			expectedType:          types.TokenCSI,
+			expectedNotation:      "CSI Ps X",
+			expectedSignification: "Erase 3 characters",
+		},
 		{
 			name:                  "Save Cursor Position",
 			input:                 "\x1b[s",
@@ -621,6 +785,7 @@ func TestTokenTypeString(t *testing.T) {
 		{types.TokenOSC, "TokenOSC"},
 		{types.TokenEscape, "TokenEscape"},
 		{types.TokenUnknown, "TokenUnknown"},
+		{types.TokenAPC, "TokenAPC"},
 		{types.TokenType(999), "TokenType(999)"},
 	}
 
@@ -750,3 +915,342 @@ func TestTokenTypeUnmarshalJSON_Invalid(t *testing.T) {
 		t.Error("Expected error for invalid JSON")
 	}
 }
+
+func TestSGROverlineRoundTrip(t *testing.T) {
+	tokens := NewANSITokenizer([]byte("\x1b[53m")).Tokenize()
+	if len(tokens) != 1 || tokens[0].Type != types.TokenSGR {
+		t.Fatalf("Expected a single SGR token, got %v", tokens)
+	}
+
+	params := make([]int, 0, len(tokens[0].Parameters))
+	for _, p := range tokens[0].Parameters {
+		params = append(params, ParseNumberParam(p, 0))
+	}
+
+	sgr := types.NewSGR()
+	sgr.ApplyParams(params)
+	if !sgr.Overline {
+		t.Fatalf("Expected Overline to be set from %v", params)
+	}
+
+	expected := "\x1b[37;40;53m"
+	if got := sgr.ToANSI(false, false); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestForEachCountsTextTokens(t *testing.T) {
+	input := []byte("Hello\x1b[31mWorld\r\n\x1b[0mBye")
+	tokenizer := NewANSITokenizer(input)
+
+	textCount := 0
+	err := tokenizer.ForEach(func(token types.Token) error {
+		if token.Type == types.TokenText {
+			textCount++
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected ForEach error: %v", err)
+	}
+
+	if textCount != 3 {
+		t.Errorf("Expected 3 text tokens, got %d", textCount)
+	}
+
+	if len(tokenizer.Tokens) != 0 {
+		t.Errorf("Expected no accumulated tokens, got %d", len(tokenizer.Tokens))
+	}
+
+	stats := tokenizer.GetStats()
+	if stats.TotalTokens != 7 {
+		t.Errorf("Expected 7 total tokens in stats, got %d", stats.TotalTokens)
+	}
+	if stats.TokensByType[types.TokenText] != 3 {
+		t.Errorf("Expected 3 text tokens in stats, got %d", stats.TokensByType[types.TokenText])
+	}
+	if stats.ParsedPercent != 100 {
+		t.Errorf("Expected 100%% parsed, got %f", stats.ParsedPercent)
+	}
+}
+
+func TestForEachStopsOnError(t *testing.T) {
+	tokenizer := NewANSITokenizer([]byte("A\nB\nC"))
+	stopErr := errors.New("stop")
+
+	seen := 0
+	err := tokenizer.ForEach(func(token types.Token) error {
+		seen++
+		if token.Type == types.TokenC0 {
+			return stopErr
+		}
+		return nil
+	})
+
+	if !errors.Is(err, stopErr) {
+		t.Fatalf("Expected stop error, got %v", err)
+	}
+	if seen != 2 {
+		t.Errorf("Expected 2 tokens before stopping, got %d", seen)
+	}
+}
+
+func TestRecoverModeContinuesAfterInterruptedCSI(t *testing.T) {
+	input := []byte("AB\x1b[31\nCD\x1b[32mEF")
+	tokenizer := NewANSITokenizer(input)
+	tokenizer.RecoverMode = true
+	tokens := tokenizer.Tokenize()
+
+	expected := []struct {
+		typ types.TokenType
+		raw string
+	}{
+		{types.TokenText, "AB"},
+		{types.TokenCSIInterupted, "\x1b[31"},
+		{types.TokenC0, "\n"},
+		{types.TokenText, "CD"},
+		{types.TokenSGR, "\x1b[32m"},
+		{types.TokenText, "EF"},
+	}
+
+	if len(tokens) != len(expected) {
+		t.Fatalf("Expected %d tokens, got %d: %v", len(expected), len(tokens), tokens)
+	}
+
+	for i, e := range expected {
+		if tokens[i].Type != e.typ || tokens[i].Raw != e.raw {
+			t.Errorf("Token %d: expected %v %q, got %v %q", i, e.typ, e.raw, tokens[i].Type, tokens[i].Raw)
+		}
+	}
+
+	stats := tokenizer.GetStats()
+	if stats.PosFirstBadSequence != 7 {
+		t.Errorf("Expected first bad sequence at 7, got %d", stats.PosFirstBadSequence)
+	}
+
+	expectedPercent := float64(len(input)-4) / float64(len(input)) * 100
+	if stats.ParsedPercent != expectedPercent {
+		t.Errorf("Expected %f%% parsed, got %f", expectedPercent, stats.ParsedPercent)
+	}
+}
+
+func TestCSIPrefixAndIntermediates(t *testing.T) {
+	tests := []struct {
+		name                  string
+		input                 string
+		expectedType          types.TokenType
+		expectedParams        []string
+		expectedPrefix        string
+		expectedIntermediates string
+		expectedFinal         byte
+	}{
+		{"Hide cursor", "\x1b[?25l", types.TokenCSI, []string{"25"}, "?", "", 'l'},
+		{"Autowrap on", "\x1b[?7h", types.TokenCSI, []string{"7"}, "?", "", 'h'},
+		{"Insert mode", "\x1b[4h", types.TokenCSI, []string{"4"}, "", "", 'h'},
+		{"Cursor style", "\x1b[2 q", types.TokenUnknown, []string{"2"}, "", " ", 'q'},
+		{"Private m is not SGR", "\x1b[>4;1m", types.TokenUnknown, []string{"4", "1"}, ">", "", 'm'},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokens := NewANSITokenizer([]byte(tt.input)).Tokenize()
+
+			if len(tokens) != 1 {
+				t.Fatalf("Expected 1 token, got %d", len(tokens))
+			}
+
+			token := tokens[0]
+			if token.Type != tt.expectedType {
+				t.Errorf("Expected type %v, got %v", tt.expectedType, token.Type)
+			}
+			if !reflect.DeepEqual(token.Parameters, tt.expectedParams) {
+				t.Errorf("Expected params %v, got %v", tt.expectedParams, token.Parameters)
+			}
+			if token.Prefix != tt.expectedPrefix {
+				t.Errorf("Expected prefix %q, got %q", tt.expectedPrefix, token.Prefix)
+			}
+			if token.Intermediates != tt.expectedIntermediates {
+				t.Errorf("Expected intermediates %q, got %q", tt.expectedIntermediates, token.Intermediates)
+			}
+			if final := token.Raw[len(token.Raw)-1]; final != tt.expectedFinal {
+				t.Errorf("Expected final byte %q, got %q", tt.expectedFinal, final)
+			}
+		})
+	}
+}
+
+func TestColorUsageStats(t *testing.T) {
+	input := "\x1b[31mab\x1b[38;5;123mcde\x1b[38;2;255;0;128;44mf\x1b[0mgh"
+	tokenizer := NewANSITokenizer([]byte(input))
+	tokenizer.Tokenize()
+
+	expected := map[string]int{
+		"fg:std:1":          2,
+		"fg:idx:123":        3,
+		"fg:rgb(255,0,128)": 1,
+		"fg:std:7":          2,
+		"bg:std:0":          7,
+		"bg:std:4":          1,
+	}
+
+	if got := tokenizer.GetStats().ColorUsage; !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected color usage %v, got %v", expected, got)
+	}
+}
+
+func TestLineStats(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		screenWidth int
+		tabWidth    int
+		lineCount   int
+		maxWidth    int
+	}{
+		{name: "three lines", input: "abc\r\nde\r\n\x1b[31mfghij\x1b[0m\r\n", lineCount: 3, maxWidth: 5},
+		{name: "cursor moves", input: "ab\x1b[5;10Hc\x1b[2Ad", lineCount: 5, maxWidth: 11},
+		{name: "no text", input: "\r\n\r\n", lineCount: 0, maxWidth: 0},
+		{name: "autowrap", input: strings.Repeat("a", 240), lineCount: 3, maxWidth: 80},
+		{name: "full line then CRLF", input: strings.Repeat("a", 80) + "\r\nb", lineCount: 2, maxWidth: 80},
+		{name: "column kept on screen", input: "\x1b[1;200Hx", lineCount: 1, maxWidth: 80},
+		{name: "wide chars and combining marks", input: "漢字e\u0301", lineCount: 1, maxWidth: 5},
+		{name: "wide char past the last column", input: "abc漢", screenWidth: 4, lineCount: 2, maxWidth: 3},
+		{name: "narrow screen", input: strings.Repeat("a", 25), screenWidth: 10, lineCount: 3, maxWidth: 10},
+		{name: "default tab width", input: "a\tb", lineCount: 1, maxWidth: 9},
+		{name: "tab width", input: "a\tb", tabWidth: 4, lineCount: 1, maxWidth: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tokenizer := NewANSITokenizer([]byte(tt.input))
+			if tt.screenWidth > 0 {
+				tokenizer.ScreenWidth = tt.screenWidth
+			}
+			if tt.tabWidth > 0 {
+				tokenizer.TabWidth = tt.tabWidth
+			}
+			tokenizer.Tokenize()
+
+			stats := tokenizer.GetStats()
+			if stats.LineCount != tt.lineCount || stats.MaxLineWidth != tt.maxWidth {
+				t.Errorf("Expected %d lines of max width %d, got %d lines of max width %d",
+					tt.lineCount, tt.maxWidth, stats.LineCount, stats.MaxLineWidth)
+			}
+		})
+	}
+}
+
+func TestTokenizerReset(t *testing.T) {
+	inputs := []string{
+		"\x1b[1;31mred\x1b[0m\r\n\x1b[44mblue\x1b]0;title\x07",
+		"plain\x1b[2Jtext\x1b[38;5;196mX",
+	}
+
+	reused := NewANSITokenizer(nil)
+	var first []types.Token
+
+	for i, input := range inputs {
+		reused.Reset([]byte(input))
+		tokens := reused.Tokenize()
+		if i == 0 {
+			first = tokens
+		}
+
+		fresh := NewANSITokenizer([]byte(input))
+		want := fresh.Tokenize()
+
+		if !reflect.DeepEqual(tokens, want) {
+			t.Errorf("input %d: expected tokens %v, got %v", i, want, tokens)
+		}
+		if !reflect.DeepEqual(reused.GetStats(), fresh.GetStats()) {
+			t.Errorf("input %d: expected stats %+v, got %+v", i, fresh.GetStats(), reused.GetStats())
+		}
+	}
+
+	if first[0].Parameters[0] != "1" {
+		t.Errorf("Reset should leave the previous tokens untouched, got %v", first[0])
+	}
+}
+
+func TestTokenizeDeleteAsControl(t *testing.T) {
+	input := []byte("ab\x7fcd")
+
+	tokens := NewANSITokenizer(input).Tokenize()
+	if len(tokens) != 1 || tokens[0].Value != "ab\x7fcd" {
+		t.Fatalf("Expected DEL inside the text by default, got %v", tokens)
+	}
+
+	tokenizer := NewANSITokenizer(input)
+	tokenizer.DeleteAsControl = true
+	tokens = tokenizer.Tokenize()
+	if len(tokens) != 3 {
+		t.Fatalf("Expected 3 tokens, got %d: %v", len(tokens), tokens)
+	}
+	if tokens[1].Type != types.TokenC0 || tokens[1].C0Code != 0x7F || tokens[1].Pos != 2 {
+		t.Errorf("Expected a DEL control at 2, got %+v", tokens[1])
+	}
+	if tokens[0].Value != "ab" || tokens[2].Value != "cd" {
+		t.Errorf("Expected DEL dropped from the text, got %q and %q", tokens[0].Value, tokens[2].Value)
+	}
+}
+
+func TestTokenizeContextCancelled(t *testing.T) {
+	input := bytes.Repeat([]byte("\x1b[31mab\x1b[0m\r\n"), 10000)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tokens, err := NewANSITokenizer(input).TokenizeContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if len(tokens) >= 50000 {
+		t.Fatalf("Expected tokenization to stop early, got %d tokens", len(tokens))
+	}
+
+	tokens, err = NewANSITokenizer(input).TokenizeContext(context.Background())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(tokens) != 50000 {
+		t.Fatalf("Expected 50000 tokens, got %d", len(tokens))
+	}
+}
+
+func TestTokenizeMixedASCIIAndUTF8Text(t *testing.T) {
+	input := []byte("ab█é\xffcd\x1b[1m日本\r\nx")
+
+	expected := []types.Token{
+		{Type: types.TokenText, Pos: 0, Raw: "ab█é\xffcd", Value: "ab█é\xffcd"},
+		{Type: types.TokenSGR, Pos: 7},
+		{Type: types.TokenText, Pos: 11, Raw: "日本", Value: "日本"},
+		{Type: types.TokenC0, Pos: 13, C0Code: 0x0D},
+		{Type: types.TokenC0, Pos: 14, C0Code: 0x0A},
+		{Type: types.TokenText, Pos: 15, Raw: "x", Value: "x"},
+	}
+
+	tokens := NewANSITokenizer(input).Tokenize()
+	if len(tokens) != len(expected) {
+		t.Fatalf("Expected %d tokens, got %d: %v", len(expected), len(tokens), tokens)
+	}
+
+	for i, want := range expected {
+		got := tokens[i]
+		if got.Type != want.Type || got.Pos != want.Pos || got.Value != want.Value || got.C0Code != want.C0Code {
+			t.Errorf("Token %d: expected %v at %d, got %v at %d", i, want, want.Pos, got, got.Pos)
+		}
+		if want.Type == types.TokenText && got.Raw != want.Raw {
+			t.Errorf("Token %d: expected raw %q, got %q", i, want.Raw, got.Raw)
+		}
+	}
+}
+
+func BenchmarkTokenizeText(b *testing.B) {
+	line := []byte("The quick brown fox jumps over the lazy dog ░▒▓█ \x1b[1;31mred\x1b[0m\r\n")
+	input := bytes.Repeat(line, 10000)
+
+	b.SetBytes(int64(len(input)))
+	for b.Loop() {
+		NewANSITokenizer(input).Tokenize()
+	}
+}
@@ -0,0 +1,74 @@
+package neotex
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RewrapNeotex splits inline neotex (the output of ExportToInlineNeotex) into
+// lines of width runes, the inverse of the inline export. Sequence positions
+// are made relative to their new line, so a change at a wrap boundary starts
+// the next line. The !TW and !NL metadata are recomputed, other metadata is
+// kept on the first line. The last line is padded with spaces to width.
+func RewrapNeotex(text, sequences string, width int) (string, string, error) {
+	if width <= 0 {
+		return "", "", fmt.Errorf("invalid width %d", width)
+	}
+	if strings.Contains(text, "\n") || strings.Contains(sequences, "\n") {
+		return "", "", fmt.Errorf("neotex is not inline")
+	}
+
+	runes := []rune(text)
+	nbLines := max((len(runes)+width-1)/width, 1)
+	if pad := nbLines*width - len(runes); pad > 0 {
+		runes = append(runes, []rune(strings.Repeat(" ", pad))...)
+	}
+
+	textLines := make([]string, nbLines)
+	trimmedWidth := 0
+	for i := range textLines {
+		textLines[i] = string(runes[i*width : (i+1)*width])
+		trimmedWidth = max(trimmedWidth, len([]rune(strings.TrimRight(textLines[i], " "))))
+	}
+
+	var metadata []string
+	seqLines := make([][]string, nbLines)
+	for _, entry := range strings.Split(sequences, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if strings.HasPrefix(entry, "!") {
+			if !strings.HasPrefix(entry, "!TW") && !strings.HasPrefix(entry, "!NL") {
+				metadata = append(metadata, entry)
+			}
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 {
+			return "", "", fmt.Errorf("sequence %q: missing position", entry)
+		}
+		position, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil || position < 1 {
+			return "", "", fmt.Errorf("sequence %q: bad position", entry)
+		}
+
+		// Positions past the text stay on the last line
+		line := min((position-1)/width, nbLines-1)
+		column := position - line*width
+		seqLines[line] = append(seqLines[line], fmt.Sprintf("%d:%s", column, strings.TrimSpace(parts[1])))
+	}
+
+	metadata = append(metadata, fmt.Sprintf("!TW%d/%d", trimmedWidth, width), fmt.Sprintf("!NL%d", nbLines))
+	seqLines[0] = append(metadata, seqLines[0]...)
+
+	joined := make([]string, nbLines)
+	for i, entries := range seqLines {
+		joined[i] = strings.Join(entries, "; ")
+	}
+
+	return strings.Join(textLines, "\n"), strings.Join(joined, "\n"), nil
+}
@@ -6,7 +6,7 @@ package neotex
 //
 // Colors:
 //   Foreground colors = F<color>
-//   Background colors = B<color> (NOTE: no bright variants for background colors)
+//   Background colors = B<color> (bright backgrounds BK-BW are iCE colors)
 //   <color> lowercase = normal colors / uppercase = bright colors
 //   k/K = Black, r/R = Red, g/G = Green, y/Y = Yellow
 //   b/B = Blue, m/M = Magenta, c/C = Cyan, w/W = White
@@ -26,6 +26,7 @@ package neotex
 //   E<effect> uppercase = ON / lowercase = OFF
 //   M/m = Dim, I/i = Italic, U/u = Underline
 //   B/b = Blink, R/r = Reverse
+//   W/w = Double underline, O/o = Overline
 //   Note: Bold is handled by color case (e.g., Fr=normal, FR=bright)
 //
 // Special:
@@ -42,7 +43,6 @@ package neotex
 
 import (
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
 
@@ -50,6 +50,9 @@ import (
 	"github.com/badele/splitans/internal/types"
 )
 
+// DefaultSeparator sits between the text and the sequences of each line
+const DefaultSeparator = " | "
+
 type Tokenizer struct {
 	textLines []string         // Lignes de texte (sans \n)
 	seqLines  []string         // Lignes de séquences (sans \n)
@@ -114,27 +117,36 @@ var neotexToSGRModifier = map[string]NeotexSGRModifier{
 	"Eb": func(s *types.SGR) { s.Blink = false },
 	"ER": func(s *types.SGR) { s.Reverse = true },
 	"Er": func(s *types.SGR) { s.Reverse = false },
+	"EW": func(s *types.SGR) { s.DoubleUnderline = true },
+	"Ew": func(s *types.SGR) { s.DoubleUnderline = false },
+	"EO": func(s *types.SGR) { s.Overline = true },
+	"Eo": func(s *types.SGR) { s.Overline = false },
 }
 
 // ApplyNeotexCode applique un code neotex à un SGR
 // Gère les codes standards, RGB (FRRGGBB/BRRGGBB) et indexed (Fxxx/Bxxx)
 func ApplyNeotexCode(code string, sgr *types.SGR) {
+	applyNeotexCode(code, sgr)
+}
+
+// applyNeotexCode applies code to sgr and reports whether the code is known
+func applyNeotexCode(code string, sgr *types.SGR) bool {
 	// Vérifier d'abord la map des codes standards
 	if modifier, ok := neotexToSGRModifier[code]; ok {
 		modifier(sgr)
-		return
+		return true
 	}
 
 	// Gérer RGB: FRRGGBB ou BRRGGBB (7 chars)
 	if len(code) == 7 && (code[0] == 'F' || code[0] == 'B') {
 		if r, g, b, err := parseRGBHex(code[1:]); err == nil {
-			color := types.ColorValue{Type: types.ColorRGB, R: r, G: g, B: b}
+			color := types.ColorValue{Type: types.ColorRGB, R: r, G: g, B: b, A: 255}
 			if code[0] == 'F' {
 				sgr.FgColor = color
 			} else {
 				sgr.BgColor = color
 			}
-			return
+			return true
 		}
 	}
 
@@ -147,12 +159,24 @@ func ApplyNeotexCode(code string, sgr *types.SGR) {
 			} else {
 				sgr.BgColor = color
 			}
+			return true
 		}
 	}
+
+	return false
 }
 
-func NewNeotexTokenizer(data []byte, width int) (parsedWidth int, tokenizer *Tokenizer) {
-	parsedWidth, textLines, seqLines := SplitNeotexFormat(width, data)
+func NewNeotexTokenizer(data []byte, width int) (parsedWidth int, tokenizer *Tokenizer, err error) {
+	return NewNeotexTokenizerWithSeparator(data, width, DefaultSeparator)
+}
+
+// NewNeotexTokenizerWithSeparator is NewNeotexTokenizer for files using
+// another separator between text and sequences (DefaultSeparator when empty).
+func NewNeotexTokenizerWithSeparator(data []byte, width int, separator string) (parsedWidth int, tokenizer *Tokenizer, err error) {
+	parsedWidth, textLines, seqLines, err := SplitNeotexFormatWithSeparator(width, data, separator)
+	if err != nil {
+		return parsedWidth, nil, err
+	}
 
 	return parsedWidth, &Tokenizer{
 		textLines: textLines,
@@ -165,7 +189,7 @@ func NewNeotexTokenizer(data []byte, width int) (parsedWidth int, tokenizer *Tok
 			C0Codes:      make(map[byte]int),
 			C1Codes:      make(map[string]int),
 		},
-	}
+	}, nil
 }
 
 // parseRGBHex parses a 6-character hex string (RRGGBB) and returns R, G, B values
@@ -190,8 +214,17 @@ func parseRGBHex(hexStr string) (r, g, b uint8, err error) {
 // SplitNeotexFormat sépare les données neotex en texte et séquences
 // Format: "texte (80 car) | séquence"
 // Retourne des tableaux de lignes pour éviter les \n embeddés
-func SplitNeotexFormat(width int, data []byte) (parsedWidth int, textLines []string, seqLines []string) {
-	separator := " | "
+// Retourne une erreur si le séparateur n'est pas à la colonne attendue
+func SplitNeotexFormat(width int, data []byte) (parsedWidth int, textLines []string, seqLines []string, err error) {
+	return SplitNeotexFormatWithSeparator(width, data, DefaultSeparator)
+}
+
+// SplitNeotexFormatWithSeparator is SplitNeotexFormat with another separator
+// between text and sequences (DefaultSeparator when empty).
+func SplitNeotexFormatWithSeparator(width int, data []byte, separator string) (parsedWidth int, textLines []string, seqLines []string, err error) {
+	if separator == "" {
+		separator = DefaultSeparator
+	}
 
 	lines := strings.Split(string(data), "\n")
 	if width <= 0 {
@@ -217,22 +250,36 @@ func SplitNeotexFormat(width int, data []byte) (parsedWidth int, textLines []str
 
 	parsedWidth = width
 
+	// Trailing empty lines (final newline saved by an editor) carry nothing
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
 	for n, line := range lines {
 		// Convert to runes to handle UTF-8 properly
 		runes := []rune(line)
 		sepRunes := []rune(separator)
 
+		// Short line: text without sequences, trailing spaces may be trimmed
 		if len(runes) < width+len(sepRunes) {
-			break
+			text := runes[:min(len(runes), width)]
+			rest := string(runes[len(text):])
+			if !strings.HasPrefix(separator, rest) {
+				return parsedWidth, nil, nil, fmt.Errorf("line %d: separator %q not found at column %d, found %q",
+					n+1, separator, width, rest)
+			}
+
+			textLines = append(textLines, string(text)+strings.Repeat(" ", width-len(text)))
+			seqLines = append(seqLines, "")
+			continue
 		}
 
 		// Extract the separator at position width
 		actualSep := string(runes[width : width+len(sepRunes)])
 
 		if actualSep != separator {
-			fmt.Printf("Separator not found at position %d, found '%s' instead of '%s' at %d \n",
-				width, actualSep, separator, n)
-			os.Exit(1)
+			return parsedWidth, nil, nil, fmt.Errorf("line %d: separator %q not found at column %d, found %q",
+				n+1, separator, width, actualSep)
 		}
 
 		// Extract text and sequence using rune positions
@@ -242,7 +289,7 @@ func SplitNeotexFormat(width int, data []byte) (parsedWidth int, textLines []str
 		seqLines = append(seqLines, seq)
 	}
 
-	return parsedWidth, textLines, seqLines
+	return parsedWidth, textLines, seqLines, nil
 }
 
 func (t *Tokenizer) Tokenize() []types.Token {
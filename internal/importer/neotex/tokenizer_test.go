@@ -2,6 +2,7 @@ package neotex
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/badele/splitans/internal/types"
@@ -29,6 +30,27 @@ func TestSplitNeotexFormat(t *testing.T) {
 			expectedText: []string{"Hello", "World"},
 			expectedSeq:  []string{"1:Fr", "1:Fg"},
 		},
+		{
+			name:         "Trailing empty line",
+			width:        5,
+			data:         []byte("Hello | 1:Fr\nWorld | 1:Fg\n"),
+			expectedText: []string{"Hello", "World"},
+			expectedSeq:  []string{"1:Fr", "1:Fg"},
+		},
+		{
+			name:         "Short line in the middle",
+			width:        5,
+			data:         []byte("Hello | 1:Fr\nHi\n\nWorld | 1:Fg"),
+			expectedText: []string{"Hello", "Hi   ", "     ", "World"},
+			expectedSeq:  []string{"1:Fr", "", "", "1:Fg"},
+		},
+		{
+			name:         "Trimmed separator",
+			width:        5,
+			data:         []byte("Hello |\nWorld | 1:Fg"),
+			expectedText: []string{"Hello", "World"},
+			expectedSeq:  []string{"", "1:Fg"},
+		},
 		{
 			name:         "Unicode text",
 			width:        9,
@@ -40,7 +62,10 @@ func TestSplitNeotexFormat(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, textLines, seqLines := SplitNeotexFormat(tt.width, tt.data)
+			_, textLines, seqLines, err := SplitNeotexFormat(tt.width, tt.data)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
 			if !reflect.DeepEqual(textLines, tt.expectedText) {
 				t.Errorf("Text lines: expected %v, got %v", tt.expectedText, textLines)
 			}
@@ -158,6 +183,22 @@ func TestApplyNeotexCode(t *testing.T) {
 			},
 			checkDesc: "Reverse should be true",
 		},
+		{
+			name: "Double Underline ON",
+			code: "EW",
+			checkFn: func(s *types.SGR) bool {
+				return s.DoubleUnderline == true
+			},
+			checkDesc: "DoubleUnderline should be true",
+		},
+		{
+			name: "Overline ON",
+			code: "EO",
+			checkFn: func(s *types.SGR) bool {
+				return s.Overline == true
+			},
+			checkDesc: "Overline should be true",
+		},
 		// RGB colors
 		{
 			name: "Foreground RGB",
@@ -355,7 +396,10 @@ func findSubstring(s, substr string) bool {
 func TestNewNeotexTokenizer(t *testing.T) {
 	// Test basic tokenizer creation
 	data := []byte("Hello | 1:Fr")
-	_, tokenizer := NewNeotexTokenizer(data, 5)
+	_, tokenizer, err := NewNeotexTokenizer(data, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
 	if tokenizer == nil {
 		t.Fatal("NewNeotexTokenizer returned nil")
@@ -370,7 +414,10 @@ func TestNewNeotexTokenizer(t *testing.T) {
 func TestTokenizerWithMultipleStyles(t *testing.T) {
 	// Test with multiple style changes
 	data := []byte("RedGreen | 1:Fr; 4:Fg")
-	_, tokenizer := NewNeotexTokenizer(data, 8)
+	_, tokenizer, err := NewNeotexTokenizer(data, 8)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 
 	tokens := tokenizer.Tokenize()
 
@@ -396,13 +443,19 @@ func TestTokenizerWithMultipleStyles(t *testing.T) {
 
 func TestTokenizerGetStats(t *testing.T) {
 	data := []byte("Hello | 1:Fr")
-	_, tokenizer := NewNeotexTokenizer(data, 5)
+	_, tokenizer, err := NewNeotexTokenizer(data, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 	tokenizer.Tokenize()
 
 	stats := tokenizer.GetStats()
 	if stats.TotalTokens == 0 {
 		t.Error("Expected TotalTokens to be set after tokenization")
 	}
+	if stats.ColorUsage["fg:std:1"] != 5 {
+		t.Errorf("Expected 5 red text cells, got color usage %v", stats.ColorUsage)
+	}
 }
 
 func TestParseLineSequences(t *testing.T) {
@@ -464,3 +517,94 @@ func TestParseLineSequences(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitNeotexFormatMissingSeparator(t *testing.T) {
+	data := []byte("Hello | 1:Fr\nWorld ! 1:Fg")
+
+	_, _, _, err := SplitNeotexFormat(5, data)
+	if err == nil {
+		t.Fatal("Expected an error for a missing separator")
+	}
+
+	for _, want := range []string{"line 2", "column 5", `" ! "`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected error to mention %s, got %q", want, err.Error())
+		}
+	}
+
+	if _, tokenizer, err := NewNeotexTokenizer(data, 5); err == nil || tokenizer != nil {
+		t.Errorf("Expected NewNeotexTokenizer to return the error, got %v", err)
+	}
+}
+
+func TestTokenizeWithTrailingEmptyLine(t *testing.T) {
+	data := []byte("Hello | 1:Fr\nWorld | 1:Fg\n\n")
+
+	_, tokenizer, err := NewNeotexTokenizer(data, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var text strings.Builder
+	for _, token := range tokenizer.Tokenize() {
+		if token.Type == types.TokenText {
+			text.WriteString(token.Value)
+		}
+	}
+
+	if got := text.String(); got != "HelloWorld" {
+		t.Errorf("Expected text 'HelloWorld', got %q", got)
+	}
+}
+
+func TestValidateNeotex(t *testing.T) {
+	textLines := []string{"Hello", "World"}
+	seqLines := []string{"!V1; !TW5/5; 1:Fr, Fz; 3:EU", "2:Fg; 9:Bb; Fr; x:EU"}
+
+	expected := []NeotexError{
+		{Line: 1, Column: 14, Entry: "1:Fr, Fz", Reason: `unknown code "Fz"`},
+		{Line: 2, Column: 7, Entry: "9:Bb", Reason: "position 9 beyond text width 5"},
+		{Line: 2, Column: 13, Entry: "Fr", Reason: "missing position"},
+		{Line: 2, Column: 17, Entry: "x:EU", Reason: `bad position "x"`},
+	}
+
+	errs := ValidateNeotex(textLines, seqLines)
+	if !reflect.DeepEqual(errs, expected) {
+		t.Fatalf("ValidateNeotex() = %+v, want %+v", errs, expected)
+	}
+
+	if got := errs[0].Error(); got != `line 1, column 14: "1:Fr, Fz": unknown code "Fz"` {
+		t.Errorf("unexpected error message %q", got)
+	}
+
+	if errs := ValidateNeotex([]string{"Hello"}, []string{"1:Fr, B00FF00, F123; 5:R0"}); len(errs) != 0 {
+		t.Errorf("expected no errors, got %+v", errs)
+	}
+}
+
+func TestRewrapNeotex(t *testing.T) {
+	text, sequences, err := RewrapNeotex("ABCDEFGHIJ  ", "!V1; !TW10/12; !NL1; 1:Fr, Bk; 5:Fg; 7:EU; 11:R0", 4)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if expected := "ABCD\nEFGH\nIJ  "; text != expected {
+		t.Fatalf("text = %q, want %q", text, expected)
+	}
+
+	// 5:Fg sits on the wrap boundary, it starts the second line
+	if expected := "!V1; !TW4/4; !NL3; 1:Fr, Bk\n1:Fg; 3:EU\n3:R0"; sequences != expected {
+		t.Fatalf("sequences = %q, want %q", sequences, expected)
+	}
+
+	// Same ANSI once re-split
+	inline := ConvertNeotexToANSI([]string{"ABCDEFGHIJ  "}, []string{"1:Fr, Bk; 5:Fg; 7:EU; 11:R0"})
+	wrapped := ConvertNeotexToANSI(strings.Split(text, "\n"), strings.Split(sequences, "\n"))
+	if string(inline) != string(wrapped) {
+		t.Fatalf("rewrapped ANSI %q differs from inline %q", wrapped, inline)
+	}
+
+	if _, _, err := RewrapNeotex("ABCD", "1Fr", 4); err == nil {
+		t.Fatalf("expected an error for an entry without position")
+	}
+}
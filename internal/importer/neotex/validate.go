@@ -0,0 +1,81 @@
+package neotex
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/badele/splitans/internal/types"
+)
+
+// NeotexError is a malformed entry of a sequence line
+type NeotexError struct {
+	Line   int    // Line number (1-indexed)
+	Column int    // Rune column of the entry in the sequence line (1-indexed)
+	Entry  string // The offending entry, e.g. "14:Fz"
+	Reason string
+}
+
+func (e NeotexError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %q: %s", e.Line, e.Column, e.Entry, e.Reason)
+}
+
+// ValidateNeotex reports the sequence entries that parseLineSequences would
+// silently drop or misapply: a missing or non-numeric position, a position
+// beyond the text of the line and unknown codes. Metadata entries (!V1,
+// !TW73/80...) are not checked.
+func ValidateNeotex(textLines, seqLines []string) []NeotexError {
+	var errs []NeotexError
+
+	for n, seqLine := range seqLines {
+		width := 0
+		if n < len(textLines) {
+			width = utf8.RuneCountInString(textLines[n])
+		}
+
+		offset := 0
+		for _, raw := range strings.Split(seqLine, ";") {
+			entry := strings.TrimSpace(raw)
+			start := offset + strings.Index(raw, entry)
+			offset += len(raw) + 1
+
+			if entry == "" || strings.HasPrefix(entry, "!") {
+				continue
+			}
+
+			report := func(reason string) {
+				errs = append(errs, NeotexError{
+					Line:   n + 1,
+					Column: utf8.RuneCountInString(seqLine[:start]) + 1,
+					Entry:  entry,
+					Reason: reason,
+				})
+			}
+
+			parts := strings.SplitN(entry, ":", 2)
+			if len(parts) != 2 {
+				report("missing position")
+				continue
+			}
+
+			position, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+			if err != nil || position < 1 {
+				report(fmt.Sprintf("bad position %q", strings.TrimSpace(parts[0])))
+				continue
+			}
+			if position > width {
+				report(fmt.Sprintf("position %d beyond text width %d", position, width))
+			}
+
+			for _, code := range strings.Split(parts[1], ",") {
+				code = strings.TrimSpace(code)
+				if code != "" && !applyNeotexCode(code, types.NewSGR()) {
+					report(fmt.Sprintf("unknown code %q", code))
+				}
+			}
+		}
+	}
+
+	return errs
+}
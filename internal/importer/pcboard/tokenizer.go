@@ -0,0 +1,150 @@
+package pcboard
+
+// Format PCBoard
+//
+// Colors:
+//   @X<bg><fg> = set colors, <bg> and <fg> are hex nibbles (0-F)
+//   0 = Black, 1 = Blue, 2 = Green, 3 = Cyan
+//   4 = Red, 5 = Magenta, 6 = Brown, 7 = White (DOS order)
+//   <fg> 8-F = bright foreground (bold)
+//   <bg> 8-F = bright background (blink)
+//
+// Macros:
+//   @CLS@     = Clear screen
+//   @POS:nn@  = Move the cursor to column nn (1-based)
+//   @@        = Literal '@'
+//
+// Unknown '@' sequences are kept as text.
+//
+// Examples:
+//   @X1FHello -> Blue background, bright white foreground, "Hello"
+//   @X07      -> Black background, white foreground
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/badele/splitans/internal/importer/ansi"
+	"github.com/badele/splitans/internal/types"
+)
+
+// dosToANSI maps DOS color order to ANSI color order
+var dosToANSI = [8]int{0, 4, 2, 6, 1, 5, 3, 7}
+
+type Tokenizer struct {
+	data   []byte
+	Tokens []types.Token    `json:"tokens"`
+	Stats  types.TokenStats `json:"stats"`
+}
+
+func NewPCBoardTokenizer(data []byte) *Tokenizer {
+	return &Tokenizer{
+		data:   data,
+		Tokens: make([]types.Token, 0),
+		Stats: types.TokenStats{
+			TokensByType: make(map[types.TokenType]int),
+			SGRCodes:     make(map[string]int),
+			CSISequences: make(map[string]int),
+			C0Codes:      make(map[byte]int),
+			C1Codes:      make(map[string]int),
+		},
+	}
+}
+
+// ConvertPCBoardToANSI converts PCBoard @ codes to ANSI sequences
+func ConvertPCBoardToANSI(data []byte) []byte {
+	var out bytes.Buffer
+
+	for i := 0; i < len(data); i++ {
+		if data[i] != '@' {
+			out.WriteByte(data[i])
+			continue
+		}
+
+		rest := data[i:]
+		switch {
+		case bytes.HasPrefix(rest, []byte("@@")):
+			out.WriteByte('@')
+			i++
+
+		case len(rest) >= 4 && rest[1] == 'X' && isHex(rest[2]) && isHex(rest[3]):
+			out.WriteString(colorToANSI(hexValue(rest[2]), hexValue(rest[3])))
+			i += 3
+
+		case bytes.HasPrefix(rest, []byte("@CLS@")):
+			out.WriteString("\x1b[2J")
+			i += len("@CLS@") - 1
+
+		case bytes.HasPrefix(rest, []byte("@POS:")):
+			end := bytes.IndexByte(rest[5:], '@')
+			if end < 0 {
+				out.WriteByte('@')
+				break
+			}
+
+			col, err := strconv.Atoi(string(rest[5 : 5+end]))
+			if err != nil || col < 1 {
+				out.WriteByte('@')
+				break
+			}
+
+			// CR + cursor forward gives an absolute column
+			out.WriteByte('\r')
+			if col > 1 {
+				fmt.Fprintf(&out, "\x1b[%dC", col-1)
+			}
+			i += 5 + end
+
+		default:
+			out.WriteByte('@')
+		}
+	}
+
+	return out.Bytes()
+}
+
+// colorToANSI builds a full SGR sequence from PCBoard background/foreground nibbles
+func colorToANSI(bg, fg int) string {
+	params := "0"
+	if fg >= 8 {
+		params += ";1"
+	}
+	if bg >= 8 {
+		params += ";5"
+	}
+
+	return fmt.Sprintf("\x1b[%s;%d;%dm", params, 30+dosToANSI[fg&7], 40+dosToANSI[bg&7])
+}
+
+func isHex(b byte) bool {
+	return (b >= '0' && b <= '9') || (b >= 'A' && b <= 'F') || (b >= 'a' && b <= 'f')
+}
+
+func hexValue(b byte) int {
+	switch {
+	case b >= '0' && b <= '9':
+		return int(b - '0')
+	case b >= 'A' && b <= 'F':
+		return int(b-'A') + 10
+	default:
+		return int(b-'a') + 10
+	}
+}
+
+func (t *Tokenizer) Tokenize() []types.Token {
+	// Convert PCBoard codes to ANSI format
+	ansiData := ConvertPCBoardToANSI(t.data)
+
+	// Use the existing ANSI tokenizer
+	ansiTokenizer := ansi.NewANSITokenizer(ansiData)
+	t.Tokens = ansiTokenizer.Tokenize()
+	t.Stats = ansiTokenizer.GetStats()
+
+	return t.Tokens
+}
+
+// GetStats returns tokenization statistics
+func (t *Tokenizer) GetStats() types.TokenStats {
+	return t.Stats
+}
@@ -0,0 +1,55 @@
+package pcboard
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/badele/splitans/internal/types"
+)
+
+func TestConvertPCBoardToANSI(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Blue bg bright white fg", "@X1FHello", "\x1b[0;1;37;44mHello"},
+		{"Default colors", "@X07Text", "\x1b[0;37;40mText"},
+		{"Bright background", "@X8Cx", "\x1b[0;1;5;31;40mx"},
+		{"Lowercase hex", "@X1fA", "\x1b[0;1;37;44mA"},
+		{"Literal at", "user@@host", "user@host"},
+		{"Unknown code", "mail@example", "mail@example"},
+		{"Clear screen", "@CLS@A", "\x1b[2JA"},
+		{"Position", "@POS:10@A", "\r\x1b[9CA"},
+		{"Position first column", "@POS:1@A", "\rA"},
+		{"Unterminated position", "@POS:10", "@POS:10"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(ConvertPCBoardToANSI([]byte(tt.input)))
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTokenizeEmitsSGRAndText(t *testing.T) {
+	tokens := NewPCBoardTokenizer([]byte("@X1FHi @@")).Tokenize()
+
+	if len(tokens) != 2 {
+		t.Fatalf("Expected 2 tokens, got %d", len(tokens))
+	}
+
+	if tokens[0].Type != types.TokenSGR {
+		t.Errorf("Expected TokenSGR, got %v", tokens[0].Type)
+	}
+	if expected := []string{"0", "1", "37", "44"}; !reflect.DeepEqual(tokens[0].Parameters, expected) {
+		t.Errorf("Expected params %v, got %v", expected, tokens[0].Parameters)
+	}
+
+	if tokens[1].Type != types.TokenText || tokens[1].Value != "Hi @" {
+		t.Errorf("Expected text 'Hi @', got %v %q", tokens[1].Type, tokens[1].Value)
+	}
+}
@@ -0,0 +1,96 @@
+package pipecode
+
+// Format pipe code (Renegade, Celerity)
+//
+// Colors:
+//   |00-|07 = Foreground colors
+//   |08-|15 = Bright foreground colors (bold)
+//   |16-|23 = Background colors
+//   0 = Black, 1 = Blue, 2 = Green, 3 = Cyan
+//   4 = Red, 5 = Magenta, 6 = Brown, 7 = White (DOS order)
+//
+// A '|' not followed by a valid two-digit code is kept as text.
+//
+// Examples:
+//   |04Red|00   -> "Red" in red, then black foreground
+//   |15|17Title -> Bright white on blue "Title"
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/badele/splitans/internal/importer/ansi"
+	"github.com/badele/splitans/internal/types"
+)
+
+// dosToANSI maps DOS color order to ANSI color order
+var dosToANSI = [8]int{0, 4, 2, 6, 1, 5, 3, 7}
+
+type Tokenizer struct {
+	data   []byte
+	Tokens []types.Token    `json:"tokens"`
+	Stats  types.TokenStats `json:"stats"`
+}
+
+func NewPipeCodeTokenizer(data []byte) *Tokenizer {
+	return &Tokenizer{
+		data:   data,
+		Tokens: make([]types.Token, 0),
+		Stats: types.TokenStats{
+			TokensByType: make(map[types.TokenType]int),
+			SGRCodes:     make(map[string]int),
+			CSISequences: make(map[string]int),
+			C0Codes:      make(map[byte]int),
+			C1Codes:      make(map[string]int),
+		},
+	}
+}
+
+// ConvertPipeCodeToANSI converts |NN pipe codes to ANSI sequences
+func ConvertPipeCodeToANSI(data []byte) []byte {
+	var out bytes.Buffer
+
+	for i := 0; i < len(data); i++ {
+		if data[i] != '|' || i+2 >= len(data) || !isDigit(data[i+1]) || !isDigit(data[i+2]) {
+			out.WriteByte(data[i])
+			continue
+		}
+
+		code := int(data[i+1]-'0')*10 + int(data[i+2]-'0')
+		switch {
+		case code < 8:
+			fmt.Fprintf(&out, "\x1b[22;%dm", 30+dosToANSI[code])
+		case code < 16:
+			fmt.Fprintf(&out, "\x1b[1;%dm", 30+dosToANSI[code-8])
+		case code < 24:
+			fmt.Fprintf(&out, "\x1b[%dm", 40+dosToANSI[code-16])
+		default:
+			out.WriteByte(data[i])
+			continue
+		}
+		i += 2
+	}
+
+	return out.Bytes()
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
+func (t *Tokenizer) Tokenize() []types.Token {
+	// Convert pipe codes to ANSI format
+	ansiData := ConvertPipeCodeToANSI(t.data)
+
+	// Use the existing ANSI tokenizer
+	ansiTokenizer := ansi.NewANSITokenizer(ansiData)
+	t.Tokens = ansiTokenizer.Tokenize()
+	t.Stats = ansiTokenizer.GetStats()
+
+	return t.Tokens
+}
+
+// GetStats returns tokenization statistics
+func (t *Tokenizer) GetStats() types.TokenStats {
+	return t.Stats
+}
@@ -0,0 +1,47 @@
+package pipecode
+
+import (
+	"testing"
+
+	"github.com/badele/splitans/internal/exporter"
+)
+
+func TestConvertPipeCodeToANSI(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Foreground", "|04Red", "\x1b[22;31mRed"},
+		{"Bright foreground", "|15White", "\x1b[1;37mWhite"},
+		{"Background", "|17Blue", "\x1b[44mBlue"},
+		{"Literal pipe", "a | b", "a | b"},
+		{"Single digit", "|4x", "|4x"},
+		{"Pipe at end", "end|0", "end|0"},
+		{"Out of range", "|99", "|99"},
+		{"Double pipe", "||04x", "|\x1b[22;31mx"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(ConvertPipeCodeToANSI([]byte(tt.input)))
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestPipeCodeRoundTripToANSI(t *testing.T) {
+	tokens := NewPipeCodeTokenizer([]byte("|04Red|00")).Tokenize()
+
+	got, err := exporter.ExportFlattenedANSI(3, 1, tokens, "utf8", false)
+	if err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+
+	expected := "\x1b[31;40mRed\n\x1b[0m"
+	if got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
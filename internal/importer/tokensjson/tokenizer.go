@@ -0,0 +1,89 @@
+package tokensjson
+
+// Format tokens JSON
+// The output of exporter.TokensJSON: {"version": "1.0", "tokens": [...], "stats": {...}}
+// A missing version is read as 1.0, files of another major version are refused.
+// Tokens are restored as-is, so exporters can run without re-parsing the
+// original file.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/badele/splitans/internal/exporter"
+	"github.com/badele/splitans/internal/types"
+)
+
+type Tokenizer struct {
+	Tokens []types.Token    `json:"tokens"`
+	Stats  types.TokenStats `json:"stats"`
+}
+
+// NewTokensJSONTokenizer decodes tokens and stats previously written by
+// exporter.TokensJSON.
+func NewTokensJSONTokenizer(r io.Reader) (*Tokenizer, error) {
+	tokens, stats, err := ImportTokensJSON(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tokenizer{
+		Tokens: tokens,
+		Stats:  stats,
+	}, nil
+}
+
+// ImportTokensJSON decodes the TokenizerJSONOutput shape into tokens and stats.
+func ImportTokensJSON(r io.Reader) ([]types.Token, types.TokenStats, error) {
+	var output exporter.TokenizerJSONOutput
+	if err := json.NewDecoder(r).Decode(&output); err != nil {
+		return nil, types.TokenStats{}, fmt.Errorf("error decoding tokens JSON: %w", err)
+	}
+
+	if output.Version != "" && majorVersion(output.Version) != majorVersion(exporter.TokensJSONVersion) {
+		return nil, types.TokenStats{}, fmt.Errorf("unsupported tokens JSON version %q (expected %s)", output.Version, exporter.TokensJSONVersion)
+	}
+
+	if output.Tokens == nil {
+		output.Tokens = make([]types.Token, 0)
+	}
+
+	stats := &output.Stats
+	if stats.TokensByType == nil {
+		stats.TokensByType = make(map[types.TokenType]int)
+	}
+	if stats.SGRCodes == nil {
+		stats.SGRCodes = make(map[string]int)
+	}
+	if stats.CSISequences == nil {
+		stats.CSISequences = make(map[string]int)
+	}
+	if stats.C0Codes == nil {
+		stats.C0Codes = make(map[byte]int)
+	}
+	if stats.C1Codes == nil {
+		stats.C1Codes = make(map[string]int)
+	}
+	if stats.ColorUsage == nil {
+		stats.ColorUsage = make(map[string]int)
+	}
+
+	return output.Tokens, output.Stats, nil
+}
+
+// majorVersion returns the part of version before the first dot
+func majorVersion(version string) string {
+	major, _, _ := strings.Cut(version, ".")
+	return major
+}
+
+func (t *Tokenizer) Tokenize() []types.Token {
+	return t.Tokens
+}
+
+// GetStats returns tokenization statistics
+func (t *Tokenizer) GetStats() types.TokenStats {
+	return t.Stats
+}
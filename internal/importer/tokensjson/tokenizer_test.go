@@ -0,0 +1,100 @@
+package tokensjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/badele/splitans/internal/exporter"
+	"github.com/badele/splitans/internal/importer/ansi"
+	"github.com/badele/splitans/internal/types"
+)
+
+func TestImportTokensJSONRoundTrip(t *testing.T) {
+	input := []byte("\x1b[1;31mHello\x00\r\n\x1b[5CWorld\x1b[0m")
+
+	tok := ansi.NewANSITokenizer(input)
+	tokens := tok.Tokenize()
+
+	data, err := json.Marshal(exporter.TokenizerJSONOutput{
+		Tokens: tokens,
+		Stats:  tok.GetStats(),
+	})
+	if err != nil {
+		t.Fatalf("unexpected marshal error: %v", err)
+	}
+
+	imported, stats, err := ImportTokensJSON(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("unexpected import error: %v", err)
+	}
+
+	if !reflect.DeepEqual(imported, tokens) {
+		t.Fatalf("Imported tokens differ\nexpected: %v\ngot:      %v", tokens, imported)
+	}
+
+	if stats.C0Codes[0x00] != 1 {
+		t.Errorf("Expected one NUL in stats, got %d", stats.C0Codes[0x00])
+	}
+	if stats.TokensByType[types.TokenSGR] != 2 {
+		t.Errorf("Expected 2 SGR tokens in stats, got %d", stats.TokensByType[types.TokenSGR])
+	}
+
+	expected, err := exporter.ExportFlattenedANSI(20, 3, tokens, "utf8", false)
+	if err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+	got, err := exporter.ExportFlattenedANSI(20, 3, imported, "utf8", false)
+	if err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+	if got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestImportTokensJSONNULCode(t *testing.T) {
+	data := `{"tokens":[{"type":"TokenC0","pos":0,"raw":"\u0000"}],"stats":{}}`
+
+	tokens, stats, err := ImportTokensJSON(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("unexpected import error: %v", err)
+	}
+
+	if len(tokens) != 1 || tokens[0].Type != types.TokenC0 || tokens[0].C0Code != 0x00 {
+		t.Fatalf("Expected a NUL C0 token, got %v", tokens)
+	}
+
+	if stats.C0Codes == nil {
+		t.Errorf("Expected initialized stats maps")
+	}
+}
+
+func TestImportTokensJSONInvalid(t *testing.T) {
+	if _, _, err := ImportTokensJSON(strings.NewReader("{")); err == nil {
+		t.Fatalf("Expected an error for invalid JSON")
+	}
+}
+
+func TestImportTokensJSONVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		wantErr bool
+	}{
+		{version: "", wantErr: false},
+		{version: exporter.TokensJSONVersion, wantErr: false},
+		{version: "1.3", wantErr: false},
+		{version: "2.0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		data := `{"version":"` + tt.version + `","tokens":[{"type":"TokenText","value":"A"}],"stats":{}}`
+
+		_, _, err := ImportTokensJSON(strings.NewReader(data))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("version %q: expected error %t, got %v", tt.version, tt.wantErr, err)
+		}
+	}
+}
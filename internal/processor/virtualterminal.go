@@ -2,8 +2,12 @@ package processor
 
 import (
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
+	"unicode"
+
+	"golang.org/x/text/width"
 
 	"github.com/badele/splitans/internal/types"
 )
@@ -15,19 +19,35 @@ import (
 type Cell struct {
 	Char rune
 	SGR  *types.SGR
+	// Right half of a wide char written in the previous cell
+	Continuation bool
+	// Combining marks (e.g. U+0301) attached to Char
+	Combining []rune
+	// Hyperlink (OSC 8) active when the cell was written
+	Link *Hyperlink
+}
+
+// Hyperlink is an OSC 8 hyperlink, shared by the cells it covers
+type Hyperlink struct {
+	Params string
+	URI    string
 }
 
 type VirtualTerminal struct {
-	buffer         [][]Cell
-	width          int
-	height         int
-	cursorX        int
-	cursorY        int
-	maxCursorX     int
-	maxCursorY     int
-	currentSGR     *types.SGR
-	savedCursorX   int
-	savedCursorY   int
+	buffer       [][]Cell
+	width        int
+	height       int
+	cursorX      int
+	cursorY      int
+	maxCursorX   int
+	maxCursorY   int
+	maxPaintedY  int // Highest row holding written cells, trims the split export
+	maxPaintedX  int // Highest column holding written cells, bounds Cells
+	currentSGR   *types.SGR
+	savedCursorX int
+	savedCursorY int
+	// Style saved by DECSC (ESC 7), nil until the first save
+	savedSGR       *types.SGR
 	outputEncoding string
 	useVGAColors   bool
 	debugCursor    bool
@@ -35,6 +55,62 @@ type VirtualTerminal struct {
 	lastWrapped    bool
 	// Ignore CR/LF tokens that immediately follow a soft wrap at width.
 	ignoreWrapCRLF bool
+	// Autowrap mode (DECAWM), toggled by CSI ?7h / CSI ?7l
+	autoWrap bool
+	// Cursor sits on the last column, wrap is deferred to the next printable char
+	pendingWrap bool
+	// iCE colors: blink (SGR 5) selects a bright background instead
+	iceColors bool
+	// Legacy SGR output: attributes are turned off with reset + rebuild
+	// instead of the 22/23/24/25/27/28/29 off codes
+	legacyMode bool
+	// Legacy SGR output encodes bright backgrounds as blink + 40-47 (DOS)
+	blinkBrightBg bool
+	// Hyperlink opened by OSC 8, nil when closed
+	currentLink *Hyperlink
+	// Window title set by OSC 0/2
+	title string
+	// Horizontal tab stops, set by HTS (ESC H) and cleared by TBC (CSI g)
+	tabStops []bool
+	// Columns between the default tab stops
+	tabWidth int
+	// Last printed char with its combining marks, repeated by REP (CSI b)
+	lastPrinted string
+	// Cell of the last printed char, while lastPrinted is set
+	lastPrintedX, lastPrintedY int
+	// Overstrike: a char written back over itself after BS is bold, over or
+	// under an underscore it is underlined (nroff/man style)
+	overstrike bool
+	// The previous token was a BS that moved the cursor back
+	backspaced bool
+	// FF clears the screen instead of moving down one row
+	formFeedClears bool
+	// Keep unknown, DCS and unsupported OSC sequences in the exports
+	preserveUnknown bool
+	// Sequences kept by preserveUnknown, anchored to the cursor cell
+	rawSequences []rawSequence
+	// G0 is the DEC Special Graphics set, selected by ESC ( 0 and left by ESC ( B
+	lineDrawing bool
+	// Same for G1 with ESC ) 0 and ESC ) B
+	g1LineDrawing bool
+	// SO (0x0E) invoked G1 for the next chars, SI (0x0F) goes back to G0
+	shiftOut bool
+	// Called when the cursor is pushed below the last row, see SetOnOverflow
+	onOverflow func(x, y int)
+	// Cursor hidden by CSI ? 25 l, and the number of show/hide transitions
+	cursorHidden            bool
+	cursorVisibilityChanges int
+	// Each exported ANSI line starts with a reset and its full style
+	lineIndependent bool
+	// Rows continued on the next one by an autowrap, see Reflow
+	softWrapped []bool
+}
+
+// rawSequence is an unsupported sequence received with the cursor at (x, y).
+// x is the width when a wrap was pending, the sequence then ends the line.
+type rawSequence struct {
+	x, y int
+	raw  string
 }
 
 func NewVirtualTerminal(width, height int, outputEncoding string, useVGAColors bool) *VirtualTerminal {
@@ -62,8 +138,435 @@ func NewVirtualTerminal(width, height int, outputEncoding string, useVGAColors b
 		debugSGR:       false,
 		lastWrapped:    false,
 		ignoreWrapCRLF: true,
+		autoWrap:       true,
+		pendingWrap:    false,
+		iceColors:      false,
+		legacyMode:     true,
+		tabStops:       defaultTabStops(width, 8),
+		tabWidth:       8,
+		softWrapped:    make([]bool, height),
+	}
+}
+
+// defaultTabStops returns a tab stop every tabWidth columns
+func defaultTabStops(width, tabWidth int) []bool {
+	stops := make([]bool, width)
+	for x := tabWidth; x < width; x += tabWidth {
+		stops[x] = true
+	}
+	return stops
+}
+
+// NewVirtualTerminalAuto creates a virtual terminal tall enough to hold tokens,
+// using EstimateHeight instead of a fixed number of lines.
+func NewVirtualTerminalAuto(tokens []types.Token, width int, outputEncoding string, useVGAColors bool) *VirtualTerminal {
+	return NewVirtualTerminal(width, EstimateHeight(tokens, width, 8), outputEncoding, useVGAColors)
+}
+
+// maxEstimatedHeight bounds EstimateHeight, so a single huge cursor move such
+// as CSI 99999999 B does not allocate millions of rows
+const maxEstimatedHeight = 10000
+
+// EstimateHeight returns the number of lines needed to render tokens at the
+// given width, following LF, wraps of text, TAB (tab stops every tabWidth
+// columns, 8 when less than 1, like SetTabWidth) and REP (wide chars taking
+// two cells) and vertical cursor moves (CSI A/B/H/f). It never returns more
+// than maxEstimatedHeight lines.
+func EstimateHeight(tokens []types.Token, width, tabWidth int) int {
+	width = max(width, 1)
+	if tabWidth < 1 {
+		tabWidth = 8
+	}
+	x, y, maxY := 0, 0, 0
+	// Cells of the last printed char, repeated by REP
+	lastCells := 0
+
+	for _, token := range tokens {
+		switch token.Type {
+		case types.TokenC0, types.TokenC1, types.TokenEscape:
+			lastCells = 0
+		case types.TokenCSI:
+			if !strings.HasSuffix(token.Raw, "b") {
+				lastCells = 0
+			}
+		}
+
+		switch token.Type {
+		case types.TokenText:
+			for _, r := range token.Value {
+				if unicode.In(r, unicode.Mn, unicode.Me) {
+					continue
+				}
+				lastCells = RuneWidth(r)
+				x, y = estimateAdvance(x, y, lastCells, 1, width)
+			}
+
+		case types.TokenC0:
+			switch token.C0Code {
+			case 0x09: // TAB
+				x = (x/tabWidth + 1) * tabWidth
+				if x >= width {
+					x = 0
+					y++
+				}
+			case 0x0A: // LF
+				x = 0
+				y++
+			case 0x0B, 0x0C: // VT, FF
+				y++
+			case 0x0D: // CR
+				x = 0
+			}
+
+		case types.TokenCSI:
+			if len(token.Raw) == 0 {
+				break
+			}
+
+			n := 1
+			if len(token.Parameters) > 0 && token.Parameters[0] != "" {
+				n, _ = strconv.Atoi(token.Parameters[0])
+			}
+			n = min(n, maxEstimatedHeight)
+
+			switch token.Raw[len(token.Raw)-1] {
+			case 'A', 'F':
+				y = max(0, y-n)
+			case 'B', 'E':
+				y += n
+			case 'H', 'f':
+				y = max(0, n-1)
+			case 'b':
+				x, y = estimateAdvance(x, y, lastCells, min(n, width*maxEstimatedHeight), width)
+			}
+		}
+
+		y = min(y, maxEstimatedHeight)
+		maxY = max(maxY, y)
+	}
+
+	// One extra line for a trailing wrap or LF
+	return min(maxY+2, maxEstimatedHeight)
+}
+
+// estimateAdvance moves the (x, y) position of EstimateHeight over n chars
+// of the given cells, wrapping as soon as a row is full. A wide char that
+// does not fit on the rest of the row starts the next one.
+func estimateAdvance(x, y, cells, n, width int) (int, int) {
+	if cells == 0 || n <= 0 {
+		return x, y
+	}
+	cells = min(cells, width)
+
+	if x+cells > width {
+		x = 0
+		y++
+	}
+
+	// Chars fitting on the current row, then on each full row
+	fit := (width - x) / cells
+	if n < fit {
+		return x + n*cells, y
+	}
+	n -= fit
+	perRow := width / cells
+
+	return (n % perRow) * cells, y + 1 + n/perRow
+}
+
+// Clone returns a deep copy of the virtual terminal, buffer, cursor and
+// modes included, to snapshot a frame or render speculatively. Hyperlinks
+// are never modified once opened and stay shared.
+func (vt *VirtualTerminal) Clone() *VirtualTerminal {
+	clone := *vt
+
+	clone.buffer = make([][]Cell, len(vt.buffer))
+	for y, line := range vt.buffer {
+		clone.buffer[y] = make([]Cell, len(line))
+		for x, cell := range line {
+			clone.buffer[y][x] = copyCell(cell)
+		}
+	}
+
+	clone.currentSGR = vt.currentSGR.Copy()
+	if vt.savedSGR != nil {
+		clone.savedSGR = vt.savedSGR.Copy()
+	}
+	clone.tabStops = append([]bool(nil), vt.tabStops...)
+	clone.softWrapped = append([]bool(nil), vt.softWrapped...)
+	clone.rawSequences = append([]rawSequence(nil), vt.rawSequences...)
+
+	return &clone
+}
+
+// Resize changes the dimensions of vt to width x height (at least 1x1).
+// Cells keep their coordinates: they are clipped when the buffer shrinks and
+// blank cells are added when it grows. A wide char cut at the new right
+// edge is blanked. The cursor is clamped into the buffer, a pending wrap is
+// dropped when the width changes, and the current style is kept.
+func (vt *VirtualTerminal) Resize(width, height int) {
+	width = max(width, 1)
+	height = max(height, 1)
+
+	buffer := make([][]Cell, height)
+	for y := range buffer {
+		buffer[y] = make([]Cell, width)
+		for x := range buffer[y] {
+			if y < vt.height && x < vt.width {
+				buffer[y][x] = vt.buffer[y][x]
+				continue
+			}
+			buffer[y][x] = Cell{Char: 0x0, SGR: types.NewSGR()}
+		}
+
+		if width < vt.width && y < vt.height && vt.buffer[y][width].Continuation {
+			buffer[y][width-1] = Cell{Char: 0x0, SGR: buffer[y][width-1].SGR}
+		}
+	}
+
+	tabStops := defaultTabStops(width, vt.tabWidth)
+	copy(tabStops, vt.tabStops)
+
+	softWrapped := make([]bool, height)
+	copy(softWrapped, vt.softWrapped)
+
+	if width != vt.width {
+		vt.pendingWrap = false
+	}
+
+	rawSequences := vt.rawSequences[:0]
+	for _, seq := range vt.rawSequences {
+		if seq.y < height && seq.x <= width {
+			rawSequences = append(rawSequences, seq)
+		}
+	}
+
+	vt.buffer = buffer
+	vt.width = width
+	vt.height = height
+	vt.tabStops = tabStops
+	vt.softWrapped = softWrapped
+	vt.rawSequences = rawSequences
+	vt.cursorX = min(vt.cursorX, width-1)
+	vt.cursorY = min(vt.cursorY, height-1)
+	vt.savedCursorX = min(vt.savedCursorX, width-1)
+	vt.savedCursorY = min(vt.savedCursorY, height-1)
+	vt.maxCursorX = min(vt.maxCursorX, width-1)
+	vt.maxCursorY = min(vt.maxCursorY, height-1)
+	vt.maxPaintedY = min(vt.maxPaintedY, height-1)
+	vt.maxPaintedX = min(vt.maxPaintedX, width-1)
+}
+
+// Reflow renders tokens on a fromWidth x height terminal, then rewraps its
+// lines at toWidth, keeping the style of each cell. Rows continued by an
+// autowrap are joined into one line first, trailing blanks dropped. It is
+// best-effort, for art relying on wraps: cursor moves and tabs are only kept
+// as the blanks they rendered, so positioned art comes out scrambled. The
+// returned terminal is as tall as the rewrapped lines.
+func Reflow(tokens []types.Token, fromWidth, toWidth, height int) (*VirtualTerminal, error) {
+	if fromWidth < 1 || toWidth < 1 {
+		return nil, fmt.Errorf("invalid reflow widths %d -> %d", fromWidth, toWidth)
+	}
+	if height < 1 {
+		return nil, fmt.Errorf("invalid reflow height %d", height)
+	}
+
+	src := NewVirtualTerminal(fromWidth, height, "utf8", false)
+	if err := src.ApplyTokens(tokens); err != nil {
+		return nil, fmt.Errorf("error applying tokens: %w", err)
+	}
+
+	// Join the soft wrapped rows into lines of cells
+	var lines [][]Cell
+	var line []Cell
+	lastY := min(src.maxPaintedY, src.height-1)
+	for y := 0; y <= lastY; y++ {
+		line = append(line, src.buffer[y]...)
+		if src.softWrapped[y] && y < lastY {
+			continue
+		}
+
+		end := len(line)
+		for end > 0 && line[end-1].Char == 0 && !line[end-1].Continuation {
+			end--
+		}
+		lines = append(lines, line[:end])
+		line = nil
 	}
+
+	// Rewrap each line at toWidth, a wide char never straddles two rows
+	var rows [][]Cell
+	var wrapped []bool
+	for _, line := range lines {
+		row := []Cell{}
+		for i := 0; i < len(line); i++ {
+			cells := 1
+			if i+1 < len(line) && line[i+1].Continuation {
+				cells = 2
+			}
+			if len(row)+cells > toWidth && len(row) > 0 {
+				rows = append(rows, row)
+				wrapped = append(wrapped, true)
+				row = []Cell{}
+			}
+			if cells > toWidth {
+				// A wide char does not fit at all, keep its left half
+				row = append(row, Cell{Char: 0x0, SGR: line[i].SGR})
+				i++
+				continue
+			}
+			row = append(row, line[i:i+cells]...)
+			i += cells - 1
+		}
+		rows = append(rows, row)
+		wrapped = append(wrapped, false)
+	}
+
+	dst := NewVirtualTerminal(toWidth, max(len(rows), 1), "utf8", false)
+	dst.currentSGR = src.currentSGR.Copy()
+	dst.title = src.title
+	for y, row := range rows {
+		for x, cell := range row {
+			dst.buffer[y][x] = copyCell(cell)
+		}
+		dst.softWrapped[y] = wrapped[y] && y < dst.height-1
+		if len(row) > 0 {
+			dst.maxCursorX = max(dst.maxCursorX, len(row)-1)
+			dst.maxPaintedX = max(dst.maxPaintedX, len(row)-1)
+		}
+	}
+	dst.maxCursorY = dst.height - 1
+	dst.maxPaintedY = dst.height - 1
+	dst.cursorY = dst.height - 1
+	dst.cursorX = min(len(rows[len(rows)-1]), toWidth-1)
+
+	return dst, nil
 }
+
+// Cells returns a deep copy of the used area of the buffer (the same rows
+// and columns as the SVG export), for custom renderers. Blank cells have a
+// NUL Char and the right half of a wide char is a Continuation cell.
+func (vt *VirtualTerminal) Cells() [][]Cell {
+	rows := min(vt.maxCursorY+1, vt.height)
+	cols := min(vt.maxPaintedX+1, vt.width)
+
+	cells := make([][]Cell, rows)
+	for y := range cells {
+		cells[y] = make([]Cell, cols)
+		for x := range cells[y] {
+			cells[y][x] = copyCell(vt.buffer[y][x])
+		}
+	}
+
+	return cells
+}
+
+// StyleAt returns a copy of the style and the char of the cell at column x
+// and row y, NUL for a blank cell. Outside the buffer it returns nil and 0.
+func (vt *VirtualTerminal) StyleAt(x, y int) (*types.SGR, rune) {
+	if x < 0 || x >= vt.width || y < 0 || y >= vt.height {
+		return nil, 0
+	}
+
+	cell := vt.buffer[y][x]
+	return cell.SGR.Copy(), cell.Char
+}
+
+// RowStyles returns the style changes of row y, positioned by column: the
+// first one is at column 0. It returns nil outside the buffer.
+func (vt *VirtualTerminal) RowStyles(y int) []types.SGRSequence {
+	if y < 0 || y >= vt.height {
+		return nil
+	}
+
+	var sequences []types.SGRSequence
+	var current *types.SGR
+	for x, cell := range vt.buffer[y] {
+		if cell.Continuation || cell.SGR.Equals(current) {
+			continue
+		}
+
+		sequences = append(sequences, types.SGRSequence{Position: x, SGR: cell.SGR.Copy()})
+		current = cell.SGR
+	}
+
+	return sequences
+}
+
+// copyCell copies a cell with its own SGR and combining marks
+func copyCell(cell Cell) Cell {
+	cell.SGR = cell.SGR.Copy()
+	if cell.Combining != nil {
+		cell.Combining = append([]rune(nil), cell.Combining...)
+	}
+	return cell
+}
+
+// SetICEColors enables iCE colors mode, where blink (SGR 5) is rendered as a
+// bright background (index+8) like DOS art displayed with blink disabled.
+func (vt *VirtualTerminal) SetICEColors(enabled bool) {
+	vt.iceColors = enabled
+}
+
+// SetLegacyMode selects how flattened ANSI turns attributes off. Legacy mode
+// (the default) emits a reset followed by the remaining attributes, for ANSI
+// 1990 viewers; modern mode emits the dedicated off codes (22, 23, 24...).
+func (vt *VirtualTerminal) SetLegacyMode(enabled bool) {
+	vt.legacyMode = enabled
+}
+
+// SetBlinkBrightBg makes legacy mode flattened ANSI encode bright
+// backgrounds (index 8-15) as blink plus 40-47, like DOS art displayed with
+// iCE colors, instead of 100-107. Modern mode ignores it.
+func (vt *VirtualTerminal) SetBlinkBrightBg(enabled bool) {
+	vt.blinkBrightBg = enabled
+}
+
+// SetOverstrike enables the nroff/man overstrike convention: "a BS a"
+// writes a bold 'a' and "_ BS a" an underlined 'a' instead of overwriting.
+func (vt *VirtualTerminal) SetOverstrike(enabled bool) {
+	vt.overstrike = enabled
+}
+
+// SetPreserveUnknown keeps the sequences the terminal does not interpret
+// (unknown tokens, DCS and OSC other than titles and hyperlinks) and writes
+// them verbatim in the flattened ANSI export, where the cursor was.
+func (vt *VirtualTerminal) SetPreserveUnknown(enabled bool) {
+	vt.preserveUnknown = enabled
+}
+
+// SetFormFeedClears selects how FF (0x0C) is rendered: when enabled it
+// clears the screen and homes the cursor, otherwise it moves down one row
+// like VT (0x0B), keeping the column.
+func (vt *VirtualTerminal) SetFormFeedClears(enabled bool) {
+	vt.formFeedClears = enabled
+}
+
+// SetLineIndependent makes each line of the flattened ANSI export start
+// with a reset followed by its whole style, instead of relying on the state
+// left by the previous line, so a single copied line renders the same.
+// The output is larger. It has no effect on the inline export.
+func (vt *VirtualTerminal) SetLineIndependent(enabled bool) {
+	vt.lineIndependent = enabled
+}
+
+// SetOnOverflow registers fn to be called when a line feed, a wrap or a
+// cursor move goes below the last row. fn receives the requested position;
+// the cursor stays on the last row, so what follows overwrites it.
+func (vt *VirtualTerminal) SetOnOverflow(fn func(x, y int)) {
+	vt.onOverflow = fn
+}
+
+// SetTabWidth places the default tab stops every n columns (8 by default),
+// replacing the stops set so far. Values below 1 select the default.
+func (vt *VirtualTerminal) SetTabWidth(n int) {
+	if n < 1 {
+		n = 8
+	}
+	vt.tabWidth = n
+	vt.tabStops = defaultTabStops(vt.width, n)
+}
+
 func (vt *VirtualTerminal) GetWidth() int {
 	return vt.width
 }
@@ -76,6 +579,15 @@ func (vt *VirtualTerminal) GetMaxCursorY() int {
 	return vt.maxCursorY
 }
 
+// CursorPosition returns the cell where the next char will be written,
+// on the next line when a wrap is pending
+func (vt *VirtualTerminal) CursorPosition() (x, y int) {
+	if vt.pendingWrap {
+		return 0, min(vt.cursorY+1, vt.height-1)
+	}
+	return vt.cursorX, vt.cursorY
+}
+
 // ApplyTokens applies ANSI tokens to the virtual terminal
 func (vt *VirtualTerminal) ApplyTokens(tokens []types.Token) error {
 	for _, token := range tokens {
@@ -86,7 +598,69 @@ func (vt *VirtualTerminal) ApplyTokens(tokens []types.Token) error {
 	return nil
 }
 
+// FrameBoundary reports whether the token at index starts a new animation frame
+type FrameBoundary func(token types.Token, index int) bool
+
+// FrameOnClearScreen starts a new frame on each erase display (CSI 2J)
+func FrameOnClearScreen(token types.Token, _ int) bool {
+	return token.Type == types.TokenCSI &&
+		strings.HasSuffix(token.Raw, "J") &&
+		len(token.Parameters) > 0 && token.Parameters[0] == "2"
+}
+
+// FrameEveryNTokens starts a new frame every n tokens
+func FrameEveryNTokens(n int) FrameBoundary {
+	return func(_ types.Token, index int) bool {
+		return n > 0 && index > 0 && index%n == 0
+	}
+}
+
+// ApplyTokensWithCallback applies tokens and calls onFrame with the completed
+// frame right before each erase display (CSI 2J), to snapshot ansimations.
+func (vt *VirtualTerminal) ApplyTokensWithCallback(tokens []types.Token, onFrame func(vt *VirtualTerminal)) error {
+	return vt.ApplyTokensWithFrames(tokens, FrameOnClearScreen, onFrame)
+}
+
+// ApplyTokensWithFrames applies tokens and calls onFrame right before each
+// token matching isBoundary. The last frame is left in the buffer.
+func (vt *VirtualTerminal) ApplyTokensWithFrames(tokens []types.Token, isBoundary FrameBoundary, onFrame func(vt *VirtualTerminal)) error {
+	for i, token := range tokens {
+		if isBoundary(token, i) {
+			onFrame(vt)
+		}
+
+		if err := vt.applyToken(token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (vt *VirtualTerminal) applyToken(token types.Token) error {
+	// REP prints text, a pending wrap stays soft as with writeText
+	isREP := token.Type == types.TokenCSI && strings.HasSuffix(token.Raw, "b")
+
+	// Control codes and cursor sequences see the wrapped cursor, like DOS
+	// terminals that wrap as soon as the last column is written.
+	if vt.pendingWrap && (token.Type == types.TokenC0 || token.Type == types.TokenCSI) && !isREP {
+		vt.wrapLine()
+	}
+
+	// REP only repeats a char printed right before it, controls and cursor
+	// moves forget it
+	switch token.Type {
+	case types.TokenC0, types.TokenC1, types.TokenEscape:
+		vt.lastPrinted = ""
+	case types.TokenCSI:
+		if !isREP {
+			vt.lastPrinted = ""
+		}
+	}
+
+	if token.Type != types.TokenText {
+		vt.backspaced = false
+	}
+
 	switch token.Type {
 	case types.TokenText:
 		vt.writeText(token.Value)
@@ -99,13 +673,165 @@ func (vt *VirtualTerminal) applyToken(token types.Token) error {
 
 	case types.TokenCSI:
 		vt.handleCSI(token)
+
+	case types.TokenOSC:
+		vt.handleOSC(token)
+
+	case types.TokenC1:
+		vt.handleC1(token.C1Code)
+
+	case types.TokenEscape:
+		vt.handleEscape(token)
+
+	case types.TokenUnknown, types.TokenDCS, types.TokenAPC:
+		vt.keepRaw(token.Raw)
 	}
 
 	return nil
 }
 
+// keepRaw records an uninterpreted sequence at the cursor position
+func (vt *VirtualTerminal) keepRaw(raw string) {
+	if !vt.preserveUnknown || raw == "" {
+		return
+	}
+
+	x := vt.cursorX
+	if vt.pendingWrap {
+		x = vt.width
+	}
+	vt.rawSequences = append(vt.rawSequences, rawSequence{x: x, y: min(vt.cursorY, vt.height-1), raw: raw})
+}
+
+func (vt *VirtualTerminal) handleC1(code string) {
+	switch code {
+	case "HTS": // Horizontal Tab Set at the cursor column
+		if vt.cursorX < vt.width {
+			vt.tabStops[vt.cursorX] = true
+		}
+	}
+}
+
+func (vt *VirtualTerminal) handleEscape(token types.Token) {
+	switch strings.TrimPrefix(token.Raw, "\x1b") {
+	case "c": // RIS, full reset
+		vt.eraseDisplay(2)
+		vt.currentSGR = types.NewSGR()
+		vt.currentLink = nil
+		vt.savedCursorX = 0
+		vt.savedCursorY = 0
+		vt.savedSGR = nil
+		vt.tabStops = defaultTabStops(vt.width, vt.tabWidth)
+		vt.autoWrap = true
+		vt.pendingWrap = false
+		vt.lastWrapped = false
+		vt.lineDrawing = false
+		vt.g1LineDrawing = false
+		vt.shiftOut = false
+		vt.cursorHidden = false
+
+	case "(0": // G0 is DEC Special Graphics (line drawing)
+		vt.lineDrawing = true
+
+	case "(B": // G0 is US ASCII
+		vt.lineDrawing = false
+
+	case ")0": // G1 is DEC Special Graphics (line drawing)
+		vt.g1LineDrawing = true
+
+	case ")B": // G1 is US ASCII
+		vt.g1LineDrawing = false
+
+	case "7": // DECSC, save cursor and style
+		vt.savedCursorX = vt.cursorX
+		vt.savedCursorY = vt.cursorY
+		vt.savedSGR = vt.currentSGR.Copy()
+
+	case "8": // DECRC, restore cursor and style
+		vt.cursorX = vt.savedCursorX
+		vt.cursorY = vt.savedCursorY
+		vt.pendingWrap = false
+		if vt.savedSGR != nil {
+			vt.currentSGR = vt.savedSGR.Copy()
+		} else {
+			vt.currentSGR = types.NewSGR()
+		}
+	}
+}
+
+func (vt *VirtualTerminal) handleOSC(token types.Token) {
+	if len(token.Parameters) < 2 {
+		vt.keepRaw(token.Raw)
+		return
+	}
+
+	switch token.Parameters[0] {
+	case "0", "2": // Window title
+		vt.title = token.Parameters[1]
+
+	case "8": // Hyperlink, an empty URI closes it
+		if len(token.Parameters) < 3 || token.Parameters[2] == "" {
+			vt.currentLink = nil
+			return
+		}
+		vt.currentLink = &Hyperlink{Params: token.Parameters[1], URI: token.Parameters[2]}
+
+	default:
+		vt.keepRaw(token.Raw)
+	}
+}
+
+// GetTitle returns the window title set by OSC 0 or OSC 2
+func (vt *VirtualTerminal) GetTitle() string {
+	return vt.title
+}
+
+// CursorVisible reports whether the cursor is shown at the end of the
+// stream (CSI ? 25 h), which is the initial state
+func (vt *VirtualTerminal) CursorVisible() bool {
+	return !vt.cursorHidden
+}
+
+// CursorVisibilityChanges returns the number of times CSI ? 25 h/l showed
+// or hid the cursor, repeated requests for the same state not counted
+func (vt *VirtualTerminal) CursorVisibilityChanges() int {
+	return vt.cursorVisibilityChanges
+}
+
 func (vt *VirtualTerminal) writeText(text string) {
+	lineDrawing := vt.lineDrawing
+	if vt.shiftOut {
+		lineDrawing = vt.g1LineDrawing
+	}
+
 	for _, r := range text {
+		if lineDrawing {
+			r = decSpecialGraphics(r)
+		}
+
+		// Combining marks attach to the previous char, no cell is used
+		if unicode.In(r, unicode.Mn, unicode.Me) && vt.attachCombining(r) {
+			if vt.lastPrinted != "" {
+				vt.lastPrinted += string(r)
+			}
+			continue
+		}
+
+		cells := RuneWidth(r)
+
+		if vt.pendingWrap {
+			vt.softWrap()
+		}
+
+		// A wide char does not fit on the last column
+		if cells == 2 && vt.cursorX >= vt.width-1 {
+			if vt.autoWrap && vt.width > 1 {
+				vt.softWrap()
+			} else {
+				cells = 1
+			}
+		}
+
 		if vt.lastWrapped {
 			vt.lastWrapped = false
 		}
@@ -115,21 +841,42 @@ func (vt *VirtualTerminal) writeText(text string) {
 		}
 
 		if vt.cursorY < vt.height {
+			sgr := vt.cellSGR()
+			if vt.overstrike && vt.backspaced {
+				r = vt.overstrikeChar(r, sgr)
+			}
+			vt.backspaced = false
+
+			vt.splitWideChar(vt.cursorX, vt.cursorY)
 			vt.buffer[vt.cursorY][vt.cursorX] = Cell{
 				Char: r,
-				SGR:  vt.currentSGR.Copy(),
+				SGR:  sgr,
+				Link: vt.currentLink,
+			}
+			vt.lastPrinted = string(r)
+			vt.lastPrintedX, vt.lastPrintedY = vt.cursorX, vt.cursorY
+			if cells == 2 {
+				vt.cursorX++
+				vt.splitWideChar(vt.cursorX, vt.cursorY)
+				vt.buffer[vt.cursorY][vt.cursorX] = Cell{
+					Char:         0x0,
+					SGR:          vt.cellSGR(),
+					Continuation: true,
+					Link:         vt.currentLink,
+				}
 			}
-			vt.cursorX++
-			vt.maxCursorX = max(vt.maxCursorX, vt.cursorX)
 			vt.maxCursorY = max(vt.maxCursorY, vt.cursorY)
-
-			// Width to next line if we've reached the end
-			if vt.cursorX >= vt.width {
-				vt.cursorX = 0
-				vt.cursorY++
+			vt.maxPaintedY = max(vt.maxPaintedY, vt.cursorY)
+			vt.maxPaintedX = max(vt.maxPaintedX, vt.cursorX)
+
+			// The cursor stays on the last column, with autowrap the next
+			// printable char moves it to the next line
+			if vt.cursorX < vt.width-1 {
+				vt.cursorX++
+				vt.maxCursorX = max(vt.maxCursorX, vt.cursorX)
+			} else {
 				vt.maxCursorX = vt.width - 1
-				vt.maxCursorY = max(vt.maxCursorY, vt.cursorY)
-				vt.lastWrapped = true
+				vt.pendingWrap = vt.autoWrap
 			}
 
 			if vt.debugCursor {
@@ -139,11 +886,209 @@ func (vt *VirtualTerminal) writeText(text string) {
 	}
 }
 
+// overstrikeChar merges r with the char under the cursor, setting Bold or
+// Underline on sgr, and returns the char to write. Emphasis already set by a
+// previous overstrike is kept, so "_ BS a BS a" is bold and underlined.
+func (vt *VirtualTerminal) overstrikeChar(r rune, sgr *types.SGR) rune {
+	prev := vt.buffer[vt.cursorY][vt.cursorX]
+	if prev.Continuation || prev.Char == 0x0 {
+		return r
+	}
+
+	switch {
+	case prev.Char == r:
+		sgr.Bold = true
+	case prev.Char == '_':
+		sgr.Underline = true
+	case r == '_':
+		sgr.Underline = true
+		r = prev.Char
+	default:
+		return r
+	}
+
+	sgr.Bold = sgr.Bold || prev.SGR.Bold
+	sgr.Underline = sgr.Underline || prev.SGR.Underline
+
+	return r
+}
+
+// attachCombining appends a combining mark to the last written cell.
+// Right after a printed char that is its cell, wherever the cursor stayed
+// (e.g. on the last column with autowrap off), otherwise the cell left of
+// the cursor.
+// It returns false when there is no char to attach to.
+func (vt *VirtualTerminal) attachCombining(r rune) bool {
+	x, y := vt.cursorX, vt.cursorY
+	if vt.lastPrinted != "" {
+		x, y = vt.lastPrintedX, vt.lastPrintedY
+	} else if !vt.pendingWrap {
+		x--
+	}
+	if x < 0 || x >= vt.width || y >= vt.height {
+		return false
+	}
+
+	if vt.buffer[y][x].Continuation && x > 0 {
+		x--
+	}
+
+	cell := &vt.buffer[y][x]
+	if cell.Char == 0x0 {
+		return false
+	}
+	cell.Combining = append(cell.Combining, r)
+
+	return true
+}
+
+// decGraphics maps 0x60-0x7E to the DEC Special Graphics set
+var decGraphics = [...]rune{
+	'◆', '▒', '␉', '␌', '␍', '␊', '°', '±', '␤', '␋', '┘', '┐', '┌', '└', '┼', '⎺',
+	'⎻', '─', '⎼', '⎽', '├', '┤', '┴', '┬', '│', '≤', '≥', 'π', '≠', '£', '·',
+}
+
+// decSpecialGraphics translates r through the DEC Special Graphics set,
+// chars outside 0x60-0x7E are unchanged.
+func decSpecialGraphics(r rune) rune {
+	if r < 0x60 || r > 0x7E {
+		return r
+	}
+	return decGraphics[r-0x60]
+}
+
+// RuneWidth returns the number of cells used by r, 2 for East Asian wide and
+// fullwidth chars, 1 otherwise.
+func RuneWidth(r rune) int {
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+
+	return 1
+}
+
+// splitWideChar blanks the other half of a wide char about to be overwritten
+// at (x, y), so no orphan half is left in the buffer.
+func (vt *VirtualTerminal) splitWideChar(x, y int) {
+	row := vt.buffer[y]
+
+	if row[x].Continuation && x > 0 {
+		row[x-1] = Cell{Char: 0x0, SGR: row[x-1].SGR}
+	}
+
+	if !row[x].Continuation && x+1 < vt.width && row[x+1].Continuation {
+		row[x+1] = Cell{Char: 0x0, SGR: row[x+1].SGR}
+	}
+}
+
+// cellSGR returns a copy of the current SGR as stored in a written cell.
+// In iCE colors mode, blink is turned into a bright standard background.
+func (vt *VirtualTerminal) cellSGR() *types.SGR {
+	sgr := vt.currentSGR.Copy()
+	if vt.iceColors && sgr.Blink {
+		sgr.Blink = false
+		brightenBackground(sgr)
+	}
+
+	return sgr
+}
+
+// brightenBackground turns a standard background into its bright variant
+func brightenBackground(sgr *types.SGR) {
+	if sgr.BgColor.Type == types.ColorStandard && sgr.BgColor.Index < 8 {
+		sgr.BgColor.Index += 8
+	}
+}
+
+// BlinkMode selects what StripBlink renders in place of blink (SGR 5)
+type BlinkMode int
+
+const (
+	BlinkKeep       BlinkMode = iota // Leave blink untouched
+	BlinkRemove                      // Drop blink
+	BlinkToBold                      // Drop blink, set bold
+	BlinkToBrightBg                  // Drop blink, brighten the background like iCE colors
+)
+
+// StripBlink clears blink from every cell of vt, substituted according to
+// mode, for readers who cannot bear blinking text. Apply it after the
+// tokens and before the export.
+func StripBlink(vt *VirtualTerminal, mode BlinkMode) {
+	if mode == BlinkKeep {
+		return
+	}
+
+	for y := range vt.buffer {
+		for x := range vt.buffer[y] {
+			cell := &vt.buffer[y][x]
+			if cell.SGR == nil || !cell.SGR.Blink {
+				continue
+			}
+
+			sgr := cell.SGR.Copy()
+			sgr.Blink = false
+			switch mode {
+			case BlinkToBold:
+				sgr.Bold = true
+			case BlinkToBrightBg:
+				brightenBackground(sgr)
+			}
+			cell.SGR = sgr
+		}
+	}
+}
+
+// wrapLine performs a deferred wrap to the beginning of the next line.
+func (vt *VirtualTerminal) wrapLine() {
+	vt.pendingWrap = false
+	vt.cursorX = 0
+	vt.cursorY++
+	vt.maxCursorY = max(vt.maxCursorY, vt.cursorY)
+	vt.clampCursorY()
+	vt.lastWrapped = true
+}
+
+// softWrap wraps the text continuing past the last column, and records
+// that the row goes on with the next one. A wrap forced by a control or a
+// cursor move is a line break instead.
+func (vt *VirtualTerminal) softWrap() {
+	if vt.cursorY < vt.height-1 {
+		vt.softWrapped[vt.cursorY] = true
+	}
+	vt.wrapLine()
+}
+
+// clampCursorY keeps the cursor on the last row when it went below, and
+// reports the requested position to the overflow handler.
+func (vt *VirtualTerminal) clampCursorY() {
+	if vt.cursorY < vt.height {
+		return
+	}
+
+	if vt.onOverflow != nil {
+		vt.onOverflow(vt.cursorX, vt.cursorY)
+	}
+	vt.cursorY = vt.height - 1
+}
+
 func (vt *VirtualTerminal) handleC0(code byte) {
 	if vt.debugCursor {
 		fmt.Printf("\nBefore handleC0 Cursor at (%d, %d)\n", vt.cursorX, vt.cursorY)
 	}
 
+	// Charset shifts move nothing, a wrapped line stays wrapped
+	switch code {
+	case 0x0E: // SO (Shift Out), G1 is used
+		vt.shiftOut = true
+		return
+	case 0x0F: // SI (Shift In), back to G0
+		vt.shiftOut = false
+		return
+	case 0x7F: // DEL, tokenized as a control by DeleteAsControl
+		return
+	}
+
 	if vt.ignoreWrapCRLF && vt.lastWrapped {
 		if code == 0x0D {
 			return
@@ -161,30 +1106,42 @@ func (vt *VirtualTerminal) handleC0(code byte) {
 			vt.cursorX = 0
 			vt.cursorY++
 			vt.maxCursorY = max(vt.maxCursorY, vt.cursorY)
+			vt.clampCursorY()
 		}
 
 	case 0x09: // TAB
-		vt.cursorX = ((vt.cursorX / 8) + 1) * 8
+		vt.cursorX = vt.nextTabStop(vt.cursorX)
 		if vt.cursorX >= vt.width {
 			vt.cursorX = 0
 			vt.cursorY++
 			vt.maxCursorY = max(vt.maxCursorY, vt.cursorY)
+			vt.clampCursorY()
 		}
 
 	case 0x0A: // LF (Line Feed)
 		vt.cursorY++
 		vt.maxCursorY = max(vt.maxCursorY, vt.cursorY)
-		if vt.cursorY >= vt.height {
-			vt.cursorY = vt.height - 1
-		}
+		vt.clampCursorY()
 		vt.cursorX = 0
 
+	case 0x0B, 0x0C: // VT (Vertical Tab), FF (Form Feed)
+		if code == 0x0C && vt.formFeedClears {
+			vt.eraseDisplay(2)
+			break
+		}
+
+		// Line feed without carriage return
+		vt.cursorY++
+		vt.maxCursorY = max(vt.maxCursorY, vt.cursorY)
+		vt.clampCursorY()
+
 	case 0x0D: // CR (Carriage Return)
 		vt.cursorX = 0
 
 	case 0x08: // BS (Backspace)
 		if vt.cursorX > 0 {
 			vt.cursorX--
+			vt.backspaced = true
 		}
 	}
 	vt.lastWrapped = false
@@ -197,6 +1154,16 @@ func (vt *VirtualTerminal) handleC0(code byte) {
 
 }
 
+// nextTabStop returns the next tab stop after x, or width when there is none
+func (vt *VirtualTerminal) nextTabStop(x int) int {
+	for next := x + 1; next < vt.width; next++ {
+		if vt.tabStops[next] {
+			return next
+		}
+	}
+	return vt.width
+}
+
 func (vt *VirtualTerminal) handleSGR(params []string) {
 	if vt.debugSGR {
 		fmt.Printf("\nBefore handleSGR Current SGR: '%v'\nNew params: %v\n", vt.currentSGR, params)
@@ -244,6 +1211,7 @@ func (vt *VirtualTerminal) handleCSI(token types.Token) {
 		if len(token.Parameters) > 0 {
 			n, _ = strconv.Atoi(token.Parameters[0])
 		}
+		n = max(n, 1)
 		vt.cursorY = max(0, vt.cursorY-n)
 
 	case 'B': // Cursor Down
@@ -251,13 +1219,16 @@ func (vt *VirtualTerminal) handleCSI(token types.Token) {
 		if len(token.Parameters) > 0 {
 			n, _ = strconv.Atoi(token.Parameters[0])
 		}
+		n = max(n, 1)
 		vt.cursorY += n
+		vt.clampCursorY()
 
 	case 'C': // Cursor Right
 		n := 1
 		if len(token.Parameters) > 0 {
 			n, _ = strconv.Atoi(token.Parameters[0])
 		}
+		n = max(n, 1)
 		vt.cursorX += n
 		if vt.cursorX >= vt.width {
 			vt.cursorX = vt.width - 1
@@ -271,11 +1242,31 @@ func (vt *VirtualTerminal) handleCSI(token types.Token) {
 		if len(token.Parameters) > 0 {
 			n, _ = strconv.Atoi(token.Parameters[0])
 		}
+		n = max(n, 1)
 		vt.cursorX -= n
 		if vt.cursorX < 0 {
 			vt.cursorX = 0
 		}
 
+	case 'E': // Cursor Next Line
+		n := 1
+		if len(token.Parameters) > 0 {
+			n, _ = strconv.Atoi(token.Parameters[0])
+		}
+		n = max(n, 1)
+		vt.cursorX = 0
+		vt.cursorY += n
+		vt.clampCursorY()
+
+	case 'F': // Cursor Previous Line
+		n := 1
+		if len(token.Parameters) > 0 {
+			n, _ = strconv.Atoi(token.Parameters[0])
+		}
+		n = max(n, 1)
+		vt.cursorX = 0
+		vt.cursorY = max(0, vt.cursorY-n)
+
 	case 'H', 'f': // Cursor Position
 		// ESC [ H 	Moves the cursor to line 1, column 1 (Home).
 		// ESC [ 6 H 	Moves the cursor to line 6, column 1.
@@ -287,24 +1278,13 @@ func (vt *VirtualTerminal) handleCSI(token types.Token) {
 			fmt.Printf("Before CSI Cursor Position with params: %v, Cusor at (%d, %d) \n", token.Parameters, vt.cursorX, vt.cursorY)
 		}
 
-		row, col := 1, 1 // default 1,1 in ANSI
-
-		// replace "" by default value (1)
-		for i := 0; i < len(token.Parameters); i++ {
-			if token.Parameters[i] == "" {
-				token.Parameters[i] = "1"
-			}
-		}
+		// default 1,1 in ANSI, an omitted or zero parameter is 1
+		numbers := types.ParseDoubleNumbersParam(token.Parameters, []int{1, 1})
+		row, col := max(1, numbers[0]), max(1, numbers[1])
 
-		if len(token.Parameters) > 1 {
-			row, _ = strconv.Atoi(token.Parameters[0])
-			col, _ = strconv.Atoi(token.Parameters[1])
-		} else if len(token.Parameters) > 0 {
-			row, _ = strconv.Atoi(token.Parameters[0])
-			col = 1
-		}
-		vt.cursorY = max(0, row-1)
-		vt.cursorX = col - 1
+		vt.cursorY = row - 1
+		vt.cursorX = min(col, vt.width) - 1
+		vt.clampCursorY()
 
 		if vt.debugCursor {
 			fmt.Printf("After CSI Cursor Position with params: %v, Cusor at (%d, %d) \n", token.Parameters, vt.cursorY, vt.cursorX)
@@ -323,7 +1303,7 @@ func (vt *VirtualTerminal) handleCSI(token types.Token) {
 		}
 		vt.eraseLine(mode)
 
-	case 'b': // Repeat previous character (REP)
+	case 'X': // Erase Character (ECH)
 		n := 1
 		if len(token.Parameters) > 0 {
 			n, _ = strconv.Atoi(token.Parameters[0])
@@ -331,43 +1311,58 @@ func (vt *VirtualTerminal) handleCSI(token types.Token) {
 		if n < 1 {
 			n = 1
 		}
+		vt.eraseCharacters(n)
 
-		srcX := vt.cursorX - 1
-		srcY := vt.cursorY
-		if srcX < 0 {
-			if vt.cursorY == 0 {
-				break
-			}
-			srcY = vt.cursorY - 1
-			srcX = vt.width - 1
+	case 'b': // Repeat previous character (REP)
+		n := 1
+		if len(token.Parameters) > 0 {
+			n, _ = strconv.Atoi(token.Parameters[0])
+		}
+		if n < 1 {
+			n = 1
 		}
 
-		source := vt.buffer[srcY][srcX]
-		if source.Char == 0x0 {
+		if vt.lastPrinted == "" {
 			break
 		}
 
-		for i := 0; i < n; i++ {
-			if vt.cursorY >= vt.height {
-				break
-			}
+		// Never repeat more than the buffer can hold
+		n = min(n, vt.width*vt.height)
+		vt.writeText(strings.Repeat(vt.lastPrinted, n))
 
-			vt.buffer[vt.cursorY][vt.cursorX] = Cell{
-				Char: source.Char,
-				SGR:  source.SGR.Copy(),
+	case 'h', 'l': // Set/Reset Mode
+		if token.Prefix != "?" {
+			break
+		}
+		for _, p := range token.Parameters {
+			switch p {
+			case "7": // DECAWM
+				vt.autoWrap = lastChar == 'h'
+			case "25": // DECTCEM, cursor visibility
+				if hidden := lastChar == 'l'; hidden != vt.cursorHidden {
+					vt.cursorHidden = hidden
+					vt.cursorVisibilityChanges++
+				}
 			}
-			vt.cursorX++
-			vt.maxCursorX = max(vt.maxCursorX, vt.cursorX)
-			vt.maxCursorY = max(vt.maxCursorY, vt.cursorY)
+		}
 
-			if vt.cursorX >= vt.width {
-				vt.cursorX = 0
-				vt.cursorY++
-				vt.maxCursorX = vt.width - 1
-				vt.maxCursorY = max(vt.maxCursorY, vt.cursorY)
+	case 'g': // Tab Clear (TBC)
+		mode := 0
+		if len(token.Parameters) > 0 {
+			mode, _ = strconv.Atoi(token.Parameters[0])
+		}
+		switch mode {
+		case 0: // Clear the stop at the cursor column
+			if vt.cursorX < vt.width {
+				vt.tabStops[vt.cursorX] = false
 			}
+		case 3: // Clear all stops
+			vt.tabStops = make([]bool, vt.width)
 		}
 
+	case 'n', 'c': // Device status and attributes queries (DSR, DA)
+		// Replies go to the host, nothing is rendered
+
 	case 's': // Save Cursor Position
 		vt.savedCursorX = vt.cursorX
 		vt.savedCursorY = vt.cursorY
@@ -394,6 +1389,7 @@ func (vt *VirtualTerminal) eraseDisplay(mode int) {
 				vt.buffer[y][x] = Cell{Char: 0x0, SGR: types.NewSGR()}
 			}
 		}
+		vt.maxPaintedY = min(vt.maxPaintedY, vt.cursorY)
 	case 1: // Clear from beginning of screen to cursor
 		for y := 0; y <= vt.cursorY; y++ {
 			for x := 0; x < vt.width; x++ {
@@ -409,8 +1405,11 @@ func (vt *VirtualTerminal) eraseDisplay(mode int) {
 				vt.buffer[y][x] = Cell{Char: 0x0, SGR: types.NewSGR()}
 			}
 		}
+		clear(vt.softWrapped)
 		vt.cursorX = 0
 		vt.cursorY = 0
+		vt.maxPaintedY = 0
+		vt.maxPaintedX = 0
 	}
 }
 
@@ -431,6 +1430,119 @@ func (vt *VirtualTerminal) eraseLine(mode int) {
 	}
 }
 
+// eraseCharacters blanks n cells from the cursor without moving it,
+// clamped to the end of the current line.
+func (vt *VirtualTerminal) eraseCharacters(n int) {
+	if vt.cursorY >= vt.height {
+		return
+	}
+
+	end := min(vt.cursorX+n, vt.width)
+	for x := vt.cursorX; x < end; x++ {
+		vt.buffer[vt.cursorY][x] = Cell{Char: 0x0, SGR: types.NewSGR()}
+	}
+}
+
+// ColorHistogram counts the visible cells per foreground color, keyed by
+// ColorValue.String(). Blank cells are skipped, and overwritten cells only
+// count with their final color.
+func (vt *VirtualTerminal) ColorHistogram() map[string]int {
+	histogram := make(map[string]int)
+
+	for y := 0; y < vt.height; y++ {
+		for _, cell := range vt.buffer[y] {
+			if cell.Continuation || cell.Char == 0x0 || cell.Char == ' ' {
+				continue
+			}
+			histogram[cell.SGR.FgColor.String()]++
+		}
+	}
+
+	return histogram
+}
+
+// Compare checks the used area of both buffers cell by cell and describes the
+// first mismatching cell. NUL and space are both blank; cells outside a
+// buffer are blank with the default style. Styles are compared as displayed
+// (SGR.SameDisplay).
+func (vt *VirtualTerminal) Compare(other *VirtualTerminal) (bool, string) {
+	diffs := DiffBuffers(vt, other)
+	if len(diffs) == 0 {
+		return true, ""
+	}
+
+	d := diffs[0]
+	return false, fmt.Sprintf("line %d, column %d: %s != %s", d.Y+1, d.X+1,
+		describeCell(vt.cellAt(d.X, d.Y)), describeCell(other.cellAt(d.X, d.Y)))
+}
+
+// CellDiff is a cell that differs between two buffers, X and Y are 0-based
+type CellDiff struct {
+	X, Y  int
+	AChar rune
+	BChar rune
+	ASGR  *types.SGR
+	BSGR  *types.SGR
+}
+
+// DiffBuffers lists every differing cell in the used area of a and b, row by
+// row, with the same rules as Compare.
+func DiffBuffers(a, b *VirtualTerminal) []CellDiff {
+	rows := max(a.maxCursorY, b.maxCursorY) + 1
+	cols := max(a.width, b.width)
+
+	var diffs []CellDiff
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols; x++ {
+			cellA := a.cellAt(x, y)
+			cellB := b.cellAt(x, y)
+			if sameCell(cellA, cellB) {
+				continue
+			}
+			diffs = append(diffs, CellDiff{
+				X:     x,
+				Y:     y,
+				AChar: cellA.Char,
+				BChar: cellB.Char,
+				ASGR:  cellA.SGR.Copy(),
+				BSGR:  cellB.SGR.Copy(),
+			})
+		}
+	}
+
+	return diffs
+}
+
+func (vt *VirtualTerminal) cellAt(x, y int) Cell {
+	if y >= vt.height || x >= vt.width {
+		return Cell{Char: 0x0, SGR: types.NewSGR()}
+	}
+	return vt.buffer[y][x]
+}
+
+func sameCell(a, b Cell) bool {
+	charA, charB := a.Char, b.Char
+	if charA == 0x0 {
+		charA = ' '
+	}
+	if charB == 0x0 {
+		charB = ' '
+	}
+
+	return charA == charB &&
+		a.Continuation == b.Continuation &&
+		string(a.Combining) == string(b.Combining) &&
+		a.SGR.SameDisplay(b.SGR)
+}
+
+func describeCell(cell Cell) string {
+	char := cell.Char
+	if char == 0x0 {
+		char = ' '
+	}
+	return fmt.Sprintf("%q [%s]", string(char)+string(cell.Combining), cell.SGR.String())
+}
+
 // ExportFlattenedANSI exports the buffer with optimized ANSI codes using differential encoding.
 // Uses ExportSplitTextAndSequences and applies minimal SGR codes at the appropriate positions.
 // The legacyMode ensures ANSI 1990 compatibility by using reset+rebuild
@@ -444,25 +1556,72 @@ func (vt *VirtualTerminal) ExportFlattenedANSIInline() string {
 	return vt.exportFlattenedANSI(true)
 }
 
+// diffToANSI encodes the transition from previous to sgr with the output
+// modes of vt
+func (vt *VirtualTerminal) diffToANSI(sgr, previous *types.SGR) string {
+	if vt.blinkBrightBg {
+		return sgr.DiffToANSIBlinkBrightBg(previous, vt.useVGAColors, vt.legacyMode)
+	}
+	return sgr.DiffToANSI(previous, vt.useVGAColors, vt.legacyMode)
+}
+
 func (vt *VirtualTerminal) exportFlattenedANSI(inline bool) string {
 	lines := vt.ExportSplitTextAndSequences()
 	var builder strings.Builder
 
 	// Track the current SGR state across all lines for differential encoding
 	var currentSGR *types.SGR = nil
+	linkOpen := false
+
+	if vt.title != "" {
+		builder.WriteString("\x1b]2;" + vt.title + "\x1b\\")
+	}
 
 	for _, line := range lines {
 		var lineBuilder strings.Builder
 		textRunes := []rune(line.Text)
 
 		seqIndex := 0
+		linkIndex := 0
+		rawIndex := 0
+
+		// Re-establish the style from the default state at the line start
+		if vt.lineIndependent && !inline {
+			lineSGR := currentSGR
+			if len(line.Sequences) > 0 && line.Sequences[0].Position == 0 {
+				lineSGR = line.Sequences[0].SGR
+				seqIndex++
+			}
+			if lineSGR == nil {
+				lineSGR = types.NewSGR()
+			}
+
+			lineBuilder.WriteString("\x1b[0m")
+			lineBuilder.WriteString(vt.diffToANSI(lineSGR, types.NewSGR()))
+			currentSGR = lineSGR.Copy()
+		}
+
 		for i, r := range textRunes {
+			// Sequences kept verbatim come before anything else at this position
+			for rawIndex < len(line.Raws) && line.Raws[rawIndex].Position == i {
+				lineBuilder.WriteString(line.Raws[rawIndex].Raw)
+				rawIndex++
+			}
+
+			// Check if there's a hyperlink change at this position
+			if linkIndex < len(line.Links) && line.Links[linkIndex].Position == i {
+				link := line.Links[linkIndex]
+				lineBuilder.WriteString("\x1b]8;" + link.Params + ";" + link.URI + "\x1b\\")
+				linkOpen = link.URI != ""
+				linkIndex++
+			}
+
 			// Check if there's a SGR change at this position
 			if seqIndex < len(line.Sequences) && line.Sequences[seqIndex].Position == i {
 				newSGR := line.Sequences[seqIndex].SGR
 
-				// Generate differential ANSI sequence (legacyMode=true for ANSI 1990 compatibility)
-				diffSequence := newSGR.DiffToANSI(currentSGR, vt.useVGAColors, true)
+				// Generate differential ANSI sequence (legacy mode for ANSI 1990 compatibility)
+				diffSequence := vt.diffToANSI(newSGR, currentSGR)
 				if diffSequence != "" {
 					lineBuilder.WriteString(diffSequence)
 				}
@@ -475,6 +1634,10 @@ func (vt *VirtualTerminal) exportFlattenedANSI(inline bool) string {
 			lineBuilder.WriteRune(r)
 		}
 
+		for _, raw := range line.Raws[rawIndex:] {
+			lineBuilder.WriteString(raw.Raw)
+		}
+
 		lineText := lineBuilder.String()
 		if vt.outputEncoding == "utf8" {
 			lineText = strings.ReplaceAll(lineText, "\x00", " ")
@@ -487,6 +1650,10 @@ func (vt *VirtualTerminal) exportFlattenedANSI(inline bool) string {
 		}
 	}
 
+	if linkOpen {
+		builder.WriteString("\x1b]8;;\x1b\\")
+	}
+
 	// Reset at the end only if not already at default state
 	if !currentSGR.Equals(types.NewSGR()) {
 		builder.WriteString("\x1b[0m")
@@ -507,53 +1674,198 @@ func (vt *VirtualTerminal) ExportPlainTextInline() string {
 }
 
 func (vt *VirtualTerminal) exportPlainText(inline bool) string {
+	if inline {
+		return strings.Join(vt.plainTextLines(false), "")
+	}
+
+	return vt.ExportPlainTextWithOptions(DefaultPlainTextOptions())
+}
+
+// PlainTextOptions configures ExportPlainTextWithOptions
+type PlainTextOptions struct {
+	LineEnding      string // Line separator ("\n" when empty, "\r\n" for Windows)
+	TrailingNewline bool   // End the last line with LineEnding
+	Trim            bool   // Remove trailing blanks, like ExportPlainTextTrimmed
+	TabWidth        int    // Tab stops when rendering tokens (8 when 0), see SetTabWidth
+	Overstrike      bool   // Merge "x BS _" into the char when rendering tokens, see SetOverstrike
+	Title           bool   // Start with the window title (OSC 0/2) and a blank line, when set
+	Inline          bool   // Join the lines without separator nor trailing newline, like ExportPlainTextInline
+}
+
+// DefaultPlainTextOptions returns the options used by ExportPlainText
+func DefaultPlainTextOptions() PlainTextOptions {
+	return PlainTextOptions{
+		LineEnding:      "\n",
+		TrailingNewline: true,
+	}
+}
+
+// ExportPlainTextWithOptions exports the buffer as plain text with a custom
+// line ending. The number of lines is the same as ExportPlainText.
+func (vt *VirtualTerminal) ExportPlainTextWithOptions(opts PlainTextOptions) string {
+	lineEnding := opts.LineEnding
+	if lineEnding == "" {
+		lineEnding = "\n"
+	}
+
+	separator := lineEnding
+	if opts.Inline {
+		separator = ""
+	}
+
+	lines := vt.plainTextLines(opts.Trim)
+	text := strings.Join(lines, separator)
+	if opts.TrailingNewline && !opts.Inline && len(lines) > 0 {
+		text += lineEnding
+	}
+
+	if opts.Title && vt.title != "" {
+		text = vt.title + lineEnding + lineEnding + text
+	}
+
+	return text
+}
+
+// plainTextLines returns the text of each buffer line, optionally trimmed
+func (vt *VirtualTerminal) plainTextLines(trim bool) []string {
 	lines := vt.ExportSplitTextAndSequences()
 
+	texts := make([]string, 0, len(lines))
+	for y, line := range lines {
+		if !trim {
+			texts = append(texts, line.Text)
+			continue
+		}
+
+		runes := []rune(line.Text)
+
+		// Rune index just after the last cell to keep
+		end, pos := 0, 0
+		for _, cell := range vt.buffer[y] {
+			if cell.Continuation {
+				continue
+			}
+			pos += 1 + len(cell.Combining)
+			if !isTrailingBlank(cell) {
+				end = pos
+			}
+		}
+
+		texts = append(texts, string(runes[:end]))
+	}
+
+	return texts
+}
+
+// sameLink reports whether two cells belong to the same hyperlink
+func sameLink(a, b *Hyperlink) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// ExportPlainTextTrimmed exports the buffer as plain text with trailing spaces
+// removed from each line. Blanks with a visible background are kept.
+func (vt *VirtualTerminal) ExportPlainTextTrimmed() string {
+	opts := DefaultPlainTextOptions()
+	opts.Trim = true
+	return vt.ExportPlainTextWithOptions(opts)
+}
+
+// ExportLayout exports the buffer as plain text, one line of width columns
+// per row, with every empty cell written as a space whatever the output
+// encoding. Nothing is trimmed, so overlays and diagrams keep their alignment.
+func (vt *VirtualTerminal) ExportLayout() string {
 	var builder strings.Builder
-	for _, line := range lines {
-		builder.WriteString(line.Text)
 
-		if !inline {
-			builder.WriteString("\n")
+	for y := 0; y <= min(vt.maxPaintedY, vt.height-1); y++ {
+		for _, cell := range vt.buffer[y] {
+			if cell.Continuation {
+				continue
+			}
+
+			char := cell.Char
+			if char == 0x0 {
+				char = ' '
+			}
+			builder.WriteRune(char)
+
+			for _, mark := range cell.Combining {
+				builder.WriteRune(mark)
+			}
 		}
+		builder.WriteByte('\n')
 	}
 
 	return builder.String()
 }
 
+// isTrailingBlank reports whether a cell can be trimmed at the end of a line
+func isTrailingBlank(cell Cell) bool {
+	if cell.Char != 0x0 && cell.Char != ' ' {
+		return false
+	}
+
+	// Styled blank (e.g. a colored bar), keep it
+	if cell.SGR.Reverse || cell.SGR.BgColor != types.NewSGR().BgColor {
+		return false
+	}
+
+	return true
+}
+
 // ExportSplitTextAndSequences exports the buffer as separate text and sequences
 // Returns a slice of LineWithSequences, each containing the plain text and SGR changes
+// Lines stop at the last row written to, so a trailing line of styled spaces is kept
 func (vt *VirtualTerminal) ExportSplitTextAndSequences() []types.LineWithSequences {
 	result := []types.LineWithSequences{}
 	var currentSGR *types.SGR = nil
+	var currentLink *Hyperlink = nil
 
-	maxCursorY := 0
-	for y := 0; y < vt.height; y++ {
-
-		// Check if line has content
-		for x := 0; x < vt.width; x++ {
-			if vt.buffer[y][x].Char != 0x0 {
-				maxCursorY = max(maxCursorY, y)
-				break
-			}
-		}
+	lastY := min(vt.maxPaintedY, vt.height-1)
+	raws := vt.rawSequencesByRow(lastY)
 
+	for y := 0; y <= lastY; y++ {
 		line := types.LineWithSequences{
 			Text:      "",
 			Sequences: []types.SGRSequence{},
 		}
 
 		var textBuilder strings.Builder
+		rowRaws := raws[y]
 
+		// Position in runes, wide chars use one rune for two cells
+		pos := 0
 		for x := 0; x < vt.width; x++ {
 			cell := vt.buffer[y][x]
 
 			// fmt.Printf("Processing cell at (%d, %d): Char='%c' SGR='%v'\n", x, y, cell.Char, cell.SGR)
 
+			for len(rowRaws) > 0 && rowRaws[0].x <= x {
+				line.Raws = append(line.Raws, types.RawSequence{Position: pos, Raw: rowRaws[0].raw})
+				rowRaws = rowRaws[1:]
+			}
+
+			if cell.Continuation {
+				continue
+			}
+
+			// Detect hyperlink change
+			if !sameLink(cell.Link, currentLink) {
+				link := types.LinkSequence{Position: pos}
+				if cell.Link != nil {
+					link.Params = cell.Link.Params
+					link.URI = cell.Link.URI
+				}
+				line.Links = append(line.Links, link)
+				currentLink = cell.Link
+			}
+
 			// Detect SGR change
 			if !cell.SGR.Equals(currentSGR) {
 				line.Sequences = append(line.Sequences, types.SGRSequence{
-					Position: x,
+					Position: pos,
 					SGR:      cell.SGR.Copy(),
 				})
 				currentSGR = cell.SGR.Copy()
@@ -568,6 +1880,16 @@ func (vt *VirtualTerminal) ExportSplitTextAndSequences() []types.LineWithSequenc
 			}
 
 			textBuilder.WriteRune(char)
+			pos++
+
+			for _, mark := range cell.Combining {
+				textBuilder.WriteRune(mark)
+				pos++
+			}
+		}
+
+		for _, raw := range rowRaws {
+			line.Raws = append(line.Raws, types.RawSequence{Position: pos, Raw: raw.raw})
 		}
 
 		line.Text = textBuilder.String()
@@ -575,5 +1897,25 @@ func (vt *VirtualTerminal) ExportSplitTextAndSequences() []types.LineWithSequenc
 		result = append(result, line)
 	}
 
-	return result[:maxCursorY+1]
+	return result
+}
+
+// rawSequencesByRow groups the kept sequences by row, sorted by column.
+// Sequences below lastY end the last line.
+func (vt *VirtualTerminal) rawSequencesByRow(lastY int) map[int][]rawSequence {
+	rows := make(map[int][]rawSequence)
+	for _, raw := range vt.rawSequences {
+		if raw.y > lastY {
+			raw.x, raw.y = vt.width, lastY
+		}
+		rows[raw.y] = append(rows[raw.y], raw)
+	}
+
+	for _, row := range rows {
+		sort.SliceStable(row, func(i, j int) bool {
+			return row[i].x < row[j].x
+		})
+	}
+
+	return rows
 }
@@ -33,3 +33,1349 @@ func TestWriteTextDoesNotInsertExtraBlankLineOnExactWidth(t *testing.T) {
 		t.Fatalf("expected second line 'def', got %q", lines[1].Text)
 	}
 }
+
+func TestEraseCharacterBlanksCellsWithoutMovingCursor(t *testing.T) {
+	vt := NewVirtualTerminal(10, 2, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenText, Value: "HELLO"},
+		{Type: types.TokenCSI, Raw: "\x1b[5D", Parameters: []string{"5"}},
+		{Type: types.TokenCSI, Raw: "\x1b[3X", Parameters: []string{"3"}},
+		{Type: types.TokenText, Value: "J"},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	lines := vt.ExportSplitTextAndSequences()
+	if got := strings.TrimRight(lines[0].Text, " "); got != "J  LO" {
+		t.Fatalf("expected first line 'J  LO', got %q", got)
+	}
+}
+
+func TestAutoWrapModeAtRightMargin(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		expected []string
+	}{
+		{"Autowrap on", "\x1b[?7h", []string{"abc", "de"}},
+		{"Autowrap off", "\x1b[?7l", []string{"abe"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vt := NewVirtualTerminal(3, 4, "utf8", false)
+
+			tokens := []types.Token{
+				{Type: types.TokenCSI, Raw: tt.mode, Parameters: []string{"7"}, Prefix: "?"},
+				{Type: types.TokenText, Value: "abcde"},
+			}
+
+			if err := vt.ApplyTokens(tokens); err != nil {
+				t.Fatalf("unexpected apply error: %v", err)
+			}
+
+			lines := vt.ExportSplitTextAndSequences()
+			if len(lines) != len(tt.expected) {
+				t.Fatalf("expected %d lines, got %d", len(tt.expected), len(lines))
+			}
+			for i, want := range tt.expected {
+				if got := strings.TrimRight(lines[i].Text, " "); got != want {
+					t.Errorf("line %d: expected %q, got %q", i, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestAutoWrapIsDeferredUntilNextPrintableChar(t *testing.T) {
+	vt := NewVirtualTerminal(3, 4, "utf8", false)
+
+	if err := vt.ApplyTokens([]types.Token{{Type: types.TokenText, Value: "abc"}}); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	if vt.cursorX != 2 || vt.cursorY != 0 {
+		t.Fatalf("expected cursor on last column (2, 0), got (%d, %d)", vt.cursorX, vt.cursorY)
+	}
+	if !vt.pendingWrap {
+		t.Fatalf("expected a pending wrap")
+	}
+}
+
+func TestRGBColorSurvivesToFlattenedANSI(t *testing.T) {
+	vt := NewVirtualTerminal(10, 2, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenSGR, Parameters: []string{"38", "2", "255", "100", "50"}},
+		{Type: types.TokenText, Value: "X"},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	want := types.ColorValue{Type: types.ColorRGB, R: 255, G: 100, B: 50, A: 255}
+	if got := vt.buffer[0][0].SGR.FgColor; got != want {
+		t.Fatalf("expected foreground %v, got %v", want, got)
+	}
+
+	if out := vt.ExportFlattenedANSI(); !strings.Contains(out, "38;2;255;100;50") {
+		t.Fatalf("expected RGB sequence in %q", out)
+	}
+}
+
+func TestNewVirtualTerminalAutoKeepsAllLines(t *testing.T) {
+	tokens := []types.Token{}
+	for i := 0; i < 1500; i++ {
+		tokens = append(tokens,
+			types.Token{Type: types.TokenText, Value: "line"},
+			types.Token{Type: types.TokenC0, C0Code: 0x0D},
+			types.Token{Type: types.TokenC0, C0Code: 0x0A},
+		)
+	}
+	tokens = append(tokens, types.Token{Type: types.TokenText, Value: "last"})
+
+	vt := NewVirtualTerminalAuto(tokens, 80, "utf8", false)
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	lines := vt.ExportSplitTextAndSequences()
+	if got := len(lines); got != 1501 {
+		t.Fatalf("expected 1501 lines, got %d", got)
+	}
+
+	if got := strings.TrimRight(lines[1500].Text, " "); got != "last" {
+		t.Fatalf("expected last line 'last', got %q", got)
+	}
+}
+
+func TestEstimateHeightFollowsCursorMoves(t *testing.T) {
+	tokens := []types.Token{
+		{Type: types.TokenText, Value: "abcdef"}, // wraps once at width 4
+		{Type: types.TokenCSI, Raw: "\x1b[3B", Parameters: []string{"3"}},
+		{Type: types.TokenCSI, Raw: "\x1b[2;1H", Parameters: []string{"2", "1"}},
+	}
+
+	if got := EstimateHeight(tokens, 4, 8); got != 6 {
+		t.Fatalf("expected height 6, got %d", got)
+	}
+}
+
+func TestEstimateHeightCountsCells(t *testing.T) {
+	tests := []struct {
+		name     string
+		tokens   []types.Token
+		tabWidth int
+		want     int
+	}{
+		{"Wide chars wrap every two chars", []types.Token{
+			{Type: types.TokenText, Value: strings.Repeat("漢", 10)},
+		}, 8, 7},
+		{"REP repeats the last char", []types.Token{
+			{Type: types.TokenText, Value: "a"},
+			{Type: types.TokenCSI, Raw: "\x1b[9b", Parameters: []string{"9"}},
+		}, 8, 4},
+		{"TAB past the last stop wraps", []types.Token{
+			{Type: types.TokenText, Value: "ab"},
+			{Type: types.TokenC0, C0Code: 0x09},
+		}, 8, 3},
+		{"TAB to a stop of the tab width", []types.Token{
+			{Type: types.TokenText, Value: "a"},
+			{Type: types.TokenC0, C0Code: 0x09},
+			{Type: types.TokenText, Value: "b"},
+		}, 2, 2},
+		{"Tab width defaults to 8", []types.Token{
+			{Type: types.TokenText, Value: "a"},
+			{Type: types.TokenC0, C0Code: 0x09},
+			{Type: types.TokenText, Value: "b"},
+		}, 0, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EstimateHeight(tt.tokens, 4, tt.tabWidth); got != tt.want {
+				t.Fatalf("expected height %d, got %d", tt.want, got)
+			}
+		})
+	}
+
+	// The estimated terminal holds every wrapped row of wide chars
+	tokens := []types.Token{{Type: types.TokenText, Value: strings.Repeat("漢", 10)}}
+	vt := NewVirtualTerminalAuto(tokens, 4, "utf8", false)
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+	if got := strings.Count(vt.ExportPlainText(), "漢"); got != 10 {
+		t.Fatalf("expected 10 wide chars, got %d", got)
+	}
+}
+
+func TestEstimateHeightIsBounded(t *testing.T) {
+	tokens := []types.Token{
+		{Type: types.TokenCSI, Raw: "\x1b[99999999B", Parameters: []string{"99999999"}},
+		{Type: types.TokenCSI, Raw: "\x1b[99999999E", Parameters: []string{"99999999"}},
+		{Type: types.TokenC0, C0Code: 0x0A},
+	}
+
+	if got := EstimateHeight(tokens, 80, 8); got != maxEstimatedHeight {
+		t.Fatalf("expected height %d, got %d", maxEstimatedHeight, got)
+	}
+}
+
+func TestICEColorsPromoteBlinkToBrightBackground(t *testing.T) {
+	tests := []struct {
+		name      string
+		ice       bool
+		wantBlink bool
+		wantBg    uint8
+	}{
+		{"Standard", false, true, 4},
+		{"iCE colors", true, false, 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vt := NewVirtualTerminal(4, 1, "utf8", false)
+			vt.SetICEColors(tt.ice)
+
+			tokens := []types.Token{
+				{Type: types.TokenSGR, Parameters: []string{"5", "44"}},
+				{Type: types.TokenText, Value: "X"},
+			}
+			if err := vt.ApplyTokens(tokens); err != nil {
+				t.Fatalf("unexpected apply error: %v", err)
+			}
+
+			sgr := vt.buffer[0][0].SGR
+			if sgr.Blink != tt.wantBlink {
+				t.Errorf("expected blink %v, got %v", tt.wantBlink, sgr.Blink)
+			}
+			if sgr.BgColor.Index != tt.wantBg {
+				t.Errorf("expected background index %d, got %d", tt.wantBg, sgr.BgColor.Index)
+			}
+		})
+	}
+}
+
+func TestResizeKeepsCells(t *testing.T) {
+	vt := NewVirtualTerminal(3, 3, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenText, Value: "abcdefg"},
+		{Type: types.TokenSGR, Parameters: []string{"31"}},
+		{Type: types.TokenText, Value: "hi"},
+	}
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	vt.Resize(5, 5)
+
+	for y, row := range []string{"abc", "def", "ghi"} {
+		for x, want := range row {
+			if got := vt.buffer[y][x].Char; got != want {
+				t.Errorf("expected %q at (%d, %d), got %q", want, x, y, got)
+			}
+		}
+		for x := 3; x < 5; x++ {
+			if vt.buffer[y][x].Char != 0 {
+				t.Errorf("expected a blank cell at (%d, %d), got %q", x, y, vt.buffer[y][x].Char)
+			}
+		}
+	}
+	if vt.buffer[4][4].Char != 0 || vt.buffer[4][4].SGR == nil {
+		t.Errorf("expected a blank styled cell in the new rows, got %+v", vt.buffer[4][4])
+	}
+	if vt.buffer[2][2].SGR.FgColor.Index != 1 {
+		t.Errorf("expected the cell style kept, got %v", vt.buffer[2][2].SGR)
+	}
+
+	// The pending wrap at column 2 is dropped, the style is kept
+	if err := vt.ApplyTokens([]types.Token{{Type: types.TokenText, Value: "X"}}); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+	if got := vt.buffer[2][2]; got.Char != 'X' || got.SGR.FgColor.Index != 1 {
+		t.Errorf("expected a red X at (2, 2), got %q %v", got.Char, got.SGR)
+	}
+
+	vt.Resize(2, 2)
+	if got := vt.ExportPlainText(); got != "ab\nde\n" {
+		t.Errorf("expected the buffer clipped to 2x2, got %q", got)
+	}
+	if x, y := vt.CursorPosition(); x != 1 || y != 1 {
+		t.Errorf("expected the cursor clamped to (1, 1), got (%d, %d)", x, y)
+	}
+}
+
+func TestReflow(t *testing.T) {
+	tokens := []types.Token{
+		{Type: types.TokenSGR, Parameters: []string{"31"}},
+		{Type: types.TokenText, Value: "0123456789AB"},
+		{Type: types.TokenSGR, Parameters: []string{"0"}},
+		{Type: types.TokenC0, C0Code: 0x0D},
+		{Type: types.TokenC0, C0Code: 0x0A},
+		{Type: types.TokenText, Value: "end"},
+	}
+
+	vt, err := Reflow(tokens, 10, 5, 5)
+	if err != nil {
+		t.Fatalf("unexpected reflow error: %v", err)
+	}
+
+	if got := vt.ExportPlainTextTrimmed(); got != "01234\n56789\nAB\nend\n" {
+		t.Fatalf("expected the long line rewrapped on 3 rows, got %q", got)
+	}
+	if vt.GetWidth() != 5 || vt.height != 4 {
+		t.Fatalf("expected a 5x4 terminal, got %dx%d", vt.GetWidth(), vt.height)
+	}
+	for y := range 3 {
+		if sgr := vt.buffer[y][0].SGR; sgr.FgColor.Index != 1 {
+			t.Errorf("expected row %d red, got %v", y, sgr)
+		}
+	}
+	if sgr := vt.buffer[3][0].SGR; !sgr.Equals(types.NewSGR()) {
+		t.Errorf("expected the last row unstyled, got %v", sgr)
+	}
+
+	// A full row ended by CR LF is not joined with the next one
+	fullRow := []types.Token{
+		{Type: types.TokenText, Value: "0123456789"},
+		{Type: types.TokenC0, C0Code: 0x0D},
+		{Type: types.TokenC0, C0Code: 0x0A},
+		{Type: types.TokenText, Value: "xy"},
+	}
+	vt, err = Reflow(fullRow, 10, 4, 5)
+	if err != nil {
+		t.Fatalf("unexpected reflow error: %v", err)
+	}
+	if got := vt.ExportPlainTextTrimmed(); got != "0123\n4567\n89\nxy\n" {
+		t.Fatalf("expected the hard line break kept, got %q", got)
+	}
+
+	if _, err := Reflow(tokens, 10, 0, 5); err == nil {
+		t.Errorf("expected an error for a zero width")
+	}
+	if _, err := Reflow(tokens, 10, 5, 0); err == nil {
+		t.Errorf("expected an error for a zero height")
+	}
+}
+
+func TestDeleteControlRendersNothing(t *testing.T) {
+	vt := NewVirtualTerminal(6, 1, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenText, Value: "abc"},
+		{Type: types.TokenC0, C0Code: 0x7F},
+		{Type: types.TokenText, Value: "d"},
+	}
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	if got := vt.ExportPlainTextTrimmed(); got != "abcd\n" {
+		t.Fatalf("expected DEL to render nothing, got %q", got)
+	}
+}
+
+func TestStripBlink(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      BlinkMode
+		wantBlink bool
+		wantBold  bool
+		wantBg    uint8
+	}{
+		{"Keep", BlinkKeep, true, false, 4},
+		{"Remove", BlinkRemove, false, false, 4},
+		{"ToBold", BlinkToBold, false, true, 4},
+		{"ToBrightBg", BlinkToBrightBg, false, false, 12},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vt := NewVirtualTerminal(4, 1, "utf8", false)
+
+			tokens := []types.Token{
+				{Type: types.TokenSGR, Parameters: []string{"5", "44"}},
+				{Type: types.TokenText, Value: "XY"},
+				{Type: types.TokenSGR, Parameters: []string{"0"}},
+				{Type: types.TokenText, Value: "Z"},
+			}
+			if err := vt.ApplyTokens(tokens); err != nil {
+				t.Fatalf("unexpected apply error: %v", err)
+			}
+			StripBlink(vt, tt.mode)
+
+			for x := range 2 {
+				sgr := vt.buffer[0][x].SGR
+				if sgr.Blink != tt.wantBlink {
+					t.Errorf("cell %d: expected blink %v, got %v", x, tt.wantBlink, sgr.Blink)
+				}
+				if sgr.Bold != tt.wantBold {
+					t.Errorf("cell %d: expected bold %v, got %v", x, tt.wantBold, sgr.Bold)
+				}
+				if sgr.BgColor.Index != tt.wantBg {
+					t.Errorf("cell %d: expected background index %d, got %d", x, tt.wantBg, sgr.BgColor.Index)
+				}
+			}
+
+			if !vt.buffer[0][2].SGR.Equals(types.NewSGR()) {
+				t.Errorf("expected the unblinking cell untouched, got %v", vt.buffer[0][2].SGR)
+			}
+		})
+	}
+}
+
+func TestWideCharsUseTwoCells(t *testing.T) {
+	vt := NewVirtualTerminal(6, 2, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenText, Value: "a漢"},
+		{Type: types.TokenSGR, Parameters: []string{"31"}},
+		{Type: types.TokenText, Value: "b"},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	if vt.cursorX != 4 {
+		t.Fatalf("expected cursor at column 4, got %d", vt.cursorX)
+	}
+
+	if !vt.buffer[0][2].Continuation {
+		t.Fatalf("expected a continuation cell at column 2")
+	}
+
+	if vt.buffer[0][3].Char != 'b' {
+		t.Fatalf("expected 'b' at column 3, got %q", vt.buffer[0][3].Char)
+	}
+
+	lines := vt.ExportSplitTextAndSequences()
+	if got := lines[0].Text; got != "a漢b  " {
+		t.Fatalf("expected text 'a漢b  ', got %q", got)
+	}
+
+	// Positions are rune indexes in the text
+	red := lines[0].Sequences[1]
+	if red.Position != 2 || red.SGR.FgColor.Index != 1 {
+		t.Fatalf("expected red to start at rune 2, got %d (%v)", red.Position, red.SGR)
+	}
+}
+
+func TestWideCharWrapsWhenNotFitting(t *testing.T) {
+	vt := NewVirtualTerminal(4, 2, "utf8", false)
+
+	if err := vt.ApplyTokens([]types.Token{{Type: types.TokenText, Value: "abc漢"}}); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	lines := vt.ExportSplitTextAndSequences()
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	if lines[0].Text != "abc " || lines[1].Text != "漢  " {
+		t.Fatalf("unexpected lines %q, %q", lines[0].Text, lines[1].Text)
+	}
+}
+
+func TestOverwritingWideCharLeavesNoOrphan(t *testing.T) {
+	vt := NewVirtualTerminal(4, 1, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenText, Value: "漢"},
+		{Type: types.TokenC0, C0Code: 0x0D},
+		{Type: types.TokenText, Value: "x"},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	if vt.buffer[0][1].Continuation {
+		t.Fatalf("expected the continuation cell to be cleared")
+	}
+
+	if got := vt.ExportPlainText(); got != "x   \n" {
+		t.Fatalf("expected 'x   ', got %q", got)
+	}
+}
+
+func TestCombiningMarksAttachToPreviousCell(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"Precomposed", "caf\u00e9!"},
+		{"Decomposed", "cafe\u0301!"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vt := NewVirtualTerminal(6, 1, "utf8", false)
+
+			if err := vt.ApplyTokens([]types.Token{{Type: types.TokenText, Value: tt.input}}); err != nil {
+				t.Fatalf("unexpected apply error: %v", err)
+			}
+
+			if vt.cursorX != 5 {
+				t.Fatalf("expected cursor at column 5, got %d", vt.cursorX)
+			}
+			if vt.buffer[0][4].Char != '!' {
+				t.Fatalf("expected '!' at column 4, got %q", vt.buffer[0][4].Char)
+			}
+
+			if got, want := vt.ExportPlainText(), tt.input+" \n"; got != want {
+				t.Fatalf("expected plain text %q, got %q", want, got)
+			}
+
+			if got := vt.ExportFlattenedANSI(); !strings.Contains(got, tt.input) {
+				t.Fatalf("expected %q in ANSI output %q", tt.input, got)
+			}
+		})
+	}
+}
+
+func TestCombiningMarkAtLastColumnWithoutAutowrap(t *testing.T) {
+	vt := NewVirtualTerminal(3, 1, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenCSI, Raw: "\x1b[?7l", Parameters: []string{"7"}, Prefix: "?"},
+		{Type: types.TokenText, Value: "abe\u0301"},
+	}
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	// The cursor stays on the e, the mark goes to it and not to the b
+	if len(vt.buffer[0][1].Combining) != 0 {
+		t.Fatalf("expected no mark on b, got %q", vt.buffer[0][1].Combining)
+	}
+	if got := string(vt.buffer[0][2].Combining); got != "\u0301" {
+		t.Fatalf("expected the mark on e, got %q", got)
+	}
+}
+
+func TestCombiningMarkWithoutBaseUsesACell(t *testing.T) {
+	vt := NewVirtualTerminal(4, 1, "utf8", false)
+
+	if err := vt.ApplyTokens([]types.Token{{Type: types.TokenText, Value: "\u0301a"}}); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	if vt.buffer[0][1].Char != 'a' {
+		t.Fatalf("expected 'a' at column 1, got %q", vt.buffer[0][1].Char)
+	}
+}
+
+func TestApplyTokensWithCallbackOnClearScreen(t *testing.T) {
+	vt := NewVirtualTerminal(5, 1, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenText, Value: "one"},
+		{Type: types.TokenCSI, Raw: "\x1b[2J", Parameters: []string{"2"}},
+		{Type: types.TokenText, Value: "two"},
+		{Type: types.TokenCSI, Raw: "\x1b[J", Parameters: []string{}},
+		{Type: types.TokenCSI, Raw: "\x1b[2J", Parameters: []string{"2"}},
+		{Type: types.TokenText, Value: "three"},
+	}
+
+	frames := []string{}
+	err := vt.ApplyTokensWithCallback(tokens, func(vt *VirtualTerminal) {
+		frames = append(frames, strings.TrimRight(vt.ExportPlainTextInline(), " "))
+	})
+	if err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	if len(frames) != 2 {
+		t.Fatalf("expected 2 frames, got %d", len(frames))
+	}
+	if frames[0] != "one" || frames[1] != "two" {
+		t.Fatalf("unexpected frames %q", frames)
+	}
+
+	if got := vt.ExportPlainTextInline(); got != "three" {
+		t.Fatalf("expected last frame 'three' in the buffer, got %q", got)
+	}
+}
+
+func TestApplyTokensWithFramesEveryNTokens(t *testing.T) {
+	vt := NewVirtualTerminal(10, 1, "utf8", false)
+
+	tokens := make([]types.Token, 7)
+	for i := range tokens {
+		tokens[i] = types.Token{Type: types.TokenText, Value: "x"}
+	}
+
+	count := 0
+	err := vt.ApplyTokensWithFrames(tokens, FrameEveryNTokens(3), func(*VirtualTerminal) {
+		count++
+	})
+	if err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	if count != 2 {
+		t.Fatalf("expected 2 frames, got %d", count)
+	}
+}
+
+func TestHyperlinkSurvivesFlattening(t *testing.T) {
+	vt := NewVirtualTerminal(12, 1, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenText, Value: "see "},
+		{Type: types.TokenOSC, Parameters: []string{"8", "id=1", "https://example.com"}},
+		{Type: types.TokenText, Value: "here"},
+		{Type: types.TokenOSC, Parameters: []string{"8", "", ""}},
+		{Type: types.TokenText, Value: " now"},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	got := vt.ExportFlattenedANSI()
+	want := "see \x1b]8;id=1;https://example.com\x1b\\here\x1b]8;;\x1b\\ now"
+	if !strings.Contains(got, want) {
+		t.Fatalf("expected hyperlink %q in %q", want, got)
+	}
+
+	if plain := vt.ExportPlainText(); plain != "see here now\n" {
+		t.Fatalf("expected links stripped from plain text, got %q", plain)
+	}
+}
+
+func TestUnclosedHyperlinkIsClosedAtEnd(t *testing.T) {
+	vt := NewVirtualTerminal(4, 1, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenOSC, Parameters: []string{"8", "", "https://example.com"}},
+		{Type: types.TokenText, Value: "link"},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	got := vt.ExportFlattenedANSI()
+	if !strings.HasPrefix(got, "\x1b]8;;https://example.com\x1b\\") || !strings.HasSuffix(got, "link\n\x1b]8;;\x1b\\") {
+		t.Fatalf("expected an opened and closed hyperlink, got %q", got)
+	}
+}
+
+func TestWindowTitleIsLeadingSequence(t *testing.T) {
+	vt := NewVirtualTerminal(2, 1, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenOSC, Parameters: []string{"2", "My Art"}},
+		{Type: types.TokenText, Value: "hi"},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	if vt.GetTitle() != "My Art" {
+		t.Fatalf("expected title 'My Art', got %q", vt.GetTitle())
+	}
+
+	if got := vt.ExportFlattenedANSI(); !strings.HasPrefix(got, "\x1b]2;My Art\x1b\\") {
+		t.Fatalf("expected leading title sequence, got %q", got)
+	}
+}
+
+func TestColorHistogramCountsFinalColors(t *testing.T) {
+	vt := NewVirtualTerminal(10, 2, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenSGR, Parameters: []string{"31"}},
+		{Type: types.TokenText, Value: "red red"},
+		{Type: types.TokenC0, C0Code: 0x0D},
+		{Type: types.TokenSGR, Parameters: []string{"38", "5", "123"}},
+		{Type: types.TokenText, Value: "ab"},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	expected := map[string]int{
+		"std:1":   4,
+		"idx:123": 2,
+	}
+
+	got := vt.ColorHistogram()
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for color, count := range expected {
+		if got[color] != count {
+			t.Errorf("expected %d cells for %s, got %d", count, color, got[color])
+		}
+	}
+}
+
+func TestRISResetsBufferCursorAndStyle(t *testing.T) {
+	vt := NewVirtualTerminal(5, 2, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenSGR, Parameters: []string{"1", "31", "44"}},
+		{Type: types.TokenText, Value: "ab"},
+		{Type: types.TokenC0, C0Code: 0x0A},
+		{Type: types.TokenText, Value: "cd"},
+		{Type: types.TokenEscape, Raw: "\x1bc"},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	for y := range vt.buffer {
+		for x, cell := range vt.buffer[y] {
+			if cell.Char != 0x0 {
+				t.Fatalf("expected blank cell at (%d,%d), got %q", x, y, cell.Char)
+			}
+		}
+	}
+
+	if vt.cursorX != 0 || vt.cursorY != 0 {
+		t.Fatalf("expected cursor at (0,0), got (%d,%d)", vt.cursorX, vt.cursorY)
+	}
+
+	if !vt.currentSGR.Equals(types.NewSGR()) {
+		t.Fatalf("expected default style, got %s", vt.currentSGR.String())
+	}
+
+	if err := vt.ApplyTokens([]types.Token{{Type: types.TokenText, Value: "x"}}); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+	if !vt.buffer[0][0].SGR.Equals(types.NewSGR()) {
+		t.Fatalf("expected text after RIS in default style, got %s", vt.buffer[0][0].SGR.String())
+	}
+}
+
+func TestDECSCAndDECRCRestoreCursorAndStyle(t *testing.T) {
+	vt := NewVirtualTerminal(10, 2, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenSGR, Parameters: []string{"1", "32"}},
+		{Type: types.TokenText, Value: "ab"},
+		{Type: types.TokenEscape, Raw: "\x1b7"},
+		{Type: types.TokenSGR, Parameters: []string{"0", "34"}},
+		{Type: types.TokenText, Value: "cd"},
+		{Type: types.TokenEscape, Raw: "\x1b8"},
+		{Type: types.TokenText, Value: "X"},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	if got := vt.ExportPlainText(); !strings.HasPrefix(got, "abXd") {
+		t.Fatalf("expected X written at the saved position, got %q", got)
+	}
+
+	expected := types.NewSGR()
+	expected.Bold = true
+	expected.FgColor = types.ColorValue{Type: types.ColorStandard, Index: 2}
+	if !vt.buffer[0][2].SGR.Equals(expected) {
+		t.Fatalf("expected restored style %s, got %s", expected.String(), vt.buffer[0][2].SGR.String())
+	}
+}
+
+func TestCursorPositionOmittedParams(t *testing.T) {
+	tests := []struct {
+		name      string
+		raw       string
+		params    []string
+		expectedX int
+		expectedY int
+	}{
+		{"column only", "\x1b[;12H", []string{"", "12"}, 11, 0},
+		{"row only with separator", "\x1b[6;H", []string{"6", ""}, 0, 5},
+		{"row only", "\x1b[6H", []string{"6"}, 0, 5},
+		{"home", "\x1b[H", []string{}, 0, 0},
+		{"zero is one", "\x1b[0;0H", []string{"0", "0"}, 0, 0},
+		{"column past the right margin", "\x1b[2;99H", []string{"2", "99"}, 19, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vt := NewVirtualTerminal(20, 10, "utf8", false)
+
+			tokens := []types.Token{
+				{Type: types.TokenText, Value: "abc"},
+				{Type: types.TokenCSI, Raw: tt.raw, Parameters: tt.params},
+			}
+
+			if err := vt.ApplyTokens(tokens); err != nil {
+				t.Fatalf("unexpected apply error: %v", err)
+			}
+
+			if vt.cursorX != tt.expectedX || vt.cursorY != tt.expectedY {
+				t.Fatalf("expected cursor at (%d,%d), got (%d,%d)", tt.expectedX, tt.expectedY, vt.cursorX, vt.cursorY)
+			}
+		})
+	}
+}
+
+func TestTabStopsSetAndCleared(t *testing.T) {
+	vt := NewVirtualTerminal(20, 2, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenCSI, Raw: "\x1b[3g", Parameters: []string{"3"}},
+		{Type: types.TokenText, Value: "abcd"},
+		{Type: types.TokenC1, Raw: "\x1bH", C1Code: "HTS"},
+		{Type: types.TokenC0, C0Code: 0x0D},
+		{Type: types.TokenC0, C0Code: 0x09},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	if vt.cursorX != 4 {
+		t.Fatalf("expected TAB to land on the custom stop at column 4, got %d", vt.cursorX)
+	}
+
+	// Clearing the stop at the cursor leaves no stop on the line
+	tokens = []types.Token{
+		{Type: types.TokenCSI, Raw: "\x1b[g"},
+		{Type: types.TokenC0, C0Code: 0x0D},
+		{Type: types.TokenC0, C0Code: 0x09},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	if vt.cursorX != 0 || vt.cursorY != 1 {
+		t.Fatalf("expected TAB without stop to wrap to (0,1), got (%d,%d)", vt.cursorX, vt.cursorY)
+	}
+}
+
+func TestTabDefaultsToEveryEightColumns(t *testing.T) {
+	vt := NewVirtualTerminal(20, 2, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenText, Value: "ab"},
+		{Type: types.TokenC0, C0Code: 0x09},
+		{Type: types.TokenText, Value: "c"},
+		{Type: types.TokenC0, C0Code: 0x09},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	if vt.buffer[0][8].Char != 'c' || vt.cursorX != 16 {
+		t.Fatalf("expected stops at 8 and 16, got c at %q and cursor %d", vt.buffer[0][8].Char, vt.cursorX)
+	}
+}
+
+func TestREPRepeatsLastPrintedChar(t *testing.T) {
+	tests := []struct {
+		name     string
+		tokens   []types.Token
+		expected string
+	}{
+		{
+			name: "repeat",
+			tokens: []types.Token{
+				{Type: types.TokenText, Value: "X"},
+				{Type: types.TokenCSI, Raw: "\x1b[5b", Parameters: []string{"5"}},
+			},
+			expected: "XXXXXX    ",
+		},
+		{
+			name: "default count is one",
+			tokens: []types.Token{
+				{Type: types.TokenText, Value: "ab"},
+				{Type: types.TokenCSI, Raw: "\x1b[b"},
+			},
+			expected: "abb       ",
+		},
+		{
+			name: "combining marks are repeated",
+			tokens: []types.Token{
+				{Type: types.TokenText, Value: "e\u0301"},
+				{Type: types.TokenCSI, Raw: "\x1b[2b", Parameters: []string{"2"}},
+			},
+			expected: "e\u0301e\u0301e\u0301       ",
+		},
+		{
+			name: "cursor move forgets the char",
+			tokens: []types.Token{
+				{Type: types.TokenText, Value: "X"},
+				{Type: types.TokenCSI, Raw: "\x1b[2C", Parameters: []string{"2"}},
+				{Type: types.TokenCSI, Raw: "\x1b[3b", Parameters: []string{"3"}},
+			},
+			expected: "X         ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vt := NewVirtualTerminal(10, 1, "utf8", false)
+
+			if err := vt.ApplyTokens(tt.tokens); err != nil {
+				t.Fatalf("unexpected apply error: %v", err)
+			}
+
+			if got := strings.TrimSuffix(vt.ExportPlainText(), "\n"); got != tt.expected {
+				t.Fatalf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestREPAtLastColumnWrapsLikeText(t *testing.T) {
+	rep := NewVirtualTerminal(4, 2, "utf8", false)
+	err := rep.ApplyTokens([]types.Token{
+		{Type: types.TokenText, Value: "abcd"},
+		{Type: types.TokenCSI, Raw: "\x1b[2b", Parameters: []string{"2"}},
+		{Type: types.TokenText, Value: "X"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	text := NewVirtualTerminal(4, 2, "utf8", false)
+	if err := text.ApplyTokens([]types.Token{{Type: types.TokenText, Value: "abcdddX"}}); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	if got, want := rep.ExportPlainText(), text.ExportPlainText(); got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+	if !rep.softWrapped[0] {
+		t.Fatalf("expected the first row to be soft wrapped")
+	}
+}
+
+func TestCloneIsIndependent(t *testing.T) {
+	vt := NewVirtualTerminal(10, 2, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenSGR, Parameters: []string{"31"}},
+		{Type: types.TokenText, Value: "abc"},
+	}
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	clone := vt.Clone()
+	before := clone.ExportFlattenedANSI()
+
+	tokens = []types.Token{
+		{Type: types.TokenCSI, Raw: "\x1b[H"},
+		{Type: types.TokenSGR, Parameters: []string{"1", "34"}},
+		{Type: types.TokenText, Value: "XY"},
+		{Type: types.TokenCSI, Raw: "\x1b[3g", Parameters: []string{"3"}},
+	}
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+	vt.buffer[0][2].SGR.Underline = true
+
+	if got := clone.ExportFlattenedANSI(); got != before {
+		t.Fatalf("clone changed with the original: %q, want %q", got, before)
+	}
+
+	if clone.cursorX != 3 || clone.cursorY != 0 {
+		t.Fatalf("expected clone cursor at (3,0), got (%d,%d)", clone.cursorX, clone.cursorY)
+	}
+
+	// The clone keeps its own style and tab stops
+	if err := clone.ApplyTokens([]types.Token{
+		{Type: types.TokenC0, C0Code: 0x09},
+		{Type: types.TokenText, Value: "d"},
+	}); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	expected := types.NewSGR()
+	expected.FgColor = types.ColorValue{Type: types.ColorStandard, Index: 1}
+	if clone.buffer[0][8].Char != 'd' || !clone.buffer[0][8].SGR.Equals(expected) {
+		t.Fatalf("expected red d at the default tab stop, got %q %s", clone.buffer[0][8].Char, clone.buffer[0][8].SGR.String())
+	}
+}
+
+func TestCellsReturnsUsedArea(t *testing.T) {
+	vt := NewVirtualTerminal(10, 5, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenText, Value: "ab"},
+		{Type: types.TokenC0, C0Code: 0x0D},
+		{Type: types.TokenC0, C0Code: 0x0A},
+		{Type: types.TokenSGR, Parameters: []string{"1", "32"}},
+		{Type: types.TokenText, Value: "cd"},
+	}
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	cells := vt.Cells()
+	if len(cells) != 2 || len(cells[0]) != 2 || len(cells[1]) != 2 {
+		t.Fatalf("expected 2x2 cells, got %dx%d", len(cells), len(cells[0]))
+	}
+
+	cell := cells[1][1]
+	expected := types.NewSGR()
+	expected.Bold = true
+	expected.FgColor = types.ColorValue{Type: types.ColorStandard, Index: 2}
+	if cell.Char != 'd' || !cell.SGR.Equals(expected) {
+		t.Fatalf("expected bold green d, got %q %s", cell.Char, cell.SGR.String())
+	}
+
+	// Cells is a copy
+	cells[1][1].SGR.Italic = true
+	if vt.buffer[1][1].SGR.Italic {
+		t.Fatalf("changing a returned cell should not change the buffer")
+	}
+
+	// A row written up to the last column is fully used
+	full := NewVirtualTerminal(4, 1, "utf8", false)
+	if err := full.ApplyTokens([]types.Token{{Type: types.TokenText, Value: "abcd"}}); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+	if row := full.Cells()[0]; len(row) != 4 {
+		t.Fatalf("expected 4 cells, got %d", len(row))
+	}
+}
+
+func TestSetTabWidth(t *testing.T) {
+	tokens := []types.Token{
+		{Type: types.TokenText, Value: "a"},
+		{Type: types.TokenC0, C0Code: 0x09},
+		{Type: types.TokenText, Value: "b"},
+		{Type: types.TokenC0, C0Code: 0x09},
+	}
+
+	tests := []struct {
+		tabWidth  int
+		expectedX int
+	}{
+		{8, 16},
+		{4, 8},
+	}
+
+	for _, tt := range tests {
+		vt := NewVirtualTerminal(20, 1, "utf8", false)
+		vt.SetTabWidth(tt.tabWidth)
+
+		if err := vt.ApplyTokens(tokens); err != nil {
+			t.Fatalf("unexpected apply error: %v", err)
+		}
+
+		if vt.cursorX != tt.expectedX {
+			t.Errorf("tab width %d: expected cursor at %d, got %d", tt.tabWidth, tt.expectedX, vt.cursorX)
+		}
+	}
+}
+
+func TestSplitExportKeepsTrailingStyledLine(t *testing.T) {
+	vt := NewVirtualTerminal(4, 5, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenText, Value: "AB"},
+		{Type: types.TokenC0, C0Code: 0x0D},
+		{Type: types.TokenC0, C0Code: 0x0A},
+		{Type: types.TokenSGR, Parameters: []string{"44"}},
+		{Type: types.TokenText, Value: "    "},
+		{Type: types.TokenSGR, Parameters: []string{"0"}},
+		{Type: types.TokenC0, C0Code: 0x0D},
+		{Type: types.TokenC0, C0Code: 0x0A},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	// The final CRLF only moves the cursor, it adds no line
+	lines := vt.ExportSplitTextAndSequences()
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+
+	footer := lines[1]
+	if footer.Text != "    " || len(footer.Sequences) != 1 || footer.Sequences[0].SGR.BgColor.Index != 4 {
+		t.Fatalf("expected a blue footer of spaces, got %q %v", footer.Text, footer.Sequences)
+	}
+
+	vt.eraseDisplay(2)
+	if lines := vt.ExportSplitTextAndSequences(); len(lines) != 1 {
+		t.Fatalf("expected 1 line after clearing the screen, got %d", len(lines))
+	}
+}
+
+func TestOverstrike(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      []string
+		char      rune
+		bold      bool
+		underline bool
+	}{
+		{"same char is bold", []string{"a", "a"}, 'a', true, false},
+		{"underscore first underlines", []string{"_", "x"}, 'x', false, true},
+		{"underscore last underlines", []string{"x", "_"}, 'x', false, true},
+		{"bold and underline", []string{"_", "a", "a"}, 'a', true, true},
+		{"other char overwrites", []string{"a", "b"}, 'b', false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vt := NewVirtualTerminal(10, 1, "utf8", false)
+			vt.SetOverstrike(true)
+
+			var tokens []types.Token
+			for i, text := range tt.text {
+				if i > 0 {
+					tokens = append(tokens, types.Token{Type: types.TokenC0, C0Code: 0x08})
+				}
+				tokens = append(tokens, types.Token{Type: types.TokenText, Value: text})
+			}
+
+			if err := vt.ApplyTokens(tokens); err != nil {
+				t.Fatalf("unexpected apply error: %v", err)
+			}
+
+			cell := vt.buffer[0][0]
+			if cell.Char != tt.char || cell.SGR.Bold != tt.bold || cell.SGR.Underline != tt.underline {
+				t.Fatalf("expected %q bold=%v underline=%v, got %q bold=%v underline=%v",
+					tt.char, tt.bold, tt.underline, cell.Char, cell.SGR.Bold, cell.SGR.Underline)
+			}
+			if vt.cursorX != 1 {
+				t.Fatalf("expected cursor at column 1, got %d", vt.cursorX)
+			}
+		})
+	}
+}
+
+func TestOverstrikeDisabledOverwrites(t *testing.T) {
+	vt := NewVirtualTerminal(10, 1, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenText, Value: "a"},
+		{Type: types.TokenC0, C0Code: 0x08},
+		{Type: types.TokenText, Value: "a"},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	if vt.buffer[0][0].SGR.Bold {
+		t.Fatalf("expected a plain overwrite without overstrike")
+	}
+}
+
+func TestDECLineDrawingCharset(t *testing.T) {
+	vt := NewVirtualTerminal(10, 1, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenEscape, Raw: "\x1b(0"},
+		{Type: types.TokenText, Value: "lqkx"},
+		{Type: types.TokenEscape, Raw: "\x1b(B"},
+		{Type: types.TokenText, Value: "q"},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	if got := strings.TrimRight(vt.ExportPlainTextInline(), "\x00 "); got != "┌─┐│q" {
+		t.Fatalf("expected %q, got %q", "┌─┐│q", got)
+	}
+}
+
+func TestCursorDownClampsToLastRow(t *testing.T) {
+	vt := NewVirtualTerminal(10, 3, "utf8", false)
+
+	var overflowX, overflowY int
+	overflows := 0
+	vt.SetOnOverflow(func(x, y int) {
+		overflows++
+		overflowX, overflowY = x, y
+	})
+
+	tokens := []types.Token{
+		{Type: types.TokenText, Value: "ab"},
+		{Type: types.TokenCSI, Raw: "\x1b[9999B", Parameters: []string{"9999"}},
+		{Type: types.TokenText, Value: "X"},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	if vt.buffer[2][2].Char != 'X' {
+		t.Fatalf("expected X on the last row at column 2, got %q", vt.buffer[2][2].Char)
+	}
+	if overflows != 1 || overflowX != 2 || overflowY != 9999 {
+		t.Fatalf("expected one overflow at (2, 9999), got %d at (%d, %d)", overflows, overflowX, overflowY)
+	}
+}
+
+func TestCursorNextPreviousLine(t *testing.T) {
+	vt := NewVirtualTerminal(10, 5, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenText, Value: "abc"},
+		{Type: types.TokenCSI, Raw: "\x1b[2E", Parameters: []string{"2"}},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+	if vt.cursorX != 0 || vt.cursorY != 2 {
+		t.Fatalf("expected cursor at (0, 2) after CNL, got (%d, %d)", vt.cursorX, vt.cursorY)
+	}
+
+	tokens = []types.Token{
+		{Type: types.TokenText, Value: "de"},
+		{Type: types.TokenCSI, Raw: "\x1b[F"},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+	if vt.cursorX != 0 || vt.cursorY != 1 {
+		t.Fatalf("expected cursor at (0, 1) after CPL, got (%d, %d)", vt.cursorX, vt.cursorY)
+	}
+}
+
+func TestVerticalTabAndFormFeed(t *testing.T) {
+	tokens := []types.Token{
+		{Type: types.TokenText, Value: "A"},
+		{Type: types.TokenC0, C0Code: 0x0B},
+		{Type: types.TokenText, Value: "B"},
+		{Type: types.TokenC0, C0Code: 0x0C},
+		{Type: types.TokenText, Value: "C"},
+	}
+
+	vt := NewVirtualTerminal(10, 5, "utf8", false)
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	// VT and FF move down without returning to column 0
+	for _, want := range []struct {
+		x, y int
+		char rune
+	}{{0, 0, 'A'}, {1, 1, 'B'}, {2, 2, 'C'}} {
+		if got := vt.buffer[want.y][want.x].Char; got != want.char {
+			t.Fatalf("expected %q at (%d, %d), got %q", want.char, want.x, want.y, got)
+		}
+	}
+
+	vt = NewVirtualTerminal(10, 5, "utf8", false)
+	vt.SetFormFeedClears(true)
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	if vt.buffer[1][1].Char != 0x0 || vt.buffer[0][0].Char != 'C' {
+		t.Fatalf("expected FF to clear the screen and home the cursor, got %q", vt.ExportPlainText())
+	}
+}
+
+func TestStyleAtAndRowStyles(t *testing.T) {
+	vt := NewVirtualTerminal(10, 3, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenText, Value: "ab"},
+		{Type: types.TokenSGR, Parameters: []string{"31"}},
+		{Type: types.TokenText, Value: "cd"},
+		{Type: types.TokenSGR, Parameters: []string{"0"}},
+		{Type: types.TokenText, Value: "e"},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	sgr, char := vt.StyleAt(2, 0)
+	if char != 'c' || sgr.FgColor != (types.ColorValue{Type: types.ColorStandard, Index: 1}) {
+		t.Fatalf("expected a red 'c', got %q with %v", char, sgr)
+	}
+
+	sgr, char = vt.StyleAt(6, 1)
+	if char != 0x0 || !sgr.Equals(types.NewSGR()) {
+		t.Fatalf("expected a blank default cell, got %q with %v", char, sgr)
+	}
+
+	if sgr, _ := vt.StyleAt(10, 0); sgr != nil {
+		t.Fatalf("expected nil outside the buffer, got %v", sgr)
+	}
+
+	styles := vt.RowStyles(0)
+	if len(styles) != 3 || styles[0].Position != 0 || styles[1].Position != 2 || styles[2].Position != 4 {
+		t.Fatalf("expected style changes at 0, 2 and 4, got %v", styles)
+	}
+	if !styles[2].SGR.Equals(types.NewSGR()) {
+		t.Fatalf("expected the default style from column 4, got %v", styles[2].SGR)
+	}
+}
+
+func TestCursorVisibility(t *testing.T) {
+	vt := NewVirtualTerminal(10, 2, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenCSI, Raw: "\x1b[?25l", Prefix: "?", Parameters: []string{"25"}},
+		{Type: types.TokenText, Value: "ab"},
+		{Type: types.TokenCSI, Raw: "\x1b[?25l", Prefix: "?", Parameters: []string{"25"}},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+	if vt.CursorVisible() || vt.CursorVisibilityChanges() != 1 {
+		t.Fatalf("expected a hidden cursor after one change, got visible=%t changes=%d", vt.CursorVisible(), vt.CursorVisibilityChanges())
+	}
+
+	tokens = []types.Token{
+		{Type: types.TokenCSI, Raw: "\x1b[?25h", Prefix: "?", Parameters: []string{"25"}},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+	if !vt.CursorVisible() || vt.CursorVisibilityChanges() != 2 {
+		t.Fatalf("expected a visible cursor after two changes, got visible=%t changes=%d", vt.CursorVisible(), vt.CursorVisibilityChanges())
+	}
+	if got := strings.TrimRight(vt.ExportPlainTextInline(), " "); got != "ab" {
+		t.Fatalf("expected cursor visibility not to render, got %q", got)
+	}
+}
+
+func TestShiftOutG1LineDrawing(t *testing.T) {
+	vt := NewVirtualTerminal(10, 1, "utf8", false)
+
+	tokens := []types.Token{
+		{Type: types.TokenEscape, Raw: "\x1b)0"},
+		{Type: types.TokenText, Value: "q"},
+		{Type: types.TokenC0, C0Code: 0x0E},
+		{Type: types.TokenText, Value: "lqk"},
+		{Type: types.TokenC0, C0Code: 0x0F},
+		{Type: types.TokenText, Value: "q"},
+		{Type: types.TokenC0, C0Code: 0x0E},
+		{Type: types.TokenEscape, Raw: "\x1b)B"},
+		{Type: types.TokenText, Value: "q"},
+	}
+
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+
+	if got := strings.TrimRight(vt.ExportPlainTextInline(), "\x00 "); got != "q┌─┐qq" {
+		t.Fatalf("expected %q, got %q", "q┌─┐qq", got)
+	}
+}
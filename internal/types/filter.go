@@ -0,0 +1,92 @@
+package types
+
+import "strings"
+
+/////////////////////////////////////////////////////////////////////////////
+// TOKEN FILTER
+/////////////////////////////////////////////////////////////////////////////
+
+// FilterTokens returns the tokens for which keep returns true, in order.
+// Combined with ExportPassthroughANSI, FilterTokens(tokens, KeepVisible) gives
+// a de-animated stream without cursor moves.
+func FilterTokens(tokens []Token, keep func(Token) bool) []Token {
+	filtered := make([]Token, 0, len(tokens))
+	for _, token := range tokens {
+		if keep(token) {
+			filtered = append(filtered, token)
+		}
+	}
+
+	return filtered
+}
+
+// KeepVisible keeps text, SGR and the CR/LF line breaks
+func KeepVisible(token Token) bool {
+	switch token.Type {
+	case TokenText, TokenSGR:
+		return true
+	case TokenC0:
+		return token.C0Code == 0x0A || token.C0Code == 0x0D
+	default:
+		return false
+	}
+}
+
+// DropControl drops cursor and terminal control sequences (CSI, C1, DCS,
+// APC, escapes, unknown or interrupted sequences) and keeps everything else
+func DropControl(token Token) bool {
+	switch token.Type {
+	case TokenCSI, TokenCSIInterupted, TokenC1, TokenDCS, TokenAPC, TokenEscape, TokenUnknown:
+		return false
+	default:
+		return true
+	}
+}
+
+// DropCursorVisibility drops the cursor show/hide sequences (CSI ? 25 h and
+// CSI ? 25 l), which only matter for playback
+func DropCursorVisibility(token Token) bool {
+	if token.Type != TokenCSI || token.Prefix != "?" || len(token.Parameters) == 0 {
+		return true
+	}
+	if !strings.HasSuffix(token.Raw, "h") && !strings.HasSuffix(token.Raw, "l") {
+		return true
+	}
+
+	for _, p := range token.Parameters {
+		if p != "25" {
+			return true
+		}
+	}
+	return false
+}
+
+// DedupSGR returns tokens without the SGR tokens that leave the running
+// style unchanged, e.g. the second reset of ESC[0mESC[0m or a repeated
+// ESC[31m. The style saved by DECSC (ESC 7) is put back by DECRC (ESC 8).
+// The first SGR token is always kept since the style in effect before the
+// stream is unknown, and so is the first one after a full reset (ESC c) or
+// after a DECRC with nothing saved.
+func DedupSGR(tokens []Token) []Token {
+	deduped := make([]Token, 0, len(tokens))
+	var current, saved *SGR
+	for _, token := range tokens {
+		switch {
+		case token.Type == TokenSGR:
+			next := token.ToSGR(current)
+			if current != nil && next.Equals(current) {
+				continue
+			}
+			current = next
+		case token.Type == TokenEscape && token.Raw == "\x1bc":
+			current, saved = nil, nil
+		case token.Type == TokenEscape && token.Raw == "\x1b7":
+			saved = current
+		case token.Type == TokenEscape && token.Raw == "\x1b8":
+			current = saved
+		}
+		deduped = append(deduped, token)
+	}
+
+	return deduped
+}
@@ -0,0 +1,120 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterTokens(t *testing.T) {
+	tokens := []Token{
+		{Type: TokenCSI, Raw: "\x1b[2J"},
+		{Type: TokenSGR, Parameters: []string{"31"}},
+		{Type: TokenText, Value: "Hello"},
+		{Type: TokenCSI, Raw: "\x1b[5C"},
+		{Type: TokenC0, C0Code: 0x07},
+		{Type: TokenC0, C0Code: 0x0D},
+		{Type: TokenC0, C0Code: 0x0A},
+		{Type: TokenEscape, Raw: "\x1b7"},
+		{Type: TokenOSC, Raw: "\x1b]2;title\x07"},
+		{Type: TokenText, Value: "World"},
+	}
+
+	tests := []struct {
+		name     string
+		keep     func(Token) bool
+		expected []TokenType
+	}{
+		{
+			name:     "sgr and text",
+			keep:     func(t Token) bool { return t.Type == TokenSGR || t.Type == TokenText },
+			expected: []TokenType{TokenSGR, TokenText, TokenText},
+		},
+		{
+			name:     "visible",
+			keep:     KeepVisible,
+			expected: []TokenType{TokenSGR, TokenText, TokenC0, TokenC0, TokenText},
+		},
+		{
+			name:     "drop control",
+			keep:     DropControl,
+			expected: []TokenType{TokenSGR, TokenText, TokenC0, TokenC0, TokenC0, TokenOSC, TokenText},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FilterTokens(tokens, tt.keep)
+			if len(got) != len(tt.expected) {
+				t.Fatalf("expected %d tokens, got %d: %v", len(tt.expected), len(got), got)
+			}
+			for i, token := range got {
+				if token.Type != tt.expected[i] {
+					t.Errorf("token %d: expected %s, got %s", i, tt.expected[i], token.Type)
+				}
+			}
+		})
+	}
+}
+
+func TestDropCursorVisibility(t *testing.T) {
+	tokens := []Token{
+		{Type: TokenCSI, Raw: "\x1b[?25l", Prefix: "?", Parameters: []string{"25"}},
+		{Type: TokenText, Value: "Hi"},
+		{Type: TokenCSI, Raw: "\x1b[?7h", Prefix: "?", Parameters: []string{"7"}},
+		{Type: TokenCSI, Raw: "\x1b[25C", Parameters: []string{"25"}},
+		{Type: TokenCSI, Raw: "\x1b[?25h", Prefix: "?", Parameters: []string{"25"}},
+	}
+
+	got := FilterTokens(tokens, DropCursorVisibility)
+	if len(got) != 3 || got[0].Value != "Hi" || got[1].Raw != "\x1b[?7h" || got[2].Raw != "\x1b[25C" {
+		t.Fatalf("expected only the cursor visibility sequences dropped, got %v", got)
+	}
+}
+
+func TestDedupSGR(t *testing.T) {
+	tokens := []Token{
+		{Type: TokenSGR, Parameters: []string{"0"}},
+		{Type: TokenSGR, Parameters: []string{"0"}},
+		{Type: TokenText, Value: "a"},
+		{Type: TokenSGR, Parameters: []string{"31"}},
+		{Type: TokenSGR, Parameters: []string{"1"}},
+		{Type: TokenText, Value: "b"},
+		{Type: TokenSGR, Parameters: []string{"1", "31"}},
+		{Type: TokenText, Value: "c"},
+		{Type: TokenSGR, Parameters: nil},
+		{Type: TokenEscape, Raw: "\x1bc"},
+		{Type: TokenSGR, Parameters: []string{"0"}},
+	}
+
+	got := DedupSGR(tokens)
+
+	want := []int{0, 2, 3, 4, 5, 7, 8, 9, 10}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d tokens, got %d: %v", len(want), len(got), got)
+	}
+	for i, index := range want {
+		if !reflect.DeepEqual(got[i], tokens[index]) {
+			t.Errorf("Token %d: expected %v, got %v", i, tokens[index], got[i])
+		}
+	}
+}
+
+func TestDedupSGRRestoresSavedStyle(t *testing.T) {
+	tokens := []Token{
+		{Type: TokenSGR, Parameters: []string{"31"}},
+		{Type: TokenEscape, Raw: "\x1b7"},
+		{Type: TokenSGR, Parameters: []string{"32"}},
+		{Type: TokenText, Value: "g"},
+		{Type: TokenEscape, Raw: "\x1b8"},
+		// DECRC brought red back, green must be set again
+		{Type: TokenSGR, Parameters: []string{"32"}},
+		{Type: TokenText, Value: "x"},
+		{Type: TokenSGR, Parameters: []string{"32"}},
+	}
+
+	got := DedupSGR(tokens)
+
+	if !reflect.DeepEqual(got, tokens[:7]) {
+		t.Fatalf("Expected %v, got %v", tokens[:7], got)
+	}
+}
@@ -2,6 +2,7 @@ package types
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -15,13 +16,17 @@ const (
 	ColorDefault  ColorType = iota
 	ColorStandard           // 0-15 (codes 30-37, 90-97, etc.)
 	ColorIndexed            // 0-255 (ESC[38;5;n)
-	ColorRGB                // RGB (ESC[38;2;r;g;b)
+	ColorRGB                // RGB (ESC[38;2;r;g;b) or RGBA (ESC[38;6;r;g;b;a)
 )
 
 type ColorValue struct {
 	Type    ColorType
 	R, G, B uint8
-	Index   uint8
+	// Alpha of an RGB color, 255 (opaque) when absent. HasAlpha tells an
+	// RGBA color (ESC[38;6;r;g;b;a) apart, to encode it back the same way.
+	A        uint8
+	HasAlpha bool
+	Index    uint8
 }
 
 func (c ColorValue) IsDefault() bool {
@@ -37,11 +42,85 @@ func (c ColorValue) String() string {
 	case ColorIndexed:
 		return fmt.Sprintf("idx:%d", c.Index)
 	case ColorRGB:
+		if c.HasAlpha {
+			return fmt.Sprintf("rgba(%d,%d,%d,%d)", c.R, c.G, c.B, c.A)
+		}
 		return fmt.Sprintf("rgb(%d,%d,%d)", c.R, c.G, c.B)
 	}
 	return "unknown"
 }
 
+// ParseColorValue parses the ColorValue.String form of a color
+// ("default", "std:1", "idx:123", "rgb(255,0,128)" or "rgba(255,0,128,64)")
+func ParseColorValue(s string) (ColorValue, error) {
+	if s == "default" {
+		return ColorValue{Type: ColorDefault}, nil
+	}
+
+	if kind, value, ok := strings.Cut(s, ":"); ok {
+		index, err := strconv.ParseUint(value, 10, 8)
+		switch {
+		case err != nil:
+		case kind == "std" && index < 16:
+			return ColorValue{Type: ColorStandard, Index: uint8(index)}, nil
+		case kind == "idx":
+			return ColorValue{Type: ColorIndexed, Index: uint8(index)}, nil
+		}
+		return ColorValue{}, fmt.Errorf("invalid color %q", s)
+	}
+
+	kind, rest, ok := strings.Cut(s, "(")
+	values, ok2 := strings.CutSuffix(rest, ")")
+	if !ok || !ok2 || (kind != "rgb" && kind != "rgba") {
+		return ColorValue{}, fmt.Errorf("invalid color %q", s)
+	}
+
+	channelCount := 3
+	if kind == "rgba" {
+		channelCount = 4
+	}
+	fields := strings.Split(values, ",")
+	if len(fields) != channelCount {
+		return ColorValue{}, fmt.Errorf("invalid color %q", s)
+	}
+	channels := [4]uint8{3: 255}
+	for i, field := range fields {
+		channel, err := strconv.ParseUint(field, 10, 8)
+		if err != nil {
+			return ColorValue{}, fmt.Errorf("invalid color %q", s)
+		}
+		channels[i] = uint8(channel)
+	}
+
+	return ColorValue{
+		Type:     ColorRGB,
+		R:        channels[0],
+		G:        channels[1],
+		B:        channels[2],
+		A:        channels[3],
+		HasAlpha: kind == "rgba",
+	}, nil
+}
+
+// rgbCodes returns the extended color codes of an RGB color for selector
+// (38, 48 or 58): 6;r;g;b;a with an alpha, 2;r;g;b otherwise.
+func (c ColorValue) rgbCodes(selector int) []int {
+	if c.HasAlpha {
+		return []int{selector, 6, int(c.R), int(c.G), int(c.B), int(c.A)}
+	}
+	return []int{selector, 2, int(c.R), int(c.G), int(c.B)}
+}
+
+// rgbParam is rgbCodes as a single SGR parameter string
+func (c ColorValue) rgbParam(selector int) string {
+	codes := c.rgbCodes(selector)
+	parts := make([]string, len(codes))
+	for i, code := range codes {
+		parts[i] = fmt.Sprintf("%d", code)
+	}
+	return strings.Join(parts, ";")
+}
+
 // VGA Palette with exact VGA hardware color values
 var VGAPalette = [16][3]uint8{
 	{0x00, 0x00, 0x00}, // 0: Black
@@ -62,37 +141,136 @@ var VGAPalette = [16][3]uint8{
 	{0xFF, 0xFF, 0xFF}, // 15: Bright White
 }
 
+// ModernPalette contains the xterm default 16 colors
+var ModernPalette = [16][3]uint8{
+	{0x00, 0x00, 0x00}, // 0: Black
+	{0xCD, 0x00, 0x00}, // 1: Red
+	{0x00, 0xCD, 0x00}, // 2: Green
+	{0xCD, 0xCD, 0x00}, // 3: Yellow
+	{0x00, 0x00, 0xEE}, // 4: Blue
+	{0xCD, 0x00, 0xCD}, // 5: Magenta
+	{0x00, 0xCD, 0xCD}, // 6: Cyan
+	{0xE5, 0xE5, 0xE5}, // 7: White/Light Gray
+	{0x7F, 0x7F, 0x7F}, // 8: Bright Black (Dark Gray)
+	{0xFF, 0x00, 0x00}, // 9: Bright Red
+	{0x00, 0xFF, 0x00}, // 10: Bright Green
+	{0xFF, 0xFF, 0x00}, // 11: Bright Yellow
+	{0x5C, 0x5C, 0xFF}, // 12: Bright Blue
+	{0xFF, 0x00, 0xFF}, // 13: Bright Magenta
+	{0x00, 0xFF, 0xFF}, // 14: Bright Cyan
+	{0xFF, 0xFF, 0xFF}, // 15: Bright White
+}
+
+// Palette256 contains the xterm 256 colors: ModernPalette, then the
+// 6x6x6 color cube (16-231) and the grayscale ramp (232-255)
+var Palette256 = newPalette256()
+
+// newPalette256 computes Palette256 with the xterm formula
+func newPalette256() [256][3]uint8 {
+	var palette [256][3]uint8
+	copy(palette[:16], ModernPalette[:])
+
+	levels := [6]uint8{0x00, 0x5F, 0x87, 0xAF, 0xD7, 0xFF}
+	for i := range 216 {
+		palette[16+i] = [3]uint8{levels[i/36], levels[(i/6)%6], levels[i%6]}
+	}
+
+	for i := range 24 {
+		gray := uint8(8 + i*10)
+		palette[232+i] = [3]uint8{gray, gray, gray}
+	}
+
+	return palette
+}
+
+// ToRGB resolves c to a concrete RGB color. Standard colors and indexed
+// colors 0-15 use VGAPalette when useVGA is set, ModernPalette otherwise,
+// other indexed colors use Palette256. The default color has no value of
+// its own and resolves to black.
+func (c ColorValue) ToRGB(useVGA bool) (r, g, b uint8) {
+	palette := ModernPalette
+	if useVGA {
+		palette = VGAPalette
+	}
+
+	switch c.Type {
+	case ColorStandard:
+		rgb := palette[c.Index&0x0F]
+		return rgb[0], rgb[1], rgb[2]
+	case ColorIndexed:
+		rgb := Palette256[c.Index]
+		if c.Index < 16 {
+			rgb = palette[c.Index]
+		}
+		return rgb[0], rgb[1], rgb[2]
+	case ColorRGB:
+		return c.R, c.G, c.B
+	}
+	return 0, 0, 0
+}
+
+// VGAColorNames names the VGAPalette entries
+var VGAColorNames = [16]string{
+	"Black", "Red", "Green", "Brown", "Blue", "Magenta", "Cyan", "Light Gray",
+	"Dark Gray", "Bright Red", "Bright Green", "Yellow", "Bright Blue", "Bright Magenta", "Bright Cyan", "White",
+}
+
 /////////////////////////////////////////////////////////////////////////////
 // SGR (Select Graphic Rendition)
 /////////////////////////////////////////////////////////////////////////////
 
 type SGR struct {
-	FgColor       ColorValue
-	BgColor       ColorValue
-	Bold          bool
-	Dim           bool
-	Italic        bool
-	Underline     bool
-	Blink         bool
-	Reverse       bool
-	Hidden        bool
-	Strikethrough bool
+	FgColor         ColorValue
+	BgColor         ColorValue
+	UnderlineColor  ColorValue
+	Bold            bool
+	Dim             bool
+	Italic          bool
+	Underline       bool
+	DoubleUnderline bool
+	Blink           bool
+	Reverse         bool
+	Hidden          bool
+	Strikethrough   bool
+	Overline        bool
+	Superscript     bool // SGR 73, exclusive with Subscript
+	Subscript       bool // SGR 74
+}
+
+// Colors of NewSGR and of a reset (SGR 0), see SetDefaultColors
+var (
+	defaultFgColor = ColorValue{Type: ColorStandard, Index: 7} // Light gray for better visibility
+	defaultBgColor = ColorValue{Type: ColorStandard, Index: 0} // Black
+)
+
+// SetDefaultColors changes the colors of NewSGR and of a reset (SGR 0),
+// light gray on black by default. A diff back to these colors is emitted as
+// a reset, so they should match the theme of the target terminal.
+func SetDefaultColors(fg, bg ColorValue) {
+	defaultFgColor, defaultBgColor = fg, bg
+}
+
+// DefaultColors returns the colors set by SetDefaultColors
+func DefaultColors() (fg, bg ColorValue) {
+	return defaultFgColor, defaultBgColor
 }
 
 func NewSGR() *SGR {
+	return NewSGRWithDefaults(defaultFgColor, defaultBgColor)
+}
+
+// NewSGRWithDefaults returns an SGR with no attributes and the fg and bg
+// colors, regardless of SetDefaultColors
+func NewSGRWithDefaults(fg, bg ColorValue) *SGR {
 	return &SGR{
-		// FgColor: ColorValue{Type: ColorDefault},
-		// BgColor: ColorValue{Type: ColorDefault},
-		FgColor: ColorValue{Type: ColorStandard, Index: 7}, // Default to light gray for better visibility
-		BgColor: ColorValue{Type: ColorStandard, Index: 0}, // Default to black
+		FgColor: fg,
+		BgColor: bg,
 	}
 }
 
 func (s *SGR) Reset() {
-	// s.FgColor = ColorValue{Type: ColorDefault}
-	// s.BgColor = ColorValue{Type: ColorDefault}
-	s.FgColor = ColorValue{Type: ColorStandard, Index: 7} // Default to light gray
-	s.BgColor = ColorValue{Type: ColorStandard, Index: 0} // Default to black
+	s.FgColor = defaultFgColor
+	s.BgColor = defaultBgColor
 	s.Bold = false
 	s.Dim = false
 	s.Italic = false
@@ -101,6 +279,11 @@ func (s *SGR) Reset() {
 	s.Reverse = false
 	s.Hidden = false
 	s.Strikethrough = false
+	s.DoubleUnderline = false
+	s.Overline = false
+	s.Superscript = false
+	s.Subscript = false
+	s.UnderlineColor = ColorValue{Type: ColorDefault}
 }
 
 func (s *SGR) ApplyParams(params []int) {
@@ -113,8 +296,37 @@ func (s *SGR) ApplyParams(params []int) {
 
 		case 1:
 			s.Bold = true
-		case 21, 22:
+		case 21:
+			s.DoubleUnderline = true
+		case 22: // Normal intensity: neither bold nor dim
 			s.Bold = false
+			s.Dim = false
+		case 23:
+			s.Italic = false
+		case 24: // Underline off (single and double)
+			s.Underline = false
+			s.DoubleUnderline = false
+		case 25:
+			s.Blink = false
+		case 27:
+			s.Reverse = false
+		case 28:
+			s.Hidden = false
+		case 29:
+			s.Strikethrough = false
+		case 53:
+			s.Overline = true
+		case 55:
+			s.Overline = false
+		case 73:
+			s.Superscript = true
+			s.Subscript = false
+		case 74:
+			s.Superscript = false
+			s.Subscript = true
+		case 75: // Neither superscript nor subscript
+			s.Superscript = false
+			s.Subscript = false
 
 		case 2:
 			s.Dim = true
@@ -154,6 +366,12 @@ func (s *SGR) ApplyParams(params []int) {
 
 		case 100, 101, 102, 103, 104, 105, 106, 107:
 			s.BgColor = ColorValue{Type: ColorStandard, Index: uint8(code - 100 + 8)}
+
+		case 58: // Underline color extended
+			i += s.applyExtendedColor(&s.UnderlineColor, params, i+1)
+
+		case 59:
+			s.UnderlineColor = ColorValue{Type: ColorDefault}
 		}
 	}
 }
@@ -176,14 +394,20 @@ func (s *SGR) applyExtendedColor(color *ColorValue, params []int, start int) int
 			return 2
 		}
 
-	case 2: // RGB color
-		// ESC[38;2;r;g;b
+	case 2, 6: // RGB color, RGBA color
+		// ESC[38;2;r;g;b or ESC[38;6;r;g;b;a
 		if start+3 < len(params) {
 			*color = ColorValue{
 				Type: ColorRGB,
 				R:    uint8(params[start+1]),
 				G:    uint8(params[start+2]),
 				B:    uint8(params[start+3]),
+				A:    255,
+			}
+			if colorType == 6 && start+4 < len(params) {
+				color.A = uint8(params[start+4])
+				color.HasAlpha = true
+				return 5
 			}
 			return 4
 		}
@@ -232,7 +456,7 @@ func (s *SGR) ToANSI(useVGAColors bool, legacyMode bool) string {
 		case ColorIndexed:
 			codes = append(codes, fmt.Sprintf("38;5;%d", s.FgColor.Index))
 		case ColorRGB:
-			codes = append(codes, fmt.Sprintf("38;2;%d;%d;%d", s.FgColor.R, s.FgColor.G, s.FgColor.B))
+			codes = append(codes, s.FgColor.rgbParam(38))
 		}
 	}
 
@@ -259,28 +483,31 @@ func (s *SGR) ToANSI(useVGAColors bool, legacyMode bool) string {
 					codes = append(codes, fmt.Sprintf("%d", 40+s.BgColor.Index))
 				} else {
 					// Couleur background bright (8-15)
-					if legacyMode {
-						// Mode legacy : 101 → 0;41;1 (reset + color + bold)
-						// codes = append(codes, "0")
-						codes = append(codes, fmt.Sprintf("%d", 40+(s.BgColor.Index-8)))
-						codes = append(codes, "1")
-					} else {
-						// Mode moderne : utilise 100-107
-						codes = append(codes, fmt.Sprintf("%d", 92+s.BgColor.Index))
-					}
+					// SGR 1 only brightens the foreground, so even in legacy
+					// mode bright backgrounds use 100-107
+					codes = append(codes, fmt.Sprintf("%d", 92+s.BgColor.Index))
 				}
 			}
 		case ColorIndexed:
 			codes = append(codes, fmt.Sprintf("48;5;%d", s.BgColor.Index))
 		case ColorRGB:
-			codes = append(codes, fmt.Sprintf("48;2;%d;%d;%d", s.BgColor.R, s.BgColor.G, s.BgColor.B))
+			codes = append(codes, s.BgColor.rgbParam(48))
+		}
+	}
+
+	// Underline color (there are no short codes, standard colors use the 256 palette)
+	if !s.UnderlineColor.IsDefault() {
+		switch s.UnderlineColor.Type {
+		case ColorStandard, ColorIndexed:
+			codes = append(codes, fmt.Sprintf("58;5;%d", s.UnderlineColor.Index))
+		case ColorRGB:
+			codes = append(codes, s.UnderlineColor.rgbParam(58))
 		}
 	}
 
 	// Attributes
 	// Bold - ne pas ajouter si déjà ajouté par legacyMode pour bright colors
-	alreadyBold := legacyMode && ((!s.FgColor.IsDefault() && s.FgColor.Type == ColorStandard && s.FgColor.Index >= 8) ||
-		(!s.BgColor.IsDefault() && s.BgColor.Type == ColorStandard && s.BgColor.Index >= 8))
+	alreadyBold := legacyMode && !s.FgColor.IsDefault() && s.FgColor.Type == ColorStandard && s.FgColor.Index >= 8
 
 	if s.Bold && !alreadyBold {
 		codes = append(codes, "1")
@@ -306,6 +533,18 @@ func (s *SGR) ToANSI(useVGAColors bool, legacyMode bool) string {
 	if s.Strikethrough {
 		codes = append(codes, "9")
 	}
+	if s.DoubleUnderline {
+		codes = append(codes, "21")
+	}
+	if s.Overline {
+		codes = append(codes, "53")
+	}
+	if s.Superscript {
+		codes = append(codes, "73")
+	}
+	if s.Subscript {
+		codes = append(codes, "74")
+	}
 
 	if len(codes) == 0 {
 		return "\x1b[0m"
@@ -319,6 +558,7 @@ func (s *SGR) String() string {
 
 	parts = append(parts, fmt.Sprintf("fg:%s", s.FgColor.String()))
 	parts = append(parts, fmt.Sprintf("bg:%s", s.BgColor.String()))
+	parts = append(parts, fmt.Sprintf("ul:%s", s.UnderlineColor.String()))
 
 	parts = append(parts, fmt.Sprintf("default:%t", s.FgColor.IsDefault()))
 	parts = append(parts, fmt.Sprintf("bold:%t", s.Bold))
@@ -329,10 +569,84 @@ func (s *SGR) String() string {
 	parts = append(parts, fmt.Sprintf("reverse:%t", s.Reverse))
 	parts = append(parts, fmt.Sprintf("hidden:%t", s.Hidden))
 	parts = append(parts, fmt.Sprintf("strikethrough:%t", s.Strikethrough))
+	parts = append(parts, fmt.Sprintf("doubleunderline:%t", s.DoubleUnderline))
+	parts = append(parts, fmt.Sprintf("overline:%t", s.Overline))
+	parts = append(parts, fmt.Sprintf("superscript:%t", s.Superscript))
+	parts = append(parts, fmt.Sprintf("subscript:%t", s.Subscript))
 
 	return strings.Join(parts, ", ")
 }
 
+// DisplayedColors returns the foreground and background colors as shown on
+// a VGA screen, with bold brightening and reverse video applied
+func (s *SGR) DisplayedColors() (fg, bg ColorValue) {
+	fg, bg = s.FgColor, s.BgColor
+	if s.Bold && fg.Type == ColorStandard && fg.Index < 8 {
+		fg.Index += 8
+	}
+	if s.Reverse {
+		fg, bg = bg, fg
+	}
+	return fg, bg
+}
+
+// SameDisplay reports whether s and other look the same on a VGA screen.
+// Colors are compared as displayed, with the default colors, bold
+// brightening, reverse video and hidden text applied. Bold only counts on
+// its own when it does not just brighten a standard foreground color.
+func (s *SGR) SameDisplay(other *SGR) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+
+	fg, bg := s.displayedRGB()
+	otherFg, otherBg := other.displayedRGB()
+	underlined := s.Underline || s.DoubleUnderline
+
+	return fg == otherFg &&
+		bg == otherBg &&
+		s.visibleBold() == other.visibleBold() &&
+		s.Dim == other.Dim &&
+		s.Italic == other.Italic &&
+		s.Underline == other.Underline &&
+		s.DoubleUnderline == other.DoubleUnderline &&
+		(!underlined || s.UnderlineColor == other.UnderlineColor) &&
+		s.Blink == other.Blink &&
+		s.Strikethrough == other.Strikethrough &&
+		s.Overline == other.Overline &&
+		s.Superscript == other.Superscript &&
+		s.Subscript == other.Subscript
+}
+
+// displayedRGB returns the VGA foreground and background colors shown for s
+func (s *SGR) displayedRGB() (fg, bg [3]uint8) {
+	sgr := *s
+	if sgr.FgColor.IsDefault() {
+		sgr.FgColor = defaultFgColor
+	}
+	if sgr.BgColor.IsDefault() {
+		sgr.BgColor = defaultBgColor
+	}
+
+	fgColor, bgColor := sgr.DisplayedColors()
+	if sgr.Hidden {
+		fgColor = bgColor
+	}
+
+	fg[0], fg[1], fg[2] = fgColor.ToRGB(true)
+	bg[0], bg[1], bg[2] = bgColor.ToRGB(true)
+	return fg, bg
+}
+
+// visibleBold reports whether bold shows as more than a bright standard color
+func (s *SGR) visibleBold() bool {
+	fgColor := s.FgColor
+	if fgColor.IsDefault() {
+		fgColor = defaultFgColor
+	}
+	return s.Bold && fgColor.Type != ColorStandard
+}
+
 func (s *SGR) Equals(other *SGR) bool {
 	if s == nil || other == nil {
 		return s == other
@@ -340,6 +654,7 @@ func (s *SGR) Equals(other *SGR) bool {
 
 	return s.FgColor == other.FgColor &&
 		s.BgColor == other.BgColor &&
+		s.UnderlineColor == other.UnderlineColor &&
 		s.Bold == other.Bold &&
 		s.Dim == other.Dim &&
 		s.Italic == other.Italic &&
@@ -347,21 +662,30 @@ func (s *SGR) Equals(other *SGR) bool {
 		s.Blink == other.Blink &&
 		s.Reverse == other.Reverse &&
 		s.Hidden == other.Hidden &&
-		s.Strikethrough == other.Strikethrough
+		s.Strikethrough == other.Strikethrough &&
+		s.DoubleUnderline == other.DoubleUnderline &&
+		s.Overline == other.Overline &&
+		s.Superscript == other.Superscript &&
+		s.Subscript == other.Subscript
 }
 
 func (s *SGR) Copy() *SGR {
 	return &SGR{
-		FgColor:       s.FgColor,
-		BgColor:       s.BgColor,
-		Bold:          s.Bold,
-		Dim:           s.Dim,
-		Italic:        s.Italic,
-		Underline:     s.Underline,
-		Blink:         s.Blink,
-		Reverse:       s.Reverse,
-		Hidden:        s.Hidden,
-		Strikethrough: s.Strikethrough,
+		FgColor:         s.FgColor,
+		BgColor:         s.BgColor,
+		UnderlineColor:  s.UnderlineColor,
+		Bold:            s.Bold,
+		Dim:             s.Dim,
+		Italic:          s.Italic,
+		Underline:       s.Underline,
+		DoubleUnderline: s.DoubleUnderline,
+		Blink:           s.Blink,
+		Reverse:         s.Reverse,
+		Hidden:          s.Hidden,
+		Strikethrough:   s.Strikethrough,
+		Overline:        s.Overline,
+		Superscript:     s.Superscript,
+		Subscript:       s.Subscript,
 	}
 }
 
@@ -396,12 +720,24 @@ func (s *SGR) countActiveAttributes() int {
 	if s.Strikethrough {
 		count++
 	}
+	if s.DoubleUnderline {
+		count++
+	}
+	if s.Overline {
+		count++
+	}
+	if s.Superscript || s.Subscript {
+		count++
+	}
 	if !s.FgColor.IsDefault() {
 		count++
 	}
 	if !s.BgColor.IsDefault() {
 		count++
 	}
+	if !s.UnderlineColor.IsDefault() {
+		count++
+	}
 	return count
 }
 
@@ -433,6 +769,15 @@ func (s *SGR) hasAttributeTurnedOff(previous *SGR) bool {
 	if previous.Strikethrough && !s.Strikethrough {
 		return true
 	}
+	if previous.DoubleUnderline && !s.DoubleUnderline {
+		return true
+	}
+	if previous.Overline && !s.Overline {
+		return true
+	}
+	if (previous.Superscript && !s.Superscript) || (previous.Subscript && !s.Subscript) {
+		return true
+	}
 	// FG color changed to default
 	if !previous.FgColor.IsDefault() && s.FgColor.IsDefault() {
 		return true
@@ -441,6 +786,10 @@ func (s *SGR) hasAttributeTurnedOff(previous *SGR) bool {
 	if !previous.BgColor.IsDefault() && s.BgColor.IsDefault() {
 		return true
 	}
+	// Underline color changed to default
+	if !previous.UnderlineColor.IsDefault() && s.UnderlineColor.IsDefault() {
+		return true
+	}
 	// FG bright color (8-15) changed to normal color (0-7)
 	// In legacy mode, bright colors use bold implicitly, so this is like turning off bold
 	if previous.FgColor.Type == ColorStandard && s.FgColor.Type == ColorStandard {
@@ -489,14 +838,15 @@ func (s *SGR) fgColorCodesLegacy(legacyMode bool) []int {
 	case ColorIndexed:
 		return []int{38, 5, int(s.FgColor.Index)}
 	case ColorRGB:
-		return []int{38, 2, int(s.FgColor.R), int(s.FgColor.G), int(s.FgColor.B)}
+		return s.FgColor.rgbCodes(38)
 	}
 	return nil
 }
 
 // bgColorCodesLegacy returns SGR codes for background color in legacy mode
-// In legacy mode, bright colors (8-15) use bold (1) + base color (40-47)
-func (s *SGR) bgColorCodesLegacy(legacyMode bool) []int {
+// SGR 1 only brightens the foreground, so bright colors (8-15) use 100-107,
+// unless blinkBrightBg selects the DOS/BBS convention blink (5) + base color (40-47)
+func (s *SGR) bgColorCodesLegacy(legacyMode bool, blinkBrightBg bool) []int {
 	if s.BgColor.IsDefault() {
 		return []int{49}
 	}
@@ -507,15 +857,29 @@ func (s *SGR) bgColorCodesLegacy(legacyMode bool) []int {
 			return []int{40 + int(s.BgColor.Index)}
 		}
 		// Bright background colors (8-15)
-		if legacyMode {
-			// Legacy mode: use bold + base color (e.g., bright red bg = 1;41)
-			return []int{1, 40 + int(s.BgColor.Index) - 8}
+		if legacyMode && blinkBrightBg {
+			// DOS mode: use blink + base color (e.g., bright red bg = 5;41)
+			return []int{5, 40 + int(s.BgColor.Index) - 8}
 		}
 		return []int{100 + int(s.BgColor.Index) - 8}
 	case ColorIndexed:
 		return []int{48, 5, int(s.BgColor.Index)}
 	case ColorRGB:
-		return []int{48, 2, int(s.BgColor.R), int(s.BgColor.G), int(s.BgColor.B)}
+		return s.BgColor.rgbCodes(48)
+	}
+	return nil
+}
+
+// underlineColorCodes returns SGR codes for underline color
+// Standard colors have no dedicated codes and are emitted as palette indexes
+func (s *SGR) underlineColorCodes() []int {
+	switch s.UnderlineColor.Type {
+	case ColorDefault:
+		return []int{59}
+	case ColorStandard, ColorIndexed:
+		return []int{58, 5, int(s.UnderlineColor.Index)}
+	case ColorRGB:
+		return s.UnderlineColor.rgbCodes(58)
 	}
 	return nil
 }
@@ -527,12 +891,18 @@ func (s *SGR) fgColorCodes() []int {
 
 // bgColorCodes returns SGR codes for background color (modern mode)
 func (s *SGR) bgColorCodes() []int {
-	return s.bgColorCodesLegacy(false)
+	return s.bgColorCodesLegacy(false, false)
+}
+
+// hasBlinkBrightBg reports whether the background is encoded with the blink
+// code (DOS convention), in which case Blink must not be emitted twice
+func (s *SGR) hasBlinkBrightBg(legacyMode bool, blinkBrightBg bool) bool {
+	return legacyMode && blinkBrightBg && s.BgColor.Type == ColorStandard && s.BgColor.Index >= 8
 }
 
 // toFullCodesLegacy returns all active attribute codes (without reset prefix)
 // In legacy mode, bright colors use bold + base color
-func (s *SGR) toFullCodesLegacy(legacyMode bool) []int {
+func (s *SGR) toFullCodesLegacy(legacyMode bool, blinkBrightBg bool) []int {
 	var codes []int
 
 	// In legacy mode, don't add bold separately if we have bright FG color
@@ -554,7 +924,7 @@ func (s *SGR) toFullCodesLegacy(legacyMode bool) []int {
 	if s.Underline {
 		codes = append(codes, 4)
 	}
-	if s.Blink {
+	if s.Blink && !s.hasBlinkBrightBg(legacyMode, blinkBrightBg) {
 		codes = append(codes, 5)
 	}
 	if s.Reverse {
@@ -566,12 +936,27 @@ func (s *SGR) toFullCodesLegacy(legacyMode bool) []int {
 	if s.Strikethrough {
 		codes = append(codes, 9)
 	}
+	if s.DoubleUnderline {
+		codes = append(codes, 21)
+	}
+	if s.Overline {
+		codes = append(codes, 53)
+	}
+	if s.Superscript {
+		codes = append(codes, 73)
+	}
+	if s.Subscript {
+		codes = append(codes, 74)
+	}
 
 	if !s.FgColor.IsDefault() {
 		codes = append(codes, s.fgColorCodesLegacy(legacyMode)...)
 	}
 	if !s.BgColor.IsDefault() {
-		codes = append(codes, s.bgColorCodesLegacy(legacyMode)...)
+		codes = append(codes, s.bgColorCodesLegacy(legacyMode, blinkBrightBg)...)
+	}
+	if !s.UnderlineColor.IsDefault() {
+		codes = append(codes, s.underlineColorCodes()...)
 	}
 
 	return codes
@@ -579,7 +964,7 @@ func (s *SGR) toFullCodesLegacy(legacyMode bool) []int {
 
 // toFullCodes returns all active attribute codes (modern mode)
 func (s *SGR) toFullCodes() []int {
-	return s.toFullCodesLegacy(false)
+	return s.toFullCodesLegacy(false, false)
 }
 
 // Diff returns the minimal set of SGR codes to transition from previous to current state.
@@ -587,9 +972,19 @@ func (s *SGR) toFullCodes() []int {
 // If legacyMode is true, uses [0m + full state when any attribute needs to be turned OFF.
 // If legacyMode is false, uses individual OFF codes (22, 23, 24, etc.).
 func (s *SGR) Diff(previous *SGR, legacyMode bool) []int {
+	return s.diff(previous, legacyMode, false)
+}
+
+// DiffBlinkBrightBg is like Diff, but in legacy mode encodes bright backgrounds
+// with the DOS/BBS blink convention (5;40-47) instead of 100-107.
+func (s *SGR) DiffBlinkBrightBg(previous *SGR, legacyMode bool) []int {
+	return s.diff(previous, legacyMode, true)
+}
+
+func (s *SGR) diff(previous *SGR, legacyMode bool, blinkBrightBg bool) []int {
 	// Handle nil previous - return full state
 	if previous == nil {
-		return s.toFullCodesLegacy(legacyMode)
+		return s.toFullCodesLegacy(legacyMode, blinkBrightBg)
 	}
 
 	// If equal, no changes needed
@@ -605,7 +1000,7 @@ func (s *SGR) Diff(previous *SGR, legacyMode bool) []int {
 	// In legacy mode, if any attribute needs to be turned OFF, use reset + full state
 	if legacyMode && s.hasAttributeTurnedOff(previous) {
 		codes := []int{0}
-		codes = append(codes, s.toFullCodesLegacy(legacyMode)...)
+		codes = append(codes, s.toFullCodesLegacy(legacyMode, blinkBrightBg)...)
 		return codes
 	}
 
@@ -619,19 +1014,22 @@ func (s *SGR) Diff(previous *SGR, legacyMode bool) []int {
 		addBold = false // Bold will be added by fgColorCodesLegacy
 	}
 
-	if addBold {
+	// Bold and Dim share a single OFF code (22, normal intensity), so when
+	// either is turned off the one that remains active must be re-enabled.
+	if (previous.Bold && !s.Bold) || (previous.Dim && !s.Dim) {
+		codes = append(codes, 22)
 		if s.Bold {
 			codes = append(codes, 1)
-		} else {
-			codes = append(codes, 22) // Bold off
 		}
-	}
-
-	if s.Dim != previous.Dim {
 		if s.Dim {
 			codes = append(codes, 2)
-		} else {
-			codes = append(codes, 22) // Dim off (same as bold off)
+		}
+	} else {
+		if addBold && s.Bold {
+			codes = append(codes, 1)
+		}
+		if s.Dim && !previous.Dim {
+			codes = append(codes, 2)
 		}
 	}
 
@@ -643,15 +1041,27 @@ func (s *SGR) Diff(previous *SGR, legacyMode bool) []int {
 		}
 	}
 
-	if s.Underline != previous.Underline {
+	// Underline and DoubleUnderline share a single OFF code (24)
+	if (previous.Underline && !s.Underline) || (previous.DoubleUnderline && !s.DoubleUnderline) {
+		codes = append(codes, 24)
 		if s.Underline {
 			codes = append(codes, 4)
-		} else {
-			codes = append(codes, 24)
+		}
+		if s.DoubleUnderline {
+			codes = append(codes, 21)
+		}
+	} else {
+		if s.Underline && !previous.Underline {
+			codes = append(codes, 4)
+		}
+		if s.DoubleUnderline && !previous.DoubleUnderline {
+			codes = append(codes, 21)
 		}
 	}
 
-	if s.Blink != previous.Blink {
+	// Blink is already carried by the background codes in DOS mode
+	bgCarriesBlink := s.BgColor != previous.BgColor && s.hasBlinkBrightBg(legacyMode, blinkBrightBg)
+	if s.Blink != previous.Blink && !(s.Blink && bgCarriesBlink) {
 		if s.Blink {
 			codes = append(codes, 5)
 		} else {
@@ -683,6 +1093,26 @@ func (s *SGR) Diff(previous *SGR, legacyMode bool) []int {
 		}
 	}
 
+	if s.Overline != previous.Overline {
+		if s.Overline {
+			codes = append(codes, 53)
+		} else {
+			codes = append(codes, 55)
+		}
+	}
+
+	// Superscript and subscript share a single OFF code (75)
+	if s.Superscript != previous.Superscript || s.Subscript != previous.Subscript {
+		switch {
+		case s.Superscript:
+			codes = append(codes, 73)
+		case s.Subscript:
+			codes = append(codes, 74)
+		default:
+			codes = append(codes, 75)
+		}
+	}
+
 	// Foreground color
 	if s.FgColor != previous.FgColor {
 		codes = append(codes, s.fgColorCodesLegacy(legacyMode)...)
@@ -690,7 +1120,12 @@ func (s *SGR) Diff(previous *SGR, legacyMode bool) []int {
 
 	// Background color
 	if s.BgColor != previous.BgColor {
-		codes = append(codes, s.bgColorCodesLegacy(legacyMode)...)
+		codes = append(codes, s.bgColorCodesLegacy(legacyMode, blinkBrightBg)...)
+	}
+
+	// Underline color
+	if s.UnderlineColor != previous.UnderlineColor {
+		codes = append(codes, s.underlineColorCodes()...)
 	}
 
 	return codes
@@ -700,7 +1135,17 @@ func (s *SGR) Diff(previous *SGR, legacyMode bool) []int {
 // If legacyMode is true, uses [0m + full state when attributes need to be turned OFF (ANSI 1990 compatible).
 // If legacyMode is false, uses individual OFF codes (modern terminals).
 func (s *SGR) DiffToANSI(previous *SGR, useVGAColors bool, legacyMode bool) string {
-	codes := s.Diff(previous, legacyMode)
+	return s.diffToANSI(previous, useVGAColors, legacyMode, false)
+}
+
+// DiffToANSIBlinkBrightBg is like DiffToANSI, but in legacy mode encodes bright
+// backgrounds with the DOS/BBS blink convention (5;40-47) instead of 100-107.
+func (s *SGR) DiffToANSIBlinkBrightBg(previous *SGR, useVGAColors bool, legacyMode bool) string {
+	return s.diffToANSI(previous, useVGAColors, legacyMode, true)
+}
+
+func (s *SGR) diffToANSI(previous *SGR, useVGAColors bool, legacyMode bool, blinkBrightBg bool) string {
+	codes := s.diff(previous, legacyMode, blinkBrightBg)
 
 	if len(codes) == 0 {
 		return "" // No change needed
@@ -754,6 +1199,18 @@ func (s *SGR) diffToVGAColors(previous *SGR, legacyMode bool) string {
 		if s.Strikethrough {
 			codes = append(codes, "9")
 		}
+		if s.DoubleUnderline {
+			codes = append(codes, "21")
+		}
+		if s.Overline {
+			codes = append(codes, "53")
+		}
+		if s.Superscript {
+			codes = append(codes, "73")
+		}
+		if s.Subscript {
+			codes = append(codes, "74")
+		}
 
 		// FG color with VGA palette
 		if !s.FgColor.IsDefault() && s.FgColor.Type == ColorStandard {
@@ -780,6 +1237,13 @@ func (s *SGR) diffToVGAColors(previous *SGR, legacyMode bool) string {
 			}
 		}
 
+		// Underline color
+		if !s.UnderlineColor.IsDefault() {
+			for _, c := range s.underlineColorCodes() {
+				codes = append(codes, fmt.Sprintf("%d", c))
+			}
+		}
+
 		if len(codes) == 0 {
 			return ""
 		}
@@ -790,18 +1254,22 @@ func (s *SGR) diffToVGAColors(previous *SGR, legacyMode bool) string {
 	var codes []string
 
 	// Attributes
-	if previous == nil || s.Bold != previous.Bold {
+	// Bold and Dim share the 22 OFF code, so re-enable whichever remains active
+	intensityOff := previous != nil && ((previous.Bold && !s.Bold) || (previous.Dim && !s.Dim))
+	if intensityOff && !legacyMode {
+		codes = append(codes, "22")
 		if s.Bold {
 			codes = append(codes, "1")
-		} else if !legacyMode {
-			codes = append(codes, "22")
 		}
-	}
-	if previous == nil || s.Dim != previous.Dim {
 		if s.Dim {
 			codes = append(codes, "2")
-		} else if !legacyMode {
-			codes = append(codes, "22")
+		}
+	} else {
+		if s.Bold && (previous == nil || !previous.Bold) {
+			codes = append(codes, "1")
+		}
+		if s.Dim && (previous == nil || !previous.Dim) {
+			codes = append(codes, "2")
 		}
 	}
 	if previous == nil || s.Italic != previous.Italic {
@@ -811,11 +1279,21 @@ func (s *SGR) diffToVGAColors(previous *SGR, legacyMode bool) string {
 			codes = append(codes, "23")
 		}
 	}
-	if previous == nil || s.Underline != previous.Underline {
+	underlineOff := previous != nil && ((previous.Underline && !s.Underline) || (previous.DoubleUnderline && !s.DoubleUnderline))
+	if underlineOff && !legacyMode {
+		codes = append(codes, "24")
 		if s.Underline {
 			codes = append(codes, "4")
-		} else if !legacyMode {
-			codes = append(codes, "24")
+		}
+		if s.DoubleUnderline {
+			codes = append(codes, "21")
+		}
+	} else {
+		if s.Underline && (previous == nil || !previous.Underline) {
+			codes = append(codes, "4")
+		}
+		if s.DoubleUnderline && (previous == nil || !previous.DoubleUnderline) {
+			codes = append(codes, "21")
 		}
 	}
 	if previous == nil || s.Blink != previous.Blink {
@@ -846,6 +1324,23 @@ func (s *SGR) diffToVGAColors(previous *SGR, legacyMode bool) string {
 			codes = append(codes, "29")
 		}
 	}
+	if previous == nil || s.Overline != previous.Overline {
+		if s.Overline {
+			codes = append(codes, "53")
+		} else if !legacyMode {
+			codes = append(codes, "55")
+		}
+	}
+	if previous == nil || s.Superscript != previous.Superscript || s.Subscript != previous.Subscript {
+		switch {
+		case s.Superscript:
+			codes = append(codes, "73")
+		case s.Subscript:
+			codes = append(codes, "74")
+		case previous != nil && !legacyMode:
+			codes = append(codes, "75")
+		}
+	}
 
 	// FG color - also recalculate when Bold changes for standard colors (VGA: bold affects brightness)
 	fgChanged := previous == nil || s.FgColor != previous.FgColor
@@ -882,6 +1377,12 @@ func (s *SGR) diffToVGAColors(previous *SGR, legacyMode bool) string {
 			}
 		}
 	}
+	// Underline color
+	if (previous == nil && !s.UnderlineColor.IsDefault()) || (previous != nil && s.UnderlineColor != previous.UnderlineColor) {
+		for _, c := range s.underlineColorCodes() {
+			codes = append(codes, fmt.Sprintf("%d", c))
+		}
+	}
 
 	if len(codes) == 0 {
 		return ""
@@ -899,8 +1400,23 @@ type SGRSequence struct {
 	SGR      *SGR // The SGR sequence to apply from this position
 }
 
+// LinkSequence represents a hyperlink (OSC 8) change at a specific position
+type LinkSequence struct {
+	Position int    // Position of the character in the line (0-indexed)
+	Params   string // OSC 8 parameters (e.g. "id=xyz")
+	URI      string // Link target, empty when the link ends at this position
+}
+
+// RawSequence is an unsupported sequence kept verbatim at a specific position
+type RawSequence struct {
+	Position int    // Position of the character in the line (0-indexed)
+	Raw      string // Sequence bytes, written before the character
+}
+
 // LineWithSequences contains a line of text and all SGR changes within that line
 type LineWithSequences struct {
 	Text      string
 	Sequences []SGRSequence
+	Links     []LinkSequence // Hyperlink changes within the line
+	Raws      []RawSequence  // Unsupported sequences, see SetPreserveUnknown
 }
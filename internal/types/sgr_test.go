@@ -0,0 +1,393 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyParamsNormalIntensityClearsBoldAndDim(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []int
+	}{
+		{"Dim then normal intensity", []int{2, 22}},
+		{"Bold then normal intensity", []int{1, 22}},
+		{"Bold and dim then normal intensity", []int{1, 2, 22}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sgr := NewSGR()
+			sgr.ApplyParams(tt.params)
+
+			if sgr.Bold {
+				t.Errorf("Expected Bold to be off after %v", tt.params)
+			}
+			if sgr.Dim {
+				t.Errorf("Expected Dim to be off after %v", tt.params)
+			}
+		})
+	}
+}
+
+func TestDiffNormalIntensityKeepsRemainingAttribute(t *testing.T) {
+	previous := NewSGR()
+	previous.ApplyParams([]int{1, 2})
+
+	current := previous.Copy()
+	current.ApplyParams([]int{22, 1})
+
+	codes := current.Diff(previous, false)
+	if !reflect.DeepEqual(codes, []int{22, 1}) {
+		t.Fatalf("Expected codes [22 1], got %v", codes)
+	}
+
+	replayed := previous.Copy()
+	replayed.ApplyParams(codes)
+	if !replayed.Equals(current) {
+		t.Fatalf("Expected replayed state %v, got %v", current, replayed)
+	}
+}
+
+func TestApplyParamsDoubleUnderlineAndOverline(t *testing.T) {
+	sgr := NewSGR()
+	sgr.ApplyParams([]int{21, 53})
+
+	if !sgr.DoubleUnderline {
+		t.Errorf("Expected DoubleUnderline to be on after 21")
+	}
+	if !sgr.Overline {
+		t.Errorf("Expected Overline to be on after 53")
+	}
+
+	sgr.ApplyParams([]int{24, 55})
+	if sgr.DoubleUnderline {
+		t.Errorf("Expected DoubleUnderline to be off after 24")
+	}
+	if sgr.Overline {
+		t.Errorf("Expected Overline to be off after 55")
+	}
+}
+
+func TestDiffUnderlineOffKeepsDoubleUnderline(t *testing.T) {
+	previous := NewSGR()
+	previous.ApplyParams([]int{4, 21})
+
+	current := previous.Copy()
+	current.Underline = false
+
+	codes := current.Diff(previous, false)
+	if !reflect.DeepEqual(codes, []int{24, 21}) {
+		t.Fatalf("Expected codes [24 21], got %v", codes)
+	}
+}
+
+func TestUnderlineColorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   []int
+		expected ColorValue
+		ansi     string
+	}{
+		{
+			name:     "Indexed",
+			params:   []int{4, 58, 5, 123},
+			expected: ColorValue{Type: ColorIndexed, Index: 123},
+			ansi:     "\x1b[37;40;58;5;123;4m",
+		},
+		{
+			name:     "RGB",
+			params:   []int{4, 58, 2, 255, 100, 50},
+			expected: ColorValue{Type: ColorRGB, R: 255, G: 100, B: 50, A: 255},
+			ansi:     "\x1b[37;40;58;2;255;100;50;4m",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sgr := NewSGR()
+			sgr.ApplyParams(tt.params)
+
+			if sgr.UnderlineColor != tt.expected {
+				t.Fatalf("Expected underline color %v, got %v", tt.expected, sgr.UnderlineColor)
+			}
+
+			if got := sgr.ToANSI(false, false); got != tt.ansi {
+				t.Errorf("Expected %q, got %q", tt.ansi, got)
+			}
+
+			replayed := NewSGR()
+			replayed.ApplyParams(sgr.Diff(NewSGR(), false))
+			if !replayed.Equals(sgr) {
+				t.Errorf("Expected replayed state %v, got %v", sgr, replayed)
+			}
+
+			sgr.ApplyParams([]int{59})
+			if !sgr.UnderlineColor.IsDefault() {
+				t.Errorf("Expected default underline color after 59, got %v", sgr.UnderlineColor)
+			}
+		})
+	}
+}
+
+func TestDiffBrightBackgroundEncoding(t *testing.T) {
+	sgr := NewSGR()
+	sgr.BgColor = ColorValue{Type: ColorStandard, Index: 9}
+
+	tests := []struct {
+		name     string
+		got      string
+		expected string
+	}{
+		{"Modern", sgr.DiffToANSI(NewSGR(), false, false), "\x1b[101m"},
+		{"Legacy", sgr.DiffToANSI(NewSGR(), false, true), "\x1b[101m"},
+		{"Legacy blink", sgr.DiffToANSIBlinkBrightBg(NewSGR(), false, true), "\x1b[5;41m"},
+		{"Modern ignores blink", sgr.DiffToANSIBlinkBrightBg(NewSGR(), false, false), "\x1b[101m"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, tt.got)
+			}
+		})
+	}
+}
+
+func TestDiffBlinkBrightBgDoesNotRepeatBlink(t *testing.T) {
+	sgr := NewSGR()
+	sgr.Blink = true
+	sgr.BgColor = ColorValue{Type: ColorStandard, Index: 12}
+
+	codes := sgr.DiffBlinkBrightBg(nil, true)
+	expected := []int{37, 5, 44}
+	if !reflect.DeepEqual(codes, expected) {
+		t.Fatalf("Expected codes %v, got %v", expected, codes)
+	}
+}
+
+func TestRGBAColorRoundTrip(t *testing.T) {
+	token := Token{Type: TokenSGR, Parameters: []string{"38", "6", "255", "0", "128", "128"}}
+	sgr := token.ToSGR(nil)
+
+	expected := ColorValue{Type: ColorRGB, R: 255, G: 0, B: 128, A: 128, HasAlpha: true}
+	if sgr.FgColor != expected {
+		t.Fatalf("Expected fg %v, got %v", expected, sgr.FgColor)
+	}
+
+	if got := sgr.ToANSI(false, false); got != "\x1b[38;6;255;0;128;128;40m" {
+		t.Errorf("Unexpected ToANSI %q", got)
+	}
+
+	replayed := NewSGR()
+	replayed.ApplyParams(sgr.Diff(NewSGR(), false))
+	if !replayed.Equals(sgr) {
+		t.Errorf("Expected replayed state %v, got %v", sgr, replayed)
+	}
+
+	opaque := sgr.Copy()
+	opaque.FgColor.A, opaque.FgColor.HasAlpha = 0, false
+	if opaque.Equals(sgr) {
+		t.Errorf("Expected alpha to be part of the color")
+	}
+	if got := opaque.DiffToANSI(sgr, false, false); got != "\x1b[38;2;255;0;128m" {
+		t.Errorf("Unexpected diff to the opaque color %q", got)
+	}
+
+	// RGB without alpha is opaque, as is a literal ColorValue
+	rgb := Token{Type: TokenSGR, Parameters: []string{"48", "2", "1", "2", "3"}}.ToSGR(nil)
+	if rgb.BgColor.HasAlpha {
+		t.Errorf("Expected an opaque RGB color, got %v", rgb.BgColor)
+	}
+	literal := NewSGR()
+	literal.FgColor = ColorValue{Type: ColorRGB, R: 1, G: 2, B: 3}
+	if got := literal.ToANSI(false, false); got != "\x1b[38;2;1;2;3;40m" {
+		t.Errorf("Unexpected ToANSI for a literal RGB color %q", got)
+	}
+}
+
+func TestCustomDefaultColors(t *testing.T) {
+	fg, bg := DefaultColors()
+	defer SetDefaultColors(fg, bg)
+
+	black := ColorValue{Type: ColorStandard, Index: 0}
+	white := ColorValue{Type: ColorStandard, Index: 15}
+	SetDefaultColors(black, white)
+
+	if got := NewSGR(); !got.Equals(NewSGRWithDefaults(black, white)) {
+		t.Fatalf("Expected black on white default, got %v", got)
+	}
+
+	red := NewSGR()
+	red.ApplyParams([]int{1, 31})
+	if got := red.DiffToANSI(NewSGR(), false, true); got != "\x1b[1;31m" {
+		t.Errorf("Unexpected diff from default %q", got)
+	}
+
+	reset := red.Copy()
+	reset.ApplyParams([]int{0})
+	if !reset.Equals(NewSGR()) {
+		t.Fatalf("Expected SGR 0 to restore the custom default, got %v", reset)
+	}
+	if got := reset.DiffToANSI(red, false, true); got != "\x1b[0m" {
+		t.Errorf("Expected a reset back to the custom default, got %q", got)
+	}
+
+	// The former light gray on black is now an explicit style
+	gray := NewSGRWithDefaults(ColorValue{Type: ColorStandard, Index: 7}, black)
+	if got := gray.DiffToANSI(NewSGR(), false, false); got != "\x1b[37;40m" {
+		t.Errorf("Unexpected diff to light gray on black %q", got)
+	}
+}
+
+func TestSuperscriptSubscriptRoundTrip(t *testing.T) {
+	sup := Token{Type: TokenSGR, Parameters: []string{"73"}}.ToSGR(nil)
+	if !sup.Superscript || sup.Subscript {
+		t.Fatalf("Expected superscript only, got %v", sup)
+	}
+
+	if got := sup.DiffToANSI(NewSGR(), false, false); got != "\x1b[73m" {
+		t.Errorf("Unexpected diff to superscript %q", got)
+	}
+
+	replayed := NewSGR()
+	replayed.ApplyParams(sup.Diff(NewSGR(), false))
+	if !replayed.Equals(sup) {
+		t.Errorf("Expected replayed state %v, got %v", sup, replayed)
+	}
+
+	sub := sup.Copy()
+	sub.ApplyParams([]int{74})
+	if sub.Superscript || !sub.Subscript {
+		t.Fatalf("Expected 74 to replace superscript by subscript, got %v", sub)
+	}
+	if got := sub.DiffToANSI(sup, false, false); got != "\x1b[74m" {
+		t.Errorf("Unexpected diff to subscript %q", got)
+	}
+
+	off := sub.Copy()
+	off.ApplyParams([]int{75})
+	if off.Subscript || !off.Equals(NewSGR()) {
+		t.Fatalf("Expected 75 to reset the position, got %v", off)
+	}
+
+	bold := NewSGR()
+	bold.ApplyParams([]int{1, 73})
+	plainBold := NewSGR()
+	plainBold.Bold = true
+	if got := plainBold.DiffToANSI(bold, false, false); got != "\x1b[75m" {
+		t.Errorf("Unexpected modern diff %q", got)
+	}
+	if got := plainBold.DiffToANSI(bold, false, true); got != "\x1b[0;1;37;40m" {
+		t.Errorf("Unexpected legacy diff %q", got)
+	}
+}
+
+func TestColorValueToRGB(t *testing.T) {
+	tests := []struct {
+		name    string
+		color   ColorValue
+		useVGA  bool
+		r, g, b uint8
+	}{
+		{"VGA red", ColorValue{Type: ColorStandard, Index: 1}, true, 0xAA, 0x00, 0x00},
+		{"Modern red", ColorValue{Type: ColorStandard, Index: 1}, false, 0xCD, 0x00, 0x00},
+		{"Indexed 196", ColorValue{Type: ColorIndexed, Index: 196}, false, 0xFF, 0x00, 0x00},
+		{"Indexed gray", ColorValue{Type: ColorIndexed, Index: 232}, true, 0x08, 0x08, 0x08},
+		{"Direct RGB", ColorValue{Type: ColorRGB, R: 0x12, G: 0x34, B: 0x56}, true, 0x12, 0x34, 0x56},
+		{"Default", ColorValue{Type: ColorDefault}, true, 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, g, b := tt.color.ToRGB(tt.useVGA)
+			if r != tt.r || g != tt.g || b != tt.b {
+				t.Errorf("Expected #%02x%02x%02x, got #%02x%02x%02x", tt.r, tt.g, tt.b, r, g, b)
+			}
+		})
+	}
+}
+
+func TestSGRSameDisplay(t *testing.T) {
+	apply := func(params ...int) *SGR {
+		sgr := NewSGR()
+		sgr.ApplyParams(params)
+		return sgr
+	}
+
+	tests := []struct {
+		name string
+		a, b *SGR
+		same bool
+	}{
+		{"bright foreground as bold", apply(93), apply(1, 33), true},
+		{"default foreground", apply(39), apply(37), true},
+		{"default background", apply(49), apply(40), true},
+		{"reverse video", apply(7, 31, 44), apply(34, 41), true},
+		{"hidden text", apply(8, 31, 44), apply(34, 44), true},
+		{"indexed and standard", apply(38, 5, 1), apply(31), true},
+		{"bold on RGB", apply(1, 38, 2, 1, 2, 3), apply(38, 2, 1, 2, 3), false},
+		{"other color", apply(31), apply(32), false},
+		{"underline", apply(4), apply(), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.a.SameDisplay(tt.b); got != tt.same {
+				t.Errorf("Expected %v, got %v", tt.same, got)
+			}
+		})
+	}
+}
+
+func TestParseColorValue(t *testing.T) {
+	colors := []ColorValue{
+		{Type: ColorDefault},
+		{Type: ColorStandard, Index: 9},
+		{Type: ColorIndexed, Index: 123},
+		{Type: ColorRGB, R: 255, B: 128, A: 255},
+		{Type: ColorRGB, R: 1, G: 2, B: 3, A: 64, HasAlpha: true},
+	}
+
+	for _, color := range colors {
+		t.Run(color.String(), func(t *testing.T) {
+			got, err := ParseColorValue(color.String())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != color {
+				t.Errorf("Expected %+v, got %+v", color, got)
+			}
+		})
+	}
+
+	for _, invalid := range []string{"", "std:16", "idx:256", "rgb(1,2)", "rgba(1,2,3)", "rgb(1,2,300)", "hsl(1,2,3)"} {
+		if _, err := ParseColorValue(invalid); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestPalette256(t *testing.T) {
+	tests := []struct {
+		index uint8
+		want  [3]uint8
+	}{
+		{9, ModernPalette[9]},
+		{16, [3]uint8{0x00, 0x00, 0x00}},
+		{110, [3]uint8{0x87, 0xAF, 0xD7}},
+		{231, [3]uint8{0xFF, 0xFF, 0xFF}},
+		{255, [3]uint8{0xEE, 0xEE, 0xEE}},
+	}
+
+	for _, tt := range tests {
+		if got := Palette256[tt.index]; got != tt.want {
+			t.Errorf("Palette256[%d]: expected %v, got %v", tt.index, tt.want, got)
+		}
+
+		r, g, b := ColorValue{Type: ColorIndexed, Index: tt.index}.ToRGB(false)
+		if [3]uint8{r, g, b} != tt.want {
+			t.Errorf("ToRGB of index %d: expected %v, got %v", tt.index, tt.want, [3]uint8{r, g, b})
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package types
+
+/////////////////////////////////////////////////////////////////////////////
+// STYLED RUNS
+/////////////////////////////////////////////////////////////////////////////
+
+// StyledRun is a text token with the SGR style in effect at that point
+type StyledRun struct {
+	Text string `json:"text"`
+	SGR  *SGR   `json:"sgr"`
+	Pos  int    `json:"pos"`
+}
+
+// ResolveStyles folds SGR tokens into a running style and returns one run per
+// text token. Unlike the virtual terminal, cursor moves and width are ignored.
+func ResolveStyles(tokens []Token) []StyledRun {
+	runs := []StyledRun{}
+	current := NewSGR()
+
+	for _, token := range tokens {
+		switch token.Type {
+		case TokenSGR:
+			current = token.ToSGR(current)
+
+		case TokenText:
+			runs = append(runs, StyledRun{
+				Text: token.Value,
+				SGR:  current.Copy(),
+				Pos:  token.Pos,
+			})
+		}
+	}
+
+	return runs
+}
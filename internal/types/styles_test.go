@@ -0,0 +1,56 @@
+package types
+
+import "testing"
+
+func TestResolveStyles(t *testing.T) {
+	tokens := []Token{
+		{Type: TokenText, Pos: 0, Value: "plain"},
+		{Type: TokenSGR, Pos: 5, Parameters: []string{"1", "31"}},
+		{Type: TokenText, Pos: 12, Value: "red"},
+		{Type: TokenC0, Pos: 15, C0Code: 0x0A},
+		{Type: TokenText, Pos: 16, Value: "still red"},
+		{Type: TokenSGR, Pos: 25, Parameters: []string{"0"}},
+		{Type: TokenText, Pos: 29, Value: "reset"},
+	}
+
+	red := NewSGR()
+	red.Bold = true
+	red.FgColor = ColorValue{Type: ColorStandard, Index: 1}
+
+	expected := []StyledRun{
+		{Text: "plain", SGR: NewSGR(), Pos: 0},
+		{Text: "red", SGR: red, Pos: 12},
+		{Text: "still red", SGR: red, Pos: 16},
+		{Text: "reset", SGR: NewSGR(), Pos: 29},
+	}
+
+	runs := ResolveStyles(tokens)
+	if len(runs) != len(expected) {
+		t.Fatalf("Expected %d runs, got %d", len(expected), len(runs))
+	}
+
+	for i, want := range expected {
+		got := runs[i]
+		if got.Text != want.Text || got.Pos != want.Pos {
+			t.Errorf("Run %d: expected %q at %d, got %q at %d", i, want.Text, want.Pos, got.Text, got.Pos)
+		}
+		if !got.SGR.Equals(want.SGR) {
+			t.Errorf("Run %d: expected style %v, got %v", i, want.SGR, got.SGR)
+		}
+	}
+}
+
+func TestResolveStylesRunsDoNotShareSGR(t *testing.T) {
+	tokens := []Token{
+		{Type: TokenSGR, Parameters: []string{"31"}},
+		{Type: TokenText, Value: "a"},
+		{Type: TokenText, Value: "b"},
+	}
+
+	runs := ResolveStyles(tokens)
+	runs[0].SGR.Bold = true
+
+	if runs[1].SGR.Bold {
+		t.Errorf("Expected runs to hold independent styles")
+	}
+}
@@ -3,6 +3,7 @@ package types
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 )
 
 /////////////////////////////////////////////////////////////////////////////
@@ -23,6 +24,7 @@ const (
 	TokenEscape
 	TokenSauce
 	TokenUnknown
+	TokenAPC
 )
 
 func (t TokenType) String() string {
@@ -49,11 +51,36 @@ func (t TokenType) String() string {
 		return "TokenSauce"
 	case TokenUnknown:
 		return "TokenUnknown"
+	case TokenAPC:
+		return "TokenAPC"
 	default:
 		return fmt.Sprintf("TokenType(%d)", t)
 	}
 }
 
+// IsVisible reports whether tokens of type t are printed, only text is
+func (t TokenType) IsVisible() bool {
+	return t == TokenText
+}
+
+// IsControl reports whether tokens of type t drive the terminal rather than
+// print: control codes and sequences, styles included. SAUCE records and
+// unknown tokens are neither visible nor controls.
+func (t TokenType) IsControl() bool {
+	switch t {
+	case TokenC0, TokenC1, TokenCSI, TokenCSIInterupted, TokenSGR, TokenDCS, TokenOSC, TokenAPC, TokenEscape:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsEscape reports whether tokens of type t are sequences introduced by ESC,
+// that is every control but the C0 codes
+func (t TokenType) IsEscape() bool {
+	return t.IsControl() && t != TokenC0
+}
+
 func (t TokenType) MarshalJSON() ([]byte, error) {
 	return json.Marshal(t.String())
 }
@@ -87,6 +114,8 @@ func (t *TokenType) UnmarshalJSON(data []byte) error {
 		*t = TokenSauce
 	case "TokenUnknown":
 		*t = TokenUnknown
+	case "TokenAPC":
+		*t = TokenAPC
 	default:
 		return fmt.Errorf("unknown TokenType: %s", s)
 	}
@@ -104,6 +133,8 @@ type Token struct {
 	Raw           string    `json:"raw"`
 	Value         string    `json:"value,omitempty"`
 	Parameters    []string  `json:"parameters,omitempty"`
+	Prefix        string    `json:"prefix,omitempty"`        // CSI private prefix (e.g. "?" in ESC[?25h)
+	Intermediates string    `json:"intermediates,omitempty"` // CSI intermediate bytes (e.g. " " in ESC[2 q)
 	C0Code        byte      `json:"c0_code,omitempty"`
 	C1Code        string    `json:"c1_code,omitempty"`
 	CSINotation   string    `json:"csi_notation,omitempty"`
@@ -144,6 +175,7 @@ var C0Names = map[byte]string{
 	0x1D: "GS",
 	0x1E: "RS",
 	0x1F: "US",
+	0x7F: "DEL", // Only tokenized as a control with DeleteAsControl
 }
 
 func (t Token) String() string {
@@ -165,6 +197,8 @@ func (t Token) String() string {
 		return "DCS: " + t.Raw
 	case TokenOSC:
 		return "OSC: " + t.Raw
+	case TokenAPC:
+		return "APC: " + t.Raw
 	case TokenEscape:
 		return "ESC: " + t.Raw
 	default:
@@ -172,6 +206,77 @@ func (t Token) String() string {
 	}
 }
 
+// ToSGR applies the token parameters onto a copy of base (a default SGR when
+// nil) and returns the resulting style. Empty parameters behave as 0 and no
+// parameters at all reset the style, like ESC[m.
+func (t Token) ToSGR(base *SGR) *SGR {
+	sgr := NewSGR()
+	if base != nil {
+		sgr = base.Copy()
+	}
+
+	params := make([]int, 0, len(t.Parameters))
+	for _, p := range t.Parameters {
+		if p == "" {
+			params = append(params, 0)
+			continue
+		}
+		if val, err := strconv.Atoi(p); err == nil {
+			params = append(params, val)
+		}
+	}
+
+	if len(params) == 0 {
+		sgr.Reset()
+	} else {
+		sgr.ApplyParams(params)
+	}
+
+	return sgr
+}
+
+// ParseDoubleNumbersParam parses the numeric parameters of a CSI sequence.
+// An omitted parameter keeps its default, extra ones are ignored and an
+// invalid one gives back all the defaults.
+func ParseDoubleNumbersParam(params []string, defaultValue []int) []int {
+	// Work on a copy, callers may share the default slice
+	result := make([]int, len(defaultValue))
+	copy(result, defaultValue)
+
+	for i := 0; i < len(params) && i < len(result); i++ {
+		// An omitted parameter (ESC[;12H) keeps its default
+		if params[i] == "" {
+			continue
+		}
+
+		num, err := strconv.Atoi(params[i])
+		if err != nil {
+			copy(result, defaultValue)
+			return result
+		}
+
+		result[i] = num
+	}
+
+	return result
+}
+
+// ExtractTitle returns the window title set by the last OSC 0 or OSC 2
+// token, like the virtual terminal does, or "" when there is none
+func ExtractTitle(tokens []Token) string {
+	title := ""
+	for _, token := range tokens {
+		if token.Type != TokenOSC || len(token.Parameters) < 2 {
+			continue
+		}
+		if token.Parameters[0] == "0" || token.Parameters[0] == "2" {
+			title = token.Parameters[1]
+		}
+	}
+
+	return title
+}
+
 /////////////////////////////////////////////////////////////////////////////
 // TOKEN STATS
 /////////////////////////////////////////////////////////////////////////////
@@ -183,8 +288,17 @@ type TokenStats struct {
 	CSISequences        map[string]int    `json:"csi_sequences"`
 	C0Codes             map[byte]int      `json:"c0_codes"`
 	C1Codes             map[string]int    `json:"c1_codes"`
+	ColorUsage          map[string]int    `json:"color_usage"` // Text cells per displayed color ("fg:std:1", "bg:rgb(255,0,128)"), bold and reverse applied
 	TotalTextLength     int               `json:"total_text_length"`
+	LineCount           int               `json:"line_count"`     // Lines up to the last one holding text
+	MaxLineWidth        int               `json:"max_line_width"` // Columns of the widest line, text wrapping at the tokenizer screen width
 	FileSize            int64             `json:"file_size"`
 	ParsedPercent       float64           `json:"parsed_percent"`
 	PosFirstBadSequence int64             `json:"pos_first_bad_sequence"`
 }
+
+// IsFullyParsed reports whether the tokenizer went through the whole input
+// without meeting an interrupted sequence
+func (s TokenStats) IsFullyParsed() bool {
+	return s.ParsedPercent >= 100 && s.PosFirstBadSequence == 0
+}
@@ -0,0 +1,221 @@
+package types
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenToSGR(t *testing.T) {
+	bold := NewSGR()
+	bold.Bold = true
+	bold.FgColor = ColorValue{Type: ColorStandard, Index: 1}
+
+	tests := []struct {
+		name     string
+		base     *SGR
+		params   []string
+		expected func() *SGR
+	}{
+		{
+			name:     "Reset",
+			base:     bold,
+			params:   []string{"0"},
+			expected: NewSGR,
+		},
+		{
+			name:     "No params resets",
+			base:     bold,
+			params:   nil,
+			expected: NewSGR,
+		},
+		{
+			name:     "Empty param behaves as 0",
+			base:     bold,
+			params:   []string{""},
+			expected: NewSGR,
+		},
+		{
+			name:   "Bold and color",
+			base:   nil,
+			params: []string{"1", "31"},
+			expected: func() *SGR {
+				return bold.Copy()
+			},
+		},
+		{
+			name:   "Keeps base attributes",
+			base:   bold,
+			params: []string{"44"},
+			expected: func() *SGR {
+				s := bold.Copy()
+				s.BgColor = ColorValue{Type: ColorStandard, Index: 4}
+				return s
+			},
+		},
+		{
+			name:   "Indexed color",
+			base:   nil,
+			params: []string{"38", "5", "123"},
+			expected: func() *SGR {
+				s := NewSGR()
+				s.FgColor = ColorValue{Type: ColorIndexed, Index: 123}
+				return s
+			},
+		},
+		{
+			name:   "RGB color",
+			base:   nil,
+			params: []string{"48", "2", "255", "100", "50"},
+			expected: func() *SGR {
+				s := NewSGR()
+				s.BgColor = ColorValue{Type: ColorRGB, R: 255, G: 100, B: 50, A: 255}
+				return s
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := Token{Type: TokenSGR, Parameters: tt.params}
+			got := token.ToSGR(tt.base)
+
+			if expected := tt.expected(); !got.Equals(expected) {
+				t.Errorf("Expected %v, got %v", expected, got)
+			}
+		})
+	}
+}
+
+func TestTokenToSGRDoesNotModifyBase(t *testing.T) {
+	base := NewSGR()
+	Token{Type: TokenSGR, Parameters: []string{"1", "31"}}.ToSGR(base)
+
+	if !base.Equals(NewSGR()) {
+		t.Errorf("Expected base to be unchanged, got %v", base)
+	}
+}
+
+func TestExtractTitle(t *testing.T) {
+	tokens := []Token{
+		{Type: TokenOSC, Parameters: []string{"2", "Draft"}},
+		{Type: TokenText, Value: "art"},
+		{Type: TokenOSC, Parameters: []string{"0", "My Art"}},
+		{Type: TokenOSC, Parameters: []string{"8", "", "https://example.com"}},
+	}
+
+	if got := ExtractTitle(tokens); got != "My Art" {
+		t.Errorf("Expected title %q, got %q", "My Art", got)
+	}
+
+	if got := ExtractTitle(tokens[1:2]); got != "" {
+		t.Errorf("Expected no title, got %q", got)
+	}
+}
+
+func TestTokenTypeClassification(t *testing.T) {
+	tests := []struct {
+		tokenType TokenType
+		visible   bool
+		control   bool
+		escape    bool
+	}{
+		{TokenText, true, false, false},
+		{TokenC0, false, true, false},
+		{TokenC1, false, true, true},
+		{TokenCSI, false, true, true},
+		{TokenCSIInterupted, false, true, true},
+		{TokenSGR, false, true, true},
+		{TokenDCS, false, true, true},
+		{TokenOSC, false, true, true},
+		{TokenAPC, false, true, true},
+		{TokenEscape, false, true, true},
+		{TokenSauce, false, false, false},
+		{TokenUnknown, false, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tokenType.String(), func(t *testing.T) {
+			if got := tt.tokenType.IsVisible(); got != tt.visible {
+				t.Errorf("IsVisible: expected %v, got %v", tt.visible, got)
+			}
+			if got := tt.tokenType.IsControl(); got != tt.control {
+				t.Errorf("IsControl: expected %v, got %v", tt.control, got)
+			}
+			if got := tt.tokenType.IsEscape(); got != tt.escape {
+				t.Errorf("IsEscape: expected %v, got %v", tt.escape, got)
+			}
+		})
+	}
+}
+
+func TestParseDoubleNumbersParam(t *testing.T) {
+	tests := []struct {
+		name         string
+		params       []string
+		defaultValue []int
+		expected     []int
+	}{
+		{
+			name:         "Two valid numbers",
+			params:       []string{"10", "5"},
+			defaultValue: []int{1, 1},
+			expected:     []int{10, 5},
+		},
+		{
+			name:         "Empty params returns default",
+			params:       []string{},
+			defaultValue: []int{1, 1},
+			expected:     []int{1, 1},
+		},
+		{
+			name:         "Omitted row keeps its default",
+			params:       []string{"", "12"},
+			defaultValue: []int{1, 1},
+			expected:     []int{1, 12},
+		},
+		{
+			name:         "Omitted column keeps its default",
+			params:       []string{"6", ""},
+			defaultValue: []int{1, 1},
+			expected:     []int{6, 1},
+		},
+		{
+			name:         "Invalid number returns default",
+			params:       []string{"abc", "5"},
+			defaultValue: []int{1, 1},
+			expected:     []int{1, 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ParseDoubleNumbersParam(tt.params, tt.defaultValue)
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestParseDoubleNumbersParamKeepsDefault(t *testing.T) {
+	defaultValue := []int{1, 1}
+
+	first := ParseDoubleNumbersParam([]string{"10", "5"}, defaultValue)
+	second := ParseDoubleNumbersParam([]string{}, defaultValue)
+
+	if !reflect.DeepEqual(defaultValue, []int{1, 1}) {
+		t.Fatalf("default slice was modified: %v", defaultValue)
+	}
+	if !reflect.DeepEqual(first, []int{10, 5}) {
+		t.Errorf("Expected [10 5], got %v", first)
+	}
+	if !reflect.DeepEqual(second, []int{1, 1}) {
+		t.Errorf("Expected [1 1], got %v", second)
+	}
+
+	// Extra params are ignored instead of indexing out of range
+	third := ParseDoubleNumbersParam([]string{"2", "3", "4"}, defaultValue)
+	if !reflect.DeepEqual(third, []int{2, 3}) {
+		t.Errorf("Expected [2 3], got %v", third)
+	}
+}
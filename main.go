@@ -1,10 +1,12 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"os"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/alecthomas/kong"
 
@@ -17,18 +19,37 @@ type CLI struct {
 	File string `arg:"" optional:"" type:"path" help:"ANSI file to process (reads from stdin if not specified)"`
 
 	Input struct {
-		Iformat   string `short:"f" default:"ansi" enum:"ansi,json, neotex" help:"Input format: ansi, json, neotex"`
-		Iencoding string `short:"e" default:"utf8" enum:"cp437,cp850,utf8,iso-8859-1" help:"Input encoding: cp437, cp850, utf8, iso-8859-1"`
+		Iformat   string `short:"f" default:"ansi" enum:"ansi,json, neotex,pcboard,pipecode" help:"Input format: ansi, json, neotex, pcboard, pipecode"`
+		Iencoding string `short:"e" aliases:"encoding" default:"utf8" enum:"auto,cp437,cp850,cp866,utf8,iso-8859-1,windows-1252" help:"Input encoding: auto, cp437, cp850, cp866, utf8, iso-8859-1, windows-1252"`
+		Recover   bool   `short:"r" help:"Keep parsing after an interrupted CSI sequence (ansi)"`
+		DropDel   bool   `name:"drop-del" help:"Treat DEL (0x7F) as a non-printing control instead of a char (ansi)"`
 	} `embed:"" prefix:"" group:"Input options:"`
 
 	Output struct {
-		Oformat   string `short:"F" default:"neotex" enum:"ansi,json,neotex,plaintext,table,stats" help:"Output format: ansi, json, neotex, plaintext, table, stats"`
-		Oencoding string `short:"E" default:"utf8" enum:"cp437,cp850,utf8,iso-8859-1" help:"Output encoding: cp437, cp850, utf8, iso-8859-1"`
-		Save      string `short:"S" type:"path" help:"Save to file (for -oformat option (neotex)"`
-		Width     int    `short:"W" default:"80" help:"Width text to specified width"`
-		Lines     int    `short:"L" default:"1000" help:"Nb lines text"`
-		Inline    bool   `short:"I" help:"Flatten output on a single line (neotex, ansi, plaintext)"`
-		VGA       bool   `short:"v" help:"Use true VGA colors (not affected by terminal themes)"`
+		Oformat     string `short:"F" default:"neotex" enum:"ansi,bbcode,html,irc,json,markdown,neotex,plaintext,png,svg,table,stats,statsjson" help:"Output format: ansi, bbcode, html, irc, json, markdown, neotex, plaintext, png, svg, table, stats, statsjson"`
+		Oencoding   string `short:"E" default:"utf8" enum:"cp437,cp850,cp866,utf8,iso-8859-1,windows-1252" help:"Output encoding: cp437, cp850, cp866, utf8, iso-8859-1, windows-1252"`
+		Save        string `short:"S" type:"path" help:"Save to file (for -oformat option (neotex)"`
+		Width       int    `short:"W" default:"80" help:"Width text to specified width"`
+		Lines       int    `short:"L" default:"0" help:"Nb lines text, 0 sizes the buffer to fit the input"`
+		Inline      bool   `short:"I" help:"Flatten output on a single line (neotex, ansi, plaintext)"`
+		VGA         bool   `short:"v" help:"Use true VGA colors (not affected by terminal themes)"`
+		Trim        bool   `short:"T" help:"Trim trailing spaces on each line (plaintext)"`
+		ICE         bool   `help:"iCE colors: blink selects a bright background (ansi, bbcode, html, irc, markdown, png, svg)"`
+		Modern      bool   `help:"Turn attributes off with 22/23/24... instead of reset + rebuild (ansi)"`
+		BlinkBright bool   `name:"blink-bright-bg" help:"Write bright backgrounds as blink + 40-47 for DOS viewers, ignored with --modern (ansi)"`
+		TabWidth    int    `default:"8" help:"Columns between tab stops"`
+		Overstrike  bool   `help:"Read char BS char as bold and _ BS char as underline (nroff/man pages)"`
+		Separator   string `default:" | " help:"Separator between text and sequences (neotex input and output)"`
+		KeepUnknown bool   `help:"Keep unknown, DCS and unsupported OSC sequences verbatim (ansi)"`
+		NoSGR       bool   `name:"no-sgr" help:"Strip styles but keep the layout, blanks written as spaces (ansi)"`
+		Positions   bool   `help:"Add the screen position of each token (json)"`
+		Blink       string `default:"keep" enum:"keep,remove,bold,bright-bg" help:"Replace blinking text: keep, remove, bold, bright-bg (ansi)"`
+		Title       bool   `help:"Write the window title (OSC 0/2) as a caption (html, plaintext)"`
+		LineReset   bool   `help:"Start each line with a reset and its full style, so any line can be copied alone (ansi)"`
+		Strip       bool   `help:"Print the text only, dropping styles and controls without rendering cursor moves (ansi input)"`
+		Replacement string `help:"Char written for runes missing from the output encoding, instead of failing (ansi, plaintext)"`
+		BBCodeWrap  string `name:"bbcode-wrap" default:"font=monospace" help:"Tag wrapping the output, e.g. font=monospace, code (tags shown verbatim on most forums) or none (bbcode)"`
+		Font        string `type:"existingfile" help:"Raw 8x16 CP437 font (4096 bytes, .f16 or VGA BIOS dump), a placeholder font is used otherwise (png)"`
 	} `embed:"" prefix:"" group:"Output options:"`
 
 	Debug struct {
@@ -36,32 +57,6 @@ type CLI struct {
 	} `embed:"" prefix:"" group:"Debug options:"`
 }
 
-func ConcatenateTextAndSequence(left, right string, leftWidth int, separator string) string {
-	leftLines := strings.Split(left, "\n")
-	rightLines := strings.Split(right, "\n")
-
-	result := []string{}
-	numLines := len(leftLines)
-
-	for i := 0; i < numLines; i++ {
-		if i < len(leftLines) {
-			leftLine := leftLines[i]
-			rightLine := ""
-			if i < len(rightLines) {
-				rightLine = rightLines[i]
-			}
-
-			if len(leftLine) < leftWidth {
-				break
-			}
-
-			result = append(result, fmt.Sprintf("%s%s%s", leftLine, separator, rightLine))
-		}
-	}
-
-	return strings.Join(result, "\n")
-}
-
 func main() {
 	var cli CLI
 	ctx := kong.Parse(&cli,
@@ -111,6 +106,32 @@ func main() {
 		}
 	}
 
+	// Text only fast path for ANSI input, no tokens are rendered
+	if cli.Output.Strip {
+		if cli.Input.Iformat != "ansi" {
+			fmt.Fprintf(os.Stderr, "Error: --strip requires --iformat=ansi\n")
+			os.Exit(1)
+		}
+
+		text, err := splitans.StripToTextWithOptions(data, cli.Input.Iencoding, splitans.StripOptions{
+			RecoverMode:     cli.Input.Recover,
+			DeleteAsControl: cli.Input.DropDel,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Encoding conversion error: %v\n", err)
+			os.Exit(1)
+		}
+
+		outputBytes, err := convertOutput([]byte(text), cli.Output.Oencoding, cli.Output.Replacement)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error converting to output encoding: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Print(string(outputBytes))
+		return
+	}
+
 	// Convert encoding to UTF-8
 	encoding = cli.Input.Iencoding
 	switch cli.Input.Iformat {
@@ -122,7 +143,11 @@ func main() {
 		encoding = "utf8"
 	}
 
-	data, err = splitans.ConvertToUTF8(data, encoding)
+	if encoding == "auto" {
+		data, _, err = splitans.ConvertToUTF8Auto(data)
+	} else {
+		data, err = splitans.ConvertToUTF8(data, encoding)
+	}
 
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Encoding conversion error: %v\n", err)
@@ -137,7 +162,12 @@ func main() {
 	/////////////////////////////////////////////////////////////////////////////
 	switch cli.Input.Iformat {
 	case "ansi":
-		tok = splitans.NewANSITokenizer(data)
+		ansiTok := splitans.NewANSITokenizer(data)
+		ansiTok.RecoverMode = cli.Input.Recover
+		ansiTok.DeleteAsControl = cli.Input.DropDel
+		ansiTok.ScreenWidth = cli.Output.Width
+		ansiTok.TabWidth = cli.Output.TabWidth
+		tok = ansiTok
 		tokens = tok.Tokenize()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "ANSI parse error: %v\n", err)
@@ -145,12 +175,30 @@ func main() {
 		}
 
 	case "neotex":
-		decodedWidth, tok = splitans.NewNeotexTokenizer(data, cli.Output.Width)
-		tokens = tok.Tokenize()
+		var neotexTok *splitans.NeotexTokenizer
+		decodedWidth, neotexTok, err = splitans.NewNeotexTokenizerWithSeparator(data, cli.Output.Width, cli.Output.Separator)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Neotex parse error: %v\n", err)
 			os.Exit(1)
 		}
+		tok = neotexTok
+		tokens = tok.Tokenize()
+
+	case "json":
+		tok, err = splitans.NewTokensJSONTokenizer(bytes.NewReader(data))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "JSON parse error: %v\n", err)
+			os.Exit(1)
+		}
+		tokens = tok.Tokenize()
+
+	case "pcboard":
+		tok = splitans.NewPCBoardTokenizer(data)
+		tokens = tok.Tokenize()
+
+	case "pipecode":
+		tok = splitans.NewPipeCodeTokenizer(data)
+		tokens = tok.Tokenize()
 
 	// case "neotex":
 	// 	tok = neotex.NewTokenizer(textData, seqData)
@@ -169,6 +217,11 @@ func main() {
 		cli.Output.Width = decodedWidth
 	}
 
+	// Without --lines, the buffer is sized to hold the whole rendered input
+	if cli.Output.Lines <= 0 {
+		cli.Output.Lines = splitans.EstimateHeight(tokens, cli.Output.Width, cli.Output.TabWidth)
+	}
+
 	// Validate --write option usage
 	if cli.Output.Save != "" && cli.Output.Oformat != "neotex" {
 		fmt.Fprintf(os.Stderr, "Error: --write option can only be used with --oformat=neotex\n")
@@ -180,6 +233,14 @@ func main() {
 	// 	os.Exit(1)
 	// }
 
+	// --no-sgr writes no styles, the style options would be silently ignored
+	if cli.Output.Oformat == "ansi" && cli.Output.NoSGR {
+		if flags := noSGRConflicts(&cli); len(flags) > 0 {
+			fmt.Fprintf(os.Stderr, "Error: --no-sgr cannot be used with %s (no styles are written)\n", strings.Join(flags, ", "))
+			os.Exit(1)
+		}
+	}
+
 	// Validate output encoding for neotex (must be utf8)
 	if cli.Output.Oformat == "neotex" && cli.Output.Oencoding != "utf8" {
 		fmt.Fprintf(os.Stderr, "Error: --oformat=%s requires --Oencoding=utf8 (neotex is always UTF-8)\n", cli.Output.Oformat)
@@ -199,10 +260,21 @@ func main() {
 		// } else {
 		// 	ansiOutput, err = exporter.ExportFlattenedANSI(cli.Output.Width, tokens, cli.Output.Oencoding, cli.Output.VGA)
 		// }
-		if cli.Output.Inline {
-			ansiOutput, err = exporter.ExportFlattenedANSIInline(cli.Output.Width, cli.Output.Lines, tokens, cli.Output.Oencoding, cli.Output.VGA)
+		opts := exporter.DefaultANSIOptions()
+		opts.UseVGAColors = cli.Output.VGA
+		opts.Inline = cli.Output.Inline
+		opts.ICEColors = cli.Output.ICE
+		opts.LegacyMode = !cli.Output.Modern
+		opts.BlinkBrightBg = cli.Output.BlinkBright
+		opts.TabWidth = cli.Output.TabWidth
+		opts.Overstrike = cli.Output.Overstrike
+		opts.PreserveUnknown = cli.Output.KeepUnknown
+		opts.Blink = blinkModes[cli.Output.Blink]
+		opts.LineIndependent = cli.Output.LineReset
+		if cli.Output.NoSGR {
+			ansiOutput, err = exporter.ExportFlattenedLayout(cli.Output.Width, cli.Output.Lines, tokens, cli.Output.Oencoding)
 		} else {
-			ansiOutput, err = exporter.ExportFlattenedANSI(cli.Output.Width, cli.Output.Lines, tokens, cli.Output.Oencoding, cli.Output.VGA)
+			ansiOutput, err = exporter.ExportFlattenedANSIWithOptions(cli.Output.Width, cli.Output.Lines, tokens, cli.Output.Oencoding, opts)
 		}
 
 		if err != nil {
@@ -211,7 +283,7 @@ func main() {
 		}
 
 		// Convert to output encoding if needed
-		outputBytes, err := splitans.ConvertToEncoding([]byte(ansiOutput), cli.Output.Oencoding)
+		outputBytes, err := convertOutput([]byte(ansiOutput), cli.Output.Oencoding, cli.Output.Replacement)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error converting to output encoding: %v\n", err)
 			os.Exit(1)
@@ -245,13 +317,96 @@ func main() {
 
 		// metadatas := neotex.ExtractMetadata(strings.Split(sequenceText, "\n"))
 
-		combined := ConcatenateTextAndSequence(plainText, sequenceText, cli.Output.Width, " | ")
+		combined := exporter.JoinNeotex(plainText, sequenceText, cli.Output.Width, cli.Output.Separator)
 		fmt.Println(combined)
 
+	case "bbcode":
+		vt := newRenderVT(&cli, tokens)
+		opts := exporter.DefaultBBCodeOptions()
+		opts.Wrapper = cli.Output.BBCodeWrap
+		if opts.Wrapper == "none" {
+			opts.Wrapper = ""
+		}
+		bbcodeOutput, err := exporter.ExportBBCodeWithOptions(vt, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error exporting to BBCode: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Print(bbcodeOutput)
+	case "html":
+		vt := newRenderVT(&cli, tokens)
+		htmlOutput, err := exporter.ExportHTMLWithOptions(vt, exporter.HTMLOptions{Title: cli.Output.Title})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error exporting to HTML: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Print(htmlOutput)
+	case "irc":
+		vt := newRenderVT(&cli, tokens)
+		ircOutput, err := exporter.ExportIRC(vt)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error exporting to IRC: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Print(ircOutput)
+	case "markdown":
+		vt := newRenderVT(&cli, tokens)
+		markdownOutput, err := exporter.ExportMarkdown(vt)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error exporting to Markdown: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Print(markdownOutput)
+	case "svg":
+		vt := newRenderVT(&cli, tokens)
+		opts := exporter.DefaultSVGOptions()
+		opts.UseVGAPalette = cli.Output.VGA
+		svgOutput, err := exporter.ExportSVG(vt, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error exporting to SVG: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Print(svgOutput)
+	case "png":
+		vt := newRenderVT(&cli, tokens)
+		opts := exporter.DefaultPNGOptions()
+		opts.UseVGAPalette = cli.Output.VGA
+		if cli.Output.Font != "" {
+			font, err := os.ReadFile(cli.Output.Font)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error reading font: %v\n", err)
+				os.Exit(1)
+			}
+			opts.Font = font
+		}
+		if err := exporter.ExportPNG(vt, os.Stdout, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error exporting to PNG: %v\n", err)
+			os.Exit(1)
+		}
 	case "json":
-		exporter.TokensJSON(tok)
+		if cli.Output.Positions {
+			if err := exporter.TokensJSONWithPositions(tok, cli.Output.Width, cli.Output.Lines); err != nil {
+				fmt.Fprintf(os.Stderr, "Error exporting to JSON: %v\n", err)
+				os.Exit(1)
+			}
+		} else {
+			exporter.TokensJSON(tok)
+		}
 	case "stats":
 		exporter.DisplayStats(tok)
+	case "statsjson":
+		data, err := exporter.StatsJSON(tok)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error exporting stats: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println(string(data))
 	case "table":
 		stats := tok.GetStats()
 		if stats.PosFirstBadSequence > 0 {
@@ -265,19 +420,21 @@ func main() {
 			os.Exit(1)
 		}
 	case "plaintext":
-		var plainText string
-		if cli.Output.Inline {
-			plainText, err = exporter.ExportFlattenedTextInline(cli.Output.Width, cli.Output.Lines, tokens, cli.Output.Oencoding)
-		} else {
-			plainText, err = exporter.ExportFlattenedText(cli.Output.Width, cli.Output.Lines, tokens, cli.Output.Oencoding)
-		}
+		opts := splitans.DefaultPlainTextOptions()
+		opts.TabWidth = cli.Output.TabWidth
+		opts.Overstrike = cli.Output.Overstrike
+		opts.Title = cli.Output.Title
+		opts.Inline = cli.Output.Inline
+		// Trimming inline output would glue the lines together
+		opts.Trim = cli.Output.Trim && !cli.Output.Inline
+		plainText, err := exporter.ExportFlattenedTextWithOptions(cli.Output.Width, cli.Output.Lines, tokens, cli.Output.Oencoding, opts)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error displaying plain text: %v\n", err)
 			os.Exit(1)
 		}
 
 		// Convert to output encoding if needed
-		outputBytes, err := splitans.ConvertToEncoding([]byte(plainText), cli.Output.Oencoding)
+		outputBytes, err := convertOutput([]byte(plainText), cli.Output.Oencoding, cli.Output.Replacement)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error converting to output encoding: %v\n", err)
 			os.Exit(1)
@@ -296,3 +453,59 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// newRenderVT renders tokens on a virtual terminal set up from the output
+// options, for the exporters working on the rendered buffer
+func newRenderVT(cli *CLI, tokens []types.Token) *splitans.VirtualTerminal {
+	vt := splitans.NewVirtualTerminal(cli.Output.Width, cli.Output.Lines, "utf8", cli.Output.VGA)
+	vt.SetICEColors(cli.Output.ICE)
+	vt.SetTabWidth(cli.Output.TabWidth)
+	vt.SetOverstrike(cli.Output.Overstrike)
+	if err := vt.ApplyTokens(tokens); err != nil {
+		fmt.Fprintf(os.Stderr, "Error applying tokens: %v\n", err)
+		os.Exit(1)
+	}
+
+	return vt
+}
+
+// noSGRConflicts returns the style options set along with --no-sgr
+func noSGRConflicts(cli *CLI) []string {
+	var flags []string
+	if cli.Output.LineReset {
+		flags = append(flags, "--line-reset")
+	}
+	if cli.Output.Blink != "keep" {
+		flags = append(flags, "--blink")
+	}
+	if cli.Output.ICE {
+		flags = append(flags, "--ice")
+	}
+	if cli.Output.Modern {
+		flags = append(flags, "--modern")
+	}
+	if cli.Output.BlinkBright {
+		flags = append(flags, "--blink-bright-bg")
+	}
+
+	return flags
+}
+
+// blinkModes maps the --blink values to their StripBlink mode
+var blinkModes = map[string]splitans.BlinkMode{
+	"keep":      splitans.BlinkKeep,
+	"remove":    splitans.BlinkRemove,
+	"bold":      splitans.BlinkToBold,
+	"bright-bg": splitans.BlinkToBrightBg,
+}
+
+// convertOutput converts UTF-8 output to the output encoding, substituting
+// the first rune of replacement for unmappable runes when it is set
+func convertOutput(data []byte, encoding string, replacement string) ([]byte, error) {
+	if replacement == "" {
+		return splitans.ConvertToEncoding(data, encoding)
+	}
+
+	r, _ := utf8.DecodeRuneInString(replacement)
+	return splitans.ConvertToEncodingLossy(data, encoding, r)
+}
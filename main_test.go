@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runMain runs the splitans command with args in a child test process, so
+// os.Exit in main does not stop the tests
+func runMain(t *testing.T, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestMainProcess$")
+	cmd.Env = append(os.Environ(), "SPLITANS_MAIN_ARGS="+strings.Join(args, "\x1f"))
+	output, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("splitans %v failed: %v", args, err)
+	}
+	return string(output)
+}
+
+func TestMainProcess(t *testing.T) {
+	args, ok := os.LookupEnv("SPLITANS_MAIN_ARGS")
+	if !ok {
+		t.Skip("only run as the child process of runMain")
+	}
+
+	os.Args = append([]string{"splitans"}, strings.Split(args, "\x1f")...)
+	main()
+	os.Exit(0)
+}
+
+func TestPlainTextKeepsAllLines(t *testing.T) {
+	var input strings.Builder
+	for i := 0; i < 1500; i++ {
+		fmt.Fprintf(&input, "line%d\r\n", i)
+	}
+
+	path := filepath.Join(t.TempDir(), "tall.ans")
+	if err := os.WriteFile(path, []byte(input.String()), 0o644); err != nil {
+		t.Fatalf("unexpected write error: %v", err)
+	}
+
+	output := strings.TrimRight(runMain(t, path, "-F", "plaintext", "--trim"), "\n")
+	lines := strings.Split(output, "\n")
+	if len(lines) != 1500 {
+		t.Fatalf("expected 1500 lines, got %d", len(lines))
+	}
+	for i, line := range lines {
+		if want := fmt.Sprintf("line%d", i); line != want {
+			t.Fatalf("line %d: expected %q, got %q", i+1, want, line)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package splitans
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/badele/splitans/internal/exporter"
+	"github.com/badele/splitans/internal/processor"
+)
+
+// batchExtensions maps the BatchConvert formats to their output extension
+var batchExtensions = map[string]string{
+	"text":   ".txt",
+	"ansi":   ".ans",
+	"neotex": ".neotex",
+	"html":   ".html",
+}
+
+// BatchOptions configures BatchConvert
+type BatchOptions struct {
+	Format         string // Output format: text, ansi, neotex or html (text when empty)
+	InputEncoding  string // Encoding of the input files (auto when empty)
+	OutputEncoding string // Encoding of the text and ansi outputs (utf8 when empty)
+	Width          int    // Columns, the SAUCE width of each file or 80 when 0
+	Lines          int    // Lines, estimated from the tokens of each file when 0
+	Concurrency    int    // Files converted at once (1 when below 1)
+}
+
+// BatchResult is the outcome of one file converted by BatchConvert
+type BatchResult struct {
+	Input         string  // Path of the input file
+	Output        string  // Path of the written file, "" when Err is set
+	ParsedPercent float64 // See TokenStats.ParsedPercent
+	Err           error
+}
+
+// BatchConvert converts the .ans and .asc files found under inputDir to
+// opts.Format and writes them under outputDir with the same relative path,
+// the extension replaced by the one of the format. A file that fails is
+// reported in its BatchResult and does not stop the others. The results
+// are in walk order; the error is set when inputDir cannot be walked or
+// when outputDir is inputDir or inside it, where outputs could overwrite
+// the input files.
+func BatchConvert(inputDir, outputDir string, opts BatchOptions) ([]BatchResult, error) {
+	if opts.Format == "" {
+		opts.Format = "text"
+	}
+	ext, ok := batchExtensions[opts.Format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported batch format %q", opts.Format)
+	}
+
+	inside, err := isWithinDir(outputDir, inputDir)
+	if err != nil {
+		return nil, err
+	}
+	if inside {
+		return nil, fmt.Errorf("output directory %s is inside input directory %s", outputDir, inputDir)
+	}
+
+	var results []BatchResult
+	err = filepath.WalkDir(inputDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".ans", ".asc":
+		default:
+			return nil
+		}
+
+		rel, err := filepath.Rel(inputDir, path)
+		if err != nil {
+			return err
+		}
+		output := filepath.Join(outputDir, strings.TrimSuffix(rel, filepath.Ext(rel))+ext)
+		results = append(results, BatchResult{Input: path, Output: output})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walking %s: %w", inputDir, err)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range max(opts.Concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				result := &results[i]
+				result.ParsedPercent, result.Err = batchConvertFile(result.Input, result.Output, opts)
+				if result.Err != nil {
+					result.Output = ""
+				}
+			}
+		}()
+	}
+
+	for i := range results {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results, nil
+}
+
+// isWithinDir reports whether path is dir or one of its descendants, both
+// resolved to absolute paths without symlinks where they exist
+func isWithinDir(path, dir string) (bool, error) {
+	path, err := resolvePath(path)
+	if err != nil {
+		return false, err
+	}
+	dir, err = resolvePath(dir)
+	if err != nil {
+		return false, err
+	}
+
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false, nil
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)), nil
+}
+
+// resolvePath returns the absolute path of path, following the symlinks of
+// its longest existing prefix
+func resolvePath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	missing := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(resolved, missing), nil
+		}
+		parent := filepath.Dir(path)
+		if parent == path {
+			return filepath.Join(path, missing), nil
+		}
+		missing = filepath.Join(filepath.Base(path), missing)
+		path = parent
+	}
+}
+
+// batchConvertFile converts one file for BatchConvert and returns its
+// parsed percent
+func batchConvertFile(input, output string, opts BatchOptions) (float64, error) {
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return 0, err
+	}
+
+	width := opts.Width
+	if width <= 0 {
+		width = 80
+		if sauce, ok := ParseSauce(data); ok && sauce.Width > 0 {
+			width = sauce.Width
+		}
+	}
+
+	if opts.InputEncoding == "" || opts.InputEncoding == "auto" {
+		data, _, err = ConvertToUTF8Auto(data)
+	} else {
+		data, err = ConvertToUTF8(data, opts.InputEncoding)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	tokenizer := NewANSITokenizer(data)
+	tokens := tokenizer.Tokenize()
+	parsedPercent := tokenizer.GetStats().ParsedPercent
+
+	lines := opts.Lines
+	if lines <= 0 {
+		lines = processor.EstimateHeight(tokens, width, 8)
+	}
+
+	outputEncoding := opts.OutputEncoding
+	if outputEncoding == "" {
+		outputEncoding = "utf8"
+	}
+
+	var converted string
+	switch opts.Format {
+	case "text":
+		converted, err = exporter.ExportFlattenedText(width, lines, tokens, outputEncoding)
+	case "ansi":
+		converted, err = exporter.ExportFlattenedANSI(width, lines, tokens, outputEncoding, false)
+	case "neotex":
+		var text, sequences string
+		text, sequences, err = exporter.ExportFlattenedNeotex(width, lines, tokens)
+		converted = exporter.JoinNeotex(text, sequences, width, DefaultNeotexSeparator) + "\n"
+		outputEncoding = "utf8"
+	case "html":
+		vt := processor.NewVirtualTerminal(width, lines, "utf8", false)
+		if err = vt.ApplyTokens(tokens); err == nil {
+			converted, err = exporter.ExportHTML(vt)
+		}
+		outputEncoding = "utf8"
+	}
+	if err != nil {
+		return parsedPercent, err
+	}
+
+	encoded, err := ConvertToEncoding([]byte(converted), outputEncoding)
+	if err != nil {
+		return parsedPercent, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(output), 0o755); err != nil {
+		return parsedPercent, err
+	}
+
+	return parsedPercent, os.WriteFile(output, encoded, 0o644)
+}
@@ -0,0 +1,93 @@
+package splitans
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBatchConvert(t *testing.T) {
+	inputDir := t.TempDir()
+	outputDir := t.TempDir()
+
+	files := map[string]string{
+		"red.ans":          "\x1b[31mRed\x1b[0m\r\n",
+		"sub/blue.ASC":     "\x1b[44mBlue\x1b[0m\r\n",
+		"notes/readme.txt": "not an art file",
+	}
+	for name, content := range files {
+		path := filepath.Join(inputDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("unexpected mkdir error: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("unexpected write error: %v", err)
+		}
+	}
+
+	results, err := BatchConvert(inputDir, outputDir, BatchOptions{Format: "text", InputEncoding: "utf8", Concurrency: 2})
+	if err != nil {
+		t.Fatalf("unexpected batch error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %+v", results)
+	}
+
+	want := map[string]string{
+		filepath.Join(outputDir, "red.txt"):         "Red",
+		filepath.Join(outputDir, "sub", "blue.txt"): "Blue",
+	}
+	for _, result := range results {
+		if result.Err != nil {
+			t.Fatalf("unexpected error for %s: %v", result.Input, result.Err)
+		}
+		if result.ParsedPercent != 100 {
+			t.Errorf("expected %s fully parsed, got %.1f%%", result.Input, result.ParsedPercent)
+		}
+
+		text, ok := want[result.Output]
+		if !ok {
+			t.Fatalf("unexpected output path %s", result.Output)
+		}
+		data, err := os.ReadFile(result.Output)
+		if err != nil {
+			t.Fatalf("unexpected read error: %v", err)
+		}
+		if strings.TrimSpace(string(data)) != text {
+			t.Errorf("expected %q in %s, got %q", text, result.Output, data)
+		}
+	}
+
+	if _, err := BatchConvert(inputDir, outputDir, BatchOptions{Format: "pdf"}); err == nil {
+		t.Errorf("expected an error for an unsupported format")
+	}
+}
+
+func TestBatchConvertRejectsOutputInsideInput(t *testing.T) {
+	root := t.TempDir()
+	inputDir := filepath.Join(root, "art")
+	if err := os.Mkdir(inputDir, 0o755); err != nil {
+		t.Fatalf("unexpected mkdir error: %v", err)
+	}
+	source := filepath.Join(inputDir, "art.ans")
+	if err := os.WriteFile(source, []byte("\x1b[31mRed\x1b[0m"), 0o644); err != nil {
+		t.Fatalf("unexpected write error: %v", err)
+	}
+
+	for _, outputDir := range []string{inputDir, filepath.Join(inputDir, "out")} {
+		if _, err := BatchConvert(inputDir, outputDir, BatchOptions{Format: "ansi"}); err == nil {
+			t.Errorf("expected an error for output directory %s", outputDir)
+		}
+	}
+
+	data, err := os.ReadFile(source)
+	if err != nil || string(data) != "\x1b[31mRed\x1b[0m" {
+		t.Fatalf("expected the source untouched, got %q (%v)", data, err)
+	}
+
+	// A sibling whose name starts like the input directory is fine
+	if _, err := BatchConvert(inputDir, filepath.Join(root, "art-out"), BatchOptions{Format: "ansi"}); err != nil {
+		t.Fatalf("unexpected batch error: %v", err)
+	}
+}
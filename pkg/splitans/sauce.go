@@ -0,0 +1,113 @@
+package splitans
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+)
+
+// SAUCE record layout, see https://www.acid.org/info/sauce/sauce.htm
+const (
+	sauceRecordSize  = 128
+	sauceCommentSize = 64
+	sauceVersion     = "00"
+	sauceEOF         = 0x1A
+)
+
+// Sauce is the metadata of a SAUCE record for an ANSi file
+type Sauce struct {
+	Title    string   // 35 chars
+	Author   string   // 20 chars
+	Group    string   // 20 chars
+	Date     string   // CCYYMMDD
+	Width    int      // TInfo1, columns
+	Height   int      // TInfo2, lines
+	Comments []string // COMNT lines, 64 chars each
+}
+
+// AppendSauce returns data followed by the EOF byte (0x1A), a COMNT block
+// when s has comments and the 128 bytes SAUCE record of s (version "00",
+// character data of ANSi type). Strings are written in CP437, padded with
+// spaces and cut to the width of their field.
+func AppendSauce(data []byte, s Sauce) []byte {
+	out := bytes.Clone(data)
+	out = append(out, sauceEOF)
+
+	if len(s.Comments) > 0 {
+		out = append(out, "COMNT"...)
+		for _, comment := range s.Comments {
+			out = append(out, sauceField(comment, sauceCommentSize)...)
+		}
+	}
+
+	record := make([]byte, 0, sauceRecordSize)
+	record = append(record, "SAUCE"+sauceVersion...)
+	record = append(record, sauceField(s.Title, 35)...)
+	record = append(record, sauceField(s.Author, 20)...)
+	record = append(record, sauceField(s.Group, 20)...)
+	record = append(record, sauceField(s.Date, 8)...)
+	record = binary.LittleEndian.AppendUint32(record, uint32(len(data)))
+	record = append(record, 1, 1) // DataType Character, FileType ANSi
+	record = binary.LittleEndian.AppendUint16(record, uint16(s.Width))
+	record = binary.LittleEndian.AppendUint16(record, uint16(s.Height))
+	record = binary.LittleEndian.AppendUint16(record, 0)
+	record = binary.LittleEndian.AppendUint16(record, 0)
+	record = append(record, byte(len(s.Comments)), 0) // Comments, TFlags
+	record = append(record, make([]byte, 22)...)      // TInfoS, no font name
+
+	return append(out, record...)
+}
+
+// ParseSauce returns the SAUCE record at the end of data, false when there
+// is none. Trailing spaces of the string fields are removed.
+func ParseSauce(data []byte) (Sauce, bool) {
+	if len(data) < sauceRecordSize {
+		return Sauce{}, false
+	}
+
+	record := data[len(data)-sauceRecordSize:]
+	if string(record[:5]) != "SAUCE" {
+		return Sauce{}, false
+	}
+
+	s := Sauce{
+		Title:  sauceString(record[7:42]),
+		Author: sauceString(record[42:62]),
+		Group:  sauceString(record[62:82]),
+		Date:   sauceString(record[82:90]),
+		Width:  int(binary.LittleEndian.Uint16(record[96:98])),
+		Height: int(binary.LittleEndian.Uint16(record[98:100])),
+	}
+
+	nbComments := int(record[104])
+	commentsStart := len(data) - sauceRecordSize - nbComments*sauceCommentSize - 5
+	if nbComments > 0 && commentsStart >= 0 && string(data[commentsStart:commentsStart+5]) == "COMNT" {
+		for i := range nbComments {
+			start := commentsStart + 5 + i*sauceCommentSize
+			s.Comments = append(s.Comments, sauceString(data[start:start+sauceCommentSize]))
+		}
+	}
+
+	return s, true
+}
+
+// sauceField encodes value in CP437 and pads it with spaces to size bytes
+func sauceField(value string, size int) []byte {
+	encoded, err := ConvertToEncodingLossy([]byte(value), "cp437", '?')
+	if err != nil {
+		encoded = []byte(value)
+	}
+
+	field := bytes.Repeat([]byte{' '}, size)
+	copy(field, encoded)
+	return field
+}
+
+// sauceString decodes a CP437 field without its space or NUL padding
+func sauceString(field []byte) string {
+	decoded, err := ConvertToUTF8(field, "cp437")
+	if err != nil {
+		decoded = field
+	}
+	return strings.TrimRight(string(decoded), " \x00")
+}
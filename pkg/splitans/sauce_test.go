@@ -0,0 +1,61 @@
+package splitans
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAppendSauceRoundTrip(t *testing.T) {
+	art := []byte("\x1b[1;31mHello\x1b[0m\r\n")
+	sauce := Sauce{
+		Title:    "Héllo",
+		Author:   "badele",
+		Group:    "splitans",
+		Date:     "20261016",
+		Width:    80,
+		Height:   25,
+		Comments: []string{"first comment", "second comment"},
+	}
+
+	out := AppendSauce(art, sauce)
+
+	if expected := len(art) + 1 + 5 + 2*64 + 128; len(out) != expected {
+		t.Fatalf("expected %d bytes, got %d", expected, len(out))
+	}
+	if !bytes.HasPrefix(out, append(art, 0x1A)) {
+		t.Fatalf("expected the art followed by EOF, got %q", out[:len(art)+1])
+	}
+
+	record := out[len(out)-128:]
+	if string(record[:7]) != "SAUCE00" {
+		t.Fatalf("expected SAUCE00 id, got %q", record[:7])
+	}
+	if title := string(record[7:42]); title != "H\x82llo"+strings.Repeat(" ", 30) {
+		t.Fatalf("expected a space padded CP437 title, got %q", title)
+	}
+
+	parsed, ok := ParseSauce(out)
+	if !ok {
+		t.Fatalf("expected a SAUCE record")
+	}
+	if !reflect.DeepEqual(parsed, sauce) {
+		t.Fatalf("expected %+v, got %+v", sauce, parsed)
+	}
+
+	// The tokenizer stops at EOF, the record is not rendered
+	text, err := ExportFlattenedText(80, 2, TokenizeString(string(out)), "utf8")
+	if err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+	if strings.Contains(text, "SAUCE") {
+		t.Fatalf("expected the SAUCE record to be skipped, got %q", text)
+	}
+}
+
+func TestParseSauceMissing(t *testing.T) {
+	if _, ok := ParseSauce([]byte("no metadata")); ok {
+		t.Fatalf("expected no SAUCE record")
+	}
+}
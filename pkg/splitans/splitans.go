@@ -21,6 +21,8 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"strings"
+	"unicode/utf8"
 
 	"golang.org/x/text/encoding"
 	"golang.org/x/text/encoding/charmap"
@@ -29,6 +31,9 @@ import (
 	"github.com/badele/splitans/internal/exporter"
 	"github.com/badele/splitans/internal/importer/ansi"
 	"github.com/badele/splitans/internal/importer/neotex"
+	"github.com/badele/splitans/internal/importer/pcboard"
+	"github.com/badele/splitans/internal/importer/pipecode"
+	"github.com/badele/splitans/internal/importer/tokensjson"
 	"github.com/badele/splitans/internal/processor"
 	"github.com/badele/splitans/internal/types"
 )
@@ -47,6 +52,9 @@ type (
 	// SGR represents Select Graphic Rendition attributes (colors, styles)
 	SGR = types.SGR
 
+	// StyledRun is a text token with the resolved SGR style in effect
+	StyledRun = types.StyledRun
+
 	// ColorValue represents a color (standard, indexed, or RGB)
 	ColorValue = types.ColorValue
 
@@ -62,11 +70,68 @@ type (
 	// VirtualTerminal provides a virtual terminal buffer for processing tokens
 	VirtualTerminal = processor.VirtualTerminal
 
+	// Cell is one rendered cell of a VirtualTerminal, see VirtualTerminal.Cells
+	Cell = processor.Cell
+
+	// Hyperlink is an OSC 8 hyperlink attached to cells
+	Hyperlink = processor.Hyperlink
+
+	// CellDiff is a cell that differs between two rendered buffers
+	CellDiff = processor.CellDiff
+
+	// FrameBoundary reports whether a token starts a new animation frame
+	FrameBoundary = processor.FrameBoundary
+
 	// ANSITokenizer is the tokenizer for ANSI format files
 	ANSITokenizer = ansi.Tokenizer
 
+	// StreamTokenizer tokenizes ANSI data read incrementally from an io.Reader
+	StreamTokenizer = ansi.StreamTokenizer
+
+	// SGROp is one typed operation of an SGR sequence, see ParseSGRStructured
+	SGROp = ansi.SGROp
+
 	// NeotexTokenizer is the tokenizer for Neotex format files
 	NeotexTokenizer = neotex.Tokenizer
+
+	// TokenPosition is the screen cell where a token takes effect
+	TokenPosition = exporter.TokenPosition
+
+	// StatEntry is a counted key of the stats with its readable name
+	StatEntry = exporter.StatEntry
+
+	// NeotexError is a malformed entry of a Neotex sequence line
+	NeotexError = neotex.NeotexError
+
+	// PCBoardTokenizer is the tokenizer for PCBoard @X color code files
+	PCBoardTokenizer = pcboard.Tokenizer
+
+	// PipeCodeTokenizer is the tokenizer for BBS pipe code (|NN) files
+	PipeCodeTokenizer = pipecode.Tokenizer
+
+	// TokensJSONTokenizer restores tokens saved with the JSON exporter
+	TokensJSONTokenizer = tokensjson.Tokenizer
+
+	// SVGOptions configures the SVG rendering
+	SVGOptions = exporter.SVGOptions
+
+	// PNGOptions configures the PNG rendering
+	PNGOptions = exporter.PNGOptions
+
+	// ANSIOptions configures the flattened ANSI export
+	ANSIOptions = exporter.ANSIOptions
+
+	// HTMLOptions configures the HTML export
+	HTMLOptions = exporter.HTMLOptions
+
+	// BBCodeOptions configures the BBCode export
+	BBCodeOptions = exporter.BBCodeOptions
+
+	// PlainTextOptions configures the line endings of the plain text export
+	PlainTextOptions = processor.PlainTextOptions
+
+	// BlinkMode selects what StripBlink renders in place of blink
+	BlinkMode = processor.BlinkMode
 )
 
 // Token type constants
@@ -82,6 +147,7 @@ const (
 	TokenEscape        = types.TokenEscape
 	TokenSauce         = types.TokenSauce
 	TokenUnknown       = types.TokenUnknown
+	TokenAPC           = types.TokenAPC
 )
 
 // Color type constants
@@ -92,9 +158,34 @@ const (
 	ColorRGB      = types.ColorRGB
 )
 
+// SGROp kind constants
+const (
+	SGROpReset          = ansi.SGROpReset
+	SGROpAttributeOn    = ansi.SGROpAttributeOn
+	SGROpAttributeOff   = ansi.SGROpAttributeOff
+	SGROpForeground     = ansi.SGROpForeground
+	SGROpBackground     = ansi.SGROpBackground
+	SGROpUnderlineColor = ansi.SGROpUnderlineColor
+	SGROpUnknown        = ansi.SGROpUnknown
+)
+
+// Blink mode constants
+const (
+	BlinkKeep       = processor.BlinkKeep
+	BlinkRemove     = processor.BlinkRemove
+	BlinkToBold     = processor.BlinkToBold
+	BlinkToBrightBg = processor.BlinkToBrightBg
+)
+
 // VGAPalette contains the 16 standard VGA colors
 var VGAPalette = types.VGAPalette
 
+// ModernPalette contains the xterm default 16 colors
+var ModernPalette = types.ModernPalette
+
+// Palette256 contains the xterm 256 colors
+var Palette256 = types.Palette256
+
 // C0Names maps C0 control codes to their names
 var C0Names = types.C0Names
 
@@ -110,7 +201,7 @@ func stripUTF8BOM(data []byte) []byte {
 }
 
 // ConvertToUTF8 converts byte data from a source encoding to UTF-8.
-// Supported encodings: "utf8", "cp437", "cp850", "iso-8859-1"
+// Supported encodings: "utf8", "cp437", "cp850", "cp866", "iso-8859-1", "windows-1252"
 // The UTF-8 BOM (Byte Order Mark) is automatically stripped if present.
 func ConvertToUTF8(data []byte, sourceEncoding string) ([]byte, error) {
 	if sourceEncoding == "utf8" {
@@ -124,8 +215,12 @@ func ConvertToUTF8(data []byte, sourceEncoding string) ([]byte, error) {
 		decoder = charmap.CodePage437.NewDecoder()
 	case "cp850":
 		decoder = charmap.CodePage850.NewDecoder()
+	case "cp866":
+		decoder = charmap.CodePage866.NewDecoder()
 	case "iso-8859-1":
 		decoder = charmap.ISO8859_1.NewDecoder()
+	case "windows-1252":
+		decoder = charmap.Windows1252.NewDecoder()
 	default:
 		return nil, fmt.Errorf("unsupported encoding: %s", sourceEncoding)
 	}
@@ -140,27 +235,78 @@ func ConvertToUTF8(data []byte, sourceEncoding string) ([]byte, error) {
 	return stripUTF8BOM(utf8Data), nil
 }
 
+// cp850Letters lists bytes that are box drawing or symbols in CP437 but
+// accented letters in CP850.
+var cp850Letters = map[byte]bool{
+	0xB5: true, 0xB6: true, 0xB7: true, 0xC6: true, 0xC7: true,
+	0xD0: true, 0xD1: true, 0xD2: true, 0xD3: true, 0xD4: true,
+	0xD6: true, 0xD7: true, 0xD8: true, 0xDE: true, 0xE0: true,
+	0xE2: true, 0xE3: true, 0xE4: true, 0xE5: true, 0xE7: true,
+	0xE8: true, 0xE9: true, 0xEA: true, 0xEB: true, 0xEC: true,
+	0xED: true,
+}
+
+func isASCIILetter(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z')
+}
+
+// DetectEncoding guesses the encoding of data: "utf8" when a UTF-8 BOM is
+// present or the data is well-formed UTF-8, otherwise "cp437" or "cp850".
+// CP850 is only chosen when the bytes that differ between both code pages
+// mostly sit next to ASCII letters (accented letters inside words), as
+// CP437 (the BBS default) uses them for box drawing.
+func DetectEncoding(data []byte) string {
+	if bytes.HasPrefix(data, utf8BOM) || utf8.Valid(data) {
+		return "utf8"
+	}
+
+	cp437Votes, cp850Votes := 0, 0
+	for i, b := range data {
+		if !cp850Letters[b] {
+			continue
+		}
+
+		inWord := (i > 0 && isASCIILetter(data[i-1])) || (i+1 < len(data) && isASCIILetter(data[i+1]))
+		if inWord {
+			cp850Votes++
+		} else {
+			cp437Votes++
+		}
+	}
+
+	if cp850Votes > cp437Votes {
+		return "cp850"
+	}
+
+	return "cp437"
+}
+
+// ConvertToUTF8Auto converts data to UTF-8 using the encoding guessed by
+// DetectEncoding, and returns the detected encoding.
+func ConvertToUTF8Auto(data []byte) ([]byte, string, error) {
+	sourceEncoding := DetectEncoding(data)
+
+	utf8Data, err := ConvertToUTF8(data, sourceEncoding)
+	if err != nil {
+		return nil, sourceEncoding, err
+	}
+
+	return utf8Data, sourceEncoding, nil
+}
+
 // ConvertToEncoding converts UTF-8 data to the target encoding.
-// Supported encodings: "utf8", "cp437", "cp850", "iso-8859-1"
+// Supported encodings: "utf8", "cp437", "cp850", "cp866", "iso-8859-1", "windows-1252"
 func ConvertToEncoding(data []byte, targetEncoding string) ([]byte, error) {
 	if targetEncoding == "utf8" {
 		return data, nil
 	}
 
-	var encoder *encoding.Encoder
-
-	switch targetEncoding {
-	case "cp437":
-		encoder = charmap.CodePage437.NewEncoder()
-	case "cp850":
-		encoder = charmap.CodePage850.NewEncoder()
-	case "iso-8859-1":
-		encoder = charmap.ISO8859_1.NewEncoder()
-	default:
-		return nil, fmt.Errorf("unsupported encoding: %s", targetEncoding)
+	target, err := targetCharmap(targetEncoding)
+	if err != nil {
+		return nil, err
 	}
 
-	reader := transform.NewReader(bytes.NewReader(data), encoder)
+	reader := transform.NewReader(bytes.NewReader(data), target.NewEncoder())
 	encodedData, err := io.ReadAll(reader)
 	if err != nil {
 		return nil, fmt.Errorf("encoding conversion error: %w", err)
@@ -169,6 +315,54 @@ func ConvertToEncoding(data []byte, targetEncoding string) ([]byte, error) {
 	return encodedData, nil
 }
 
+// ConvertToEncodingLossy converts UTF-8 data to the target encoding like
+// ConvertToEncoding, writing replacement for the runes the target cannot
+// represent (e.g. '-' for an em-dash in cp437) instead of failing.
+func ConvertToEncodingLossy(data []byte, targetEncoding string, replacement rune) ([]byte, error) {
+	if targetEncoding == "utf8" {
+		return data, nil
+	}
+
+	target, err := targetCharmap(targetEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	replacementByte, ok := target.EncodeRune(replacement)
+	if !ok {
+		return nil, fmt.Errorf("replacement %q is not in %s", replacement, targetEncoding)
+	}
+
+	encodedData := make([]byte, 0, len(data))
+	for _, r := range string(data) {
+		b, ok := target.EncodeRune(r)
+		if !ok {
+			b = replacementByte
+		}
+		encodedData = append(encodedData, b)
+	}
+
+	return encodedData, nil
+}
+
+// targetCharmap returns the code page of a non UTF-8 output encoding
+func targetCharmap(targetEncoding string) (*charmap.Charmap, error) {
+	switch targetEncoding {
+	case "cp437":
+		return charmap.CodePage437, nil
+	case "cp850":
+		return charmap.CodePage850, nil
+	case "cp866":
+		return charmap.CodePage866, nil
+	case "iso-8859-1":
+		return charmap.ISO8859_1, nil
+	case "windows-1252":
+		return charmap.Windows1252, nil
+	}
+
+	return nil, fmt.Errorf("unsupported encoding: %s", targetEncoding)
+}
+
 // NormalizeANSIUTF8Input cleans UTF-8 ANSI data by stripping carriage returns when a width is provided.
 // When width is zero or negative, the data is returned untouched.
 func NormalizeANSIUTF8Input(data []byte, width int) []byte {
@@ -193,25 +387,284 @@ func NewANSITokenizer(input []byte) *ANSITokenizer {
 	return ansi.NewANSITokenizer(input)
 }
 
+// TokenPositions returns the screen position (0-indexed) at which each token
+// starts, computed on a width x nblines virtual terminal.
+func TokenPositions(width, nblines int, tokens []Token) ([]TokenPosition, error) {
+	return exporter.TokenPositions(width, nblines, tokens)
+}
+
+// ParseSGRStructured returns the typed operations of SGR parameters
+// (attribute on/off, foreground/background/underline color), extended
+// colors being a single operation.
+func ParseSGRStructured(params []string) []SGROp {
+	return ansi.ParseSGRStructured(params)
+}
+
+// TokenizeString tokenizes a UTF-8 ANSI string, a leading BOM is skipped.
+func TokenizeString(s string) []Token {
+	tokens, _ := TokenizeStringWithStats(s)
+	return tokens
+}
+
+// TokenizeStringWithStats is like TokenizeString and also returns the
+// tokenizer statistics.
+func TokenizeStringWithStats(s string) ([]Token, TokenStats) {
+	tok := NewANSITokenizer(stripUTF8BOM([]byte(s)))
+	tokens := tok.Tokenize()
+	return tokens, tok.GetStats()
+}
+
+// TokenizeReader reads UTF-8 ANSI data from r and tokenizes it, a leading
+// BOM is skipped. Use NewStreamTokenizer to avoid reading everything first.
+func TokenizeReader(r io.Reader) ([]Token, error) {
+	tokens, _, err := TokenizeReaderWithStats(r)
+	return tokens, err
+}
+
+// TokenizeReaderWithStats is like TokenizeReader and also returns the
+// tokenizer statistics.
+func TokenizeReaderWithStats(r io.Reader) ([]Token, TokenStats, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, TokenStats{}, fmt.Errorf("error reading input: %w", err)
+	}
+
+	tok := NewANSITokenizer(stripUTF8BOM(data))
+	tokens := tok.Tokenize()
+	return tokens, tok.GetStats(), nil
+}
+
+// AnalyzeFile tokenizes the content of an ANSI file, converted to UTF-8
+// from the encoding guessed by DetectEncoding, and returns its statistics.
+// A CSI interrupted by a control code stops the parse, so a corrupt file
+// has a ParsedPercent below 100, see TokenStats.IsFullyParsed.
+func AnalyzeFile(data []byte) (TokenStats, error) {
+	utf8Data, _, err := ConvertToUTF8Auto(data)
+	if err != nil {
+		return TokenStats{}, err
+	}
+
+	tok := NewANSITokenizer(utf8Data)
+	tok.Tokenize()
+	return tok.GetStats(), nil
+}
+
+// StripOptions configures the ANSI tokenizer of StripToTextWithOptions
+type StripOptions struct {
+	RecoverMode     bool // Keep parsing after an interrupted CSI, see ANSITokenizer.RecoverMode
+	DeleteAsControl bool // Drop DEL (0x7F) instead of keeping it as text
+}
+
+// StripToText converts data from encoding ("auto" to guess it) and returns
+// its text without any style or control: CR, LF and CR LF become a newline
+// and every other non text token is dropped. Unlike ExportFlattenedText no
+// virtual terminal is involved, cursor moves are simply lost, which makes it
+// a fast way to get grep-able text.
+func StripToText(data []byte, encoding string) (string, error) {
+	return StripToTextWithOptions(data, encoding, StripOptions{})
+}
+
+// StripToTextWithOptions is StripToText with explicit tokenizer options
+func StripToTextWithOptions(data []byte, encoding string, opts StripOptions) (string, error) {
+	var utf8Data []byte
+	var err error
+	if encoding == "auto" {
+		utf8Data, _, err = ConvertToUTF8Auto(data)
+	} else {
+		utf8Data, err = ConvertToUTF8(data, encoding)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	tok := NewANSITokenizer(utf8Data)
+	tok.RecoverMode = opts.RecoverMode
+	tok.DeleteAsControl = opts.DeleteAsControl
+
+	var builder strings.Builder
+	afterCR := false
+	for _, token := range tok.Tokenize() {
+		switch {
+		case token.Type == TokenText:
+			builder.WriteString(token.Value)
+		case token.Type == TokenC0 && token.C0Code == 0x0D:
+			builder.WriteByte('\n')
+		case token.Type == TokenC0 && token.C0Code == 0x0A:
+			if !afterCR {
+				builder.WriteByte('\n')
+			}
+		}
+		afterCR = token.Type == TokenC0 && token.C0Code == 0x0D
+	}
+
+	return builder.String(), nil
+}
+
+// NewStreamTokenizer creates a tokenizer reading ANSI data from r.
+// Tokens are returned one at a time by Next, which returns io.EOF at the end.
+// The input should be UTF-8 encoded.
+func NewStreamTokenizer(r io.Reader) *StreamTokenizer {
+	return ansi.NewStreamTokenizer(r)
+}
+
 // NewNeotexTokenizer creates a new tokenizer for Neotex format data.
 // The width parameter specifies the expected line width.
-// Returns the parsed width (overrides when !TWxx/yy is present) and the tokenizer.
-func NewNeotexTokenizer(data []byte, width int) (int, *NeotexTokenizer) {
+// Returns the parsed width (overrides when !TWxx/yy is present) and the tokenizer,
+// or an error when a line has no separator at the expected column.
+func NewNeotexTokenizer(data []byte, width int) (int, *NeotexTokenizer, error) {
 	return neotex.NewNeotexTokenizer(data, width)
 }
 
+// SplitNeotexFormat splits Neotex data into text and sequence lines, see
+// NewNeotexTokenizer for the width.
+func SplitNeotexFormat(width int, data []byte) (int, []string, []string, error) {
+	return neotex.SplitNeotexFormat(width, data)
+}
+
+// RewrapNeotex splits inline Neotex text and sequences into lines of width
+// runes, moving the sequence positions to their new line.
+func RewrapNeotex(text, sequences string, width int) (string, string, error) {
+	return neotex.RewrapNeotex(text, sequences, width)
+}
+
+// ValidateNeotex reports the malformed entries of Neotex sequence lines
+// (bad position, position beyond the text width, unknown code), with their
+// line and column. Use SplitNeotexFormat to get the lines from a file.
+func ValidateNeotex(textLines, seqLines []string) []NeotexError {
+	return neotex.ValidateNeotex(textLines, seqLines)
+}
+
+// DefaultNeotexSeparator sits between the text and the sequences of a Neotex line
+const DefaultNeotexSeparator = neotex.DefaultSeparator
+
+// NewNeotexTokenizerWithSeparator is NewNeotexTokenizer for files using
+// another separator (DefaultNeotexSeparator when empty).
+func NewNeotexTokenizerWithSeparator(data []byte, width int, separator string) (int, *NeotexTokenizer, error) {
+	return neotex.NewNeotexTokenizerWithSeparator(data, width, separator)
+}
+
+// NewPCBoardTokenizer creates a new tokenizer for PCBoard @X color code data.
+// The input should be UTF-8 encoded (use ConvertToUTF8 if needed).
+func NewPCBoardTokenizer(data []byte) *PCBoardTokenizer {
+	return pcboard.NewPCBoardTokenizer(data)
+}
+
+// NewPipeCodeTokenizer creates a new tokenizer for Renegade/Celerity pipe code data.
+// The input should be UTF-8 encoded (use ConvertToUTF8 if needed).
+func NewPipeCodeTokenizer(data []byte) *PipeCodeTokenizer {
+	return pipecode.NewPipeCodeTokenizer(data)
+}
+
+// NewTokensJSONTokenizer creates a tokenizer from the JSON output of the json exporter.
+func NewTokensJSONTokenizer(r io.Reader) (*TokensJSONTokenizer, error) {
+	return tokensjson.NewTokensJSONTokenizer(r)
+}
+
+// ImportTokensJSON decodes tokens and stats from the JSON output of the json exporter.
+// This lets tokens be stored once and re-exported later without re-parsing.
+func ImportTokensJSON(r io.Reader) ([]Token, TokenStats, error) {
+	return tokensjson.ImportTokensJSON(r)
+}
+
 // NewVirtualTerminal creates a new virtual terminal with the specified dimensions.
-// outputEncoding specifies the output encoding ("utf8", "cp437", "cp850", "iso-8859-1").
+// outputEncoding specifies the output encoding ("utf8", "cp437", "cp850", "cp866", "iso-8859-1", "windows-1252").
 // useVGAColors enables true VGA colors (not affected by terminal themes).
 func NewVirtualTerminal(width, height int, outputEncoding string, useVGAColors bool) *VirtualTerminal {
 	return processor.NewVirtualTerminal(width, height, outputEncoding, useVGAColors)
 }
 
+// StripBlink clears blink from every cell of vt, replaced by nothing, bold or
+// a bright background according to mode.
+func StripBlink(vt *VirtualTerminal, mode BlinkMode) {
+	processor.StripBlink(vt, mode)
+}
+
+// Reflow renders tokens at fromWidth and rewraps the lines at toWidth with
+// their styles. It is best-effort, for art relying on wraps rather than
+// cursor positioning.
+func Reflow(tokens []Token, fromWidth, toWidth, height int) (*VirtualTerminal, error) {
+	return processor.Reflow(tokens, fromWidth, toWidth, height)
+}
+
+// EstimateHeight returns the number of lines needed to render tokens at the
+// given width, following line feeds, wraps, tabs (every tabWidth columns, 8
+// when less than 1) and vertical cursor moves.
+func EstimateHeight(tokens []Token, width, tabWidth int) int {
+	return processor.EstimateHeight(tokens, width, tabWidth)
+}
+
+// NewVirtualTerminalAuto creates a virtual terminal sized to fit tokens.
+// The height is estimated from line feeds, wraps and vertical cursor moves.
+func NewVirtualTerminalAuto(tokens []Token, width int, outputEncoding string, useVGAColors bool) *VirtualTerminal {
+	return processor.NewVirtualTerminalAuto(tokens, width, outputEncoding, useVGAColors)
+}
+
+// FrameOnClearScreen starts a new animation frame on each erase display (CSI 2J).
+func FrameOnClearScreen(token Token, index int) bool {
+	return processor.FrameOnClearScreen(token, index)
+}
+
+// FrameEveryNTokens starts a new animation frame every n tokens.
+func FrameEveryNTokens(n int) FrameBoundary {
+	return processor.FrameEveryNTokens(n)
+}
+
 // NewSGR creates a new SGR with default values.
 func NewSGR() *SGR {
 	return types.NewSGR()
 }
 
+// NewSGRWithDefaults creates an SGR with no attributes and the given colors.
+func NewSGRWithDefaults(fg, bg ColorValue) *SGR {
+	return types.NewSGRWithDefaults(fg, bg)
+}
+
+// SetDefaultColors changes the colors of NewSGR and of a reset (SGR 0),
+// light gray on black by default, e.g. for a white-background theme.
+func SetDefaultColors(fg, bg ColorValue) {
+	types.SetDefaultColors(fg, bg)
+}
+
+// ResolveStyles returns one StyledRun per text token with the SGR style in effect.
+// This is lighter than a virtual terminal: cursor moves and width are ignored.
+func ResolveStyles(tokens []Token) []StyledRun {
+	return types.ResolveStyles(tokens)
+}
+
+// FilterTokens returns the tokens for which keep returns true, in order.
+func FilterTokens(tokens []Token, keep func(Token) bool) []Token {
+	return types.FilterTokens(tokens, keep)
+}
+
+// KeepVisible is a FilterTokens predicate keeping text, SGR and CR/LF.
+func KeepVisible(token Token) bool {
+	return types.KeepVisible(token)
+}
+
+// DropControl is a FilterTokens predicate dropping cursor and terminal
+// control sequences (CSI, C1, DCS, escapes).
+func DropControl(token Token) bool {
+	return types.DropControl(token)
+}
+
+// DedupSGR removes the SGR tokens that do not change the running style,
+// keeping the first of each run of identical styles.
+func DedupSGR(tokens []Token) []Token {
+	return types.DedupSGR(tokens)
+}
+
+// DropCursorVisibility is a FilterTokens predicate dropping the cursor
+// show/hide sequences (CSI ? 25 h/l).
+func DropCursorVisibility(token Token) bool {
+	return types.DropCursorVisibility(token)
+}
+
+// ExportPassthroughANSI rebuilds ANSI from the raw tokens, without a virtual
+// terminal. Use FilterTokens(tokens, KeepVisible) for a de-animated stream.
+func ExportPassthroughANSI(tokens []Token) (string, error) {
+	return exporter.ExportPassthroughANSI(tokens)
+}
+
 // ExportFlattenedANSI exports tokens to a flattened ANSI string.
 // This processes tokens through a virtual terminal to resolve cursor positioning
 // and produces clean ANSI output.
@@ -224,12 +677,54 @@ func ExportFlattenedANSIInline(width, nblines int, tokens []Token, outputEncodin
 	return exporter.ExportFlattenedANSIInline(width, nblines, tokens, outputEncoding, useVGAColors)
 }
 
+// ExportFlattenedANSIWithOptions exports tokens to a flattened ANSI string.
+// opts.LegacyMode (true in DefaultANSIOptions) keeps the reset + rebuild
+// encoding for ANSI 1990 viewers; false uses the 22/23/24... off codes.
+func ExportFlattenedANSIWithOptions(width, nblines int, tokens []Token, outputEncoding string, opts ANSIOptions) (string, error) {
+	return exporter.ExportFlattenedANSIWithOptions(width, nblines, tokens, outputEncoding, opts)
+}
+
+// DefaultANSIOptions returns the options used by ExportFlattenedANSI.
+func DefaultANSIOptions() ANSIOptions {
+	return exporter.DefaultANSIOptions()
+}
+
+// ExportFlattenedANSIICE exports tokens to a flattened ANSI string in iCE colors
+// mode, where blink (SGR 5) is rendered as a bright background (100-107).
+func ExportFlattenedANSIICE(width, nblines int, tokens []Token, outputEncoding string, useVGAColors bool, inline bool) (string, error) {
+	return exporter.ExportFlattenedANSIICE(width, nblines, tokens, outputEncoding, useVGAColors, inline)
+}
+
 // ExportFlattenedText exports tokens to plain text without ANSI codes.
 // This processes tokens through a virtual terminal and outputs only the text content.
 func ExportFlattenedText(width, nblines int, tokens []Token, outputEncoding string) (string, error) {
 	return exporter.ExportFlattenedText(width, nblines, tokens, outputEncoding)
 }
 
+// ExportFlattenedTextTrimmed exports tokens to plain text with trailing spaces
+// removed from each line, which keeps the output friendly to diff and grep.
+func ExportFlattenedTextTrimmed(width, nblines int, tokens []Token, outputEncoding string) (string, error) {
+	return exporter.ExportFlattenedTextTrimmed(width, nblines, tokens, outputEncoding)
+}
+
+// ExportFlattenedTextWithOptions exports tokens to plain text with a custom
+// line ending (e.g. "\r\n") and optional trailing newline.
+func ExportFlattenedTextWithOptions(width, nblines int, tokens []Token, outputEncoding string, opts PlainTextOptions) (string, error) {
+	return exporter.ExportFlattenedTextWithOptions(width, nblines, tokens, outputEncoding, opts)
+}
+
+// ExportFlattenedLayout exports tokens to plain text with the cursor moves
+// resolved and styles stripped. Every line is width columns wide, blanks
+// included, so the spacing of overlays and diagrams is kept.
+func ExportFlattenedLayout(width, nblines int, tokens []Token, outputEncoding string) (string, error) {
+	return exporter.ExportFlattenedLayout(width, nblines, tokens, outputEncoding)
+}
+
+// DefaultPlainTextOptions returns the options used by ExportFlattenedText.
+func DefaultPlainTextOptions() PlainTextOptions {
+	return processor.DefaultPlainTextOptions()
+}
+
 // ExportFlattenedTextInline exports tokens to plain text on a single line.
 func ExportFlattenedTextInline(width, nblines int, tokens []Token, outputEncoding string) (string, error) {
 	return exporter.ExportFlattenedTextInline(width, nblines, tokens, outputEncoding)
@@ -249,6 +744,148 @@ func ExportFlattenedNeotexInline(width, nblines int, tokens []Token) (string, st
 	return exporter.ExportFlattenedNeotexInline(width, nblines, tokens)
 }
 
+// VerifyRoundTrip checks that flattening UTF-8 ANSI input does not change its
+// visuals: the input and its flattened ANSI export are both rendered through a
+// VirtualTerminal and compared cell by cell. When they differ, the returned
+// string describes the first mismatching cell.
+func VerifyRoundTrip(original []byte, width int) (bool, string, error) {
+	tokens := NewANSITokenizer(original).Tokenize()
+	vt := NewVirtualTerminalAuto(tokens, width, "utf8", false)
+	if err := vt.ApplyTokens(tokens); err != nil {
+		return false, "", fmt.Errorf("error applying original tokens: %w", err)
+	}
+
+	flattened := vt.ExportFlattenedANSI()
+
+	roundTokens := NewANSITokenizer([]byte(flattened)).Tokenize()
+	roundVT := NewVirtualTerminalAuto(roundTokens, width, "utf8", false)
+	if err := roundVT.ApplyTokens(roundTokens); err != nil {
+		return false, "", fmt.Errorf("error applying flattened tokens: %w", err)
+	}
+
+	same, diff := vt.Compare(roundVT)
+	return same, diff, nil
+}
+
+// DiffBuffers lists every differing cell between two virtual terminals.
+func DiffBuffers(a, b *VirtualTerminal) []CellDiff {
+	return processor.DiffBuffers(a, b)
+}
+
+// DiffANSI renders two UTF-8 ANSI inputs on width x height virtual terminals
+// and lists every differing cell, to check that a conversion kept the visuals.
+func DiffANSI(left, right []byte, width, height int) ([]CellDiff, error) {
+	leftTokens := NewANSITokenizer(left).Tokenize()
+	leftVT := NewVirtualTerminal(width, height, "utf8", false)
+	if err := leftVT.ApplyTokens(leftTokens); err != nil {
+		return nil, fmt.Errorf("error applying left tokens: %w", err)
+	}
+
+	rightTokens := NewANSITokenizer(right).Tokenize()
+	rightVT := NewVirtualTerminal(width, height, "utf8", false)
+	if err := rightVT.ApplyTokens(rightTokens); err != nil {
+		return nil, fmt.Errorf("error applying right tokens: %w", err)
+	}
+
+	return processor.DiffBuffers(leftVT, rightVT), nil
+}
+
+// StatsJSON returns the tokenizer statistics as JSON, with the most used
+// SGR, CSI, C0 and C1 codes sorted by count and their names resolved.
+func StatsJSON(tok TokenizerWithStats) ([]byte, error) {
+	return exporter.StatsJSON(tok)
+}
+
+// DominantColors returns the n most displayed colors (bold and reverse
+// applied) of the stats, named after the VGA palette or by hex value.
+func DominantColors(stats TokenStats, n int) []StatEntry {
+	return exporter.DominantColors(stats, n)
+}
+
+// ExportMarkdown exports a virtual terminal buffer as a Markdown ```ansi fenced block.
+func ExportMarkdown(vt *VirtualTerminal) (string, error) {
+	return exporter.ExportMarkdown(vt)
+}
+
+// ExportMarkdownPlain exports a virtual terminal buffer as a Markdown ```text fenced block.
+func ExportMarkdownPlain(vt *VirtualTerminal) (string, error) {
+	return exporter.ExportMarkdownPlain(vt)
+}
+
+// ExportBBCode exports a virtual terminal buffer to forum BBCode in a
+// [font=monospace] block.
+func ExportBBCode(vt *VirtualTerminal) (string, error) {
+	return exporter.ExportBBCode(vt)
+}
+
+// ExportBBCodeWithOptions is ExportBBCode with explicit options, e.g. another
+// wrapping tag or none.
+func ExportBBCodeWithOptions(vt *VirtualTerminal, opts BBCodeOptions) (string, error) {
+	return exporter.ExportBBCodeWithOptions(vt, opts)
+}
+
+// DefaultBBCodeOptions returns BBCode options wrapping the output in a
+// [font=monospace] block.
+func DefaultBBCodeOptions() BBCodeOptions {
+	return exporter.DefaultBBCodeOptions()
+}
+
+// ExportHTML exports a virtual terminal buffer to a <pre> block of styled <span> runs.
+func ExportHTML(vt *VirtualTerminal) (string, error) {
+	return exporter.ExportHTML(vt)
+}
+
+// ExportHTMLWithOptions is ExportHTML with explicit options, e.g. the window
+// title in a <title>.
+func ExportHTMLWithOptions(vt *VirtualTerminal, opts HTMLOptions) (string, error) {
+	return exporter.ExportHTMLWithOptions(vt, opts)
+}
+
+// ExtractTitle returns the window title set by the last OSC 0 or OSC 2
+// token, "" when there is none.
+func ExtractTitle(tokens []Token) string {
+	return types.ExtractTitle(tokens)
+}
+
+// ExportIRC exports a virtual terminal buffer to mIRC color codes.
+func ExportIRC(vt *VirtualTerminal) (string, error) {
+	return exporter.ExportIRC(vt)
+}
+
+// DefaultSVGOptions returns SVG options matching a 9x16 VGA text cell.
+func DefaultSVGOptions() SVGOptions {
+	return exporter.DefaultSVGOptions()
+}
+
+// ExportSVG exports a virtual terminal buffer to an SVG image.
+func ExportSVG(vt *VirtualTerminal, opts SVGOptions) (string, error) {
+	return exporter.ExportSVG(vt, opts)
+}
+
+// DefaultPNGOptions returns PNG options matching an 8x16 VGA text cell.
+func DefaultPNGOptions() PNGOptions {
+	return exporter.DefaultPNGOptions()
+}
+
+// ExportPNG renders a virtual terminal buffer as a PNG image, for thumbnails.
+// The built-in font is a placeholder; set opts.Font to a raw 8x16 CP437 font
+// to draw the VGA glyphs.
+func ExportPNG(vt *VirtualTerminal, w io.Writer, opts PNGOptions) error {
+	return exporter.ExportPNG(vt, w, opts)
+}
+
+// HalfBlockRGB returns the top and bottom colors of each cell of a virtual
+// terminal, reading '▀' and '▄' half-blocks as two pixels.
+func HalfBlockRGB(vt *VirtualTerminal) [][][2]ColorValue {
+	return exporter.HalfBlockRGB(vt)
+}
+
+// JoinNeotex combines the text and sequences of a Neotex export into the
+// Neotex file layout, with separator (DefaultNeotexSeparator when empty).
+func JoinNeotex(text, sequences string, width int, separator string) string {
+	return exporter.JoinNeotex(text, sequences, width, separator)
+}
+
 // SGRToNeotex converts an SGR struct to neotex format strings.
 func SGRToNeotex(sgr *SGR) []string {
 	return exporter.SGRToNeotex(sgr)
@@ -1,6 +1,9 @@
 package splitans
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestNormalizeANSIUTF8Input_RemovesCarriageReturn(t *testing.T) {
 	input := []byte("foo\r\nbar\r\nbaz")
@@ -30,3 +33,313 @@ func TestNormalizeANSIUTF8Input_PreservesUnicode(t *testing.T) {
 		t.Fatalf("expected %q, got %q", want, got)
 	}
 }
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []byte
+		expected string
+	}{
+		{"ASCII", []byte("hello"), "utf8"},
+		{"UTF-8", []byte("café █▓▒░"), "utf8"},
+		{"UTF-8 BOM", []byte("\xef\xbb\xbfhello"), "utf8"},
+		{"CP437 blocks", []byte("\xdb\xdb\xb2\xb1\xb0 \xdc\xdf"), "cp437"},
+		{"UTF-8 Cyrillic", []byte("привет"), "utf8"},
+		{"UTF-8 Hebrew", []byte("שלום עולם"), "utf8"},
+		{"UTF-8 Arabic", []byte("مرحبا بالعالم"), "utf8"},
+		{"CP437 box drawing", []byte("\xc9\xcd\xcd\xbb\n\xba  \xba\n\xc8\xcd\xcd\xbc"), "cp437"},
+		{"CP437 lines mixed with text", []byte("\xd5\xcd\xb8 Title \xd4\xcd\xbe"), "cp437"},
+		{"CP850 accented words", []byte("VOIL\xb7 \xd4TAIT D\xd2J\xb7"), "cp850"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectEncoding(tt.input); got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestConvertToUTF8Auto(t *testing.T) {
+	got, enc, err := ConvertToUTF8Auto([]byte("\xdb\xb2\xb1\xb0"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if enc != "cp437" {
+		t.Fatalf("expected cp437, got %q", enc)
+	}
+
+	if string(got) != "█▓▒░" {
+		t.Fatalf("expected %q, got %q", "█▓▒░", got)
+	}
+}
+
+func TestConvertToUTF8AutoKeepsHebrew(t *testing.T) {
+	got, enc, err := ConvertToUTF8Auto([]byte("שלום"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if enc != "utf8" || string(got) != "שלום" {
+		t.Fatalf("expected utf8 %q, got %s %q", "שלום", enc, got)
+	}
+}
+
+func TestAnalyzeFile(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      string
+		fullParse bool
+	}{
+		{"clean", "\x1b[1;31m\xdb\xdb\x1b[0m ok\r\n", true},
+		{"interrupted CSI", "ok\x1b[31\nmore text after the bad sequence", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats, err := AnalyzeFile([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if stats.IsFullyParsed() != tt.fullParse {
+				t.Fatalf("expected fully parsed %v, got %v (%.1f%%)", tt.fullParse, stats.IsFullyParsed(), stats.ParsedPercent)
+			}
+			if tt.fullParse && stats.ParsedPercent != 100 {
+				t.Fatalf("expected 100%%, got %.1f%%", stats.ParsedPercent)
+			}
+			if !tt.fullParse && (stats.ParsedPercent >= 100 || stats.PosFirstBadSequence == 0) {
+				t.Fatalf("expected a partial parse, got %.1f%% (first bad sequence at %d)", stats.ParsedPercent, stats.PosFirstBadSequence)
+			}
+		})
+	}
+}
+
+func TestStripToText(t *testing.T) {
+	data := "\x1b[1;31mHello\x1b[0m\r\n\x1b[2J\x1b[5;10Hworld\n\x1b]0;title\x07end\rover\xdb"
+
+	got, err := StripToText([]byte(data), "cp437")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if want := "Hello\nworld\nend\nover█"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+
+	if _, err := StripToText([]byte(data), "ebcdic"); err == nil {
+		t.Fatalf("expected an error for an unknown encoding")
+	}
+
+	// An interrupted CSI ends the text unless the tokenizer recovers
+	interrupted := []byte("ab\x1b[1\rcd\x7fe")
+	if got, _ := StripToText(interrupted, "utf8"); got != "ab" {
+		t.Fatalf("expected the text to stop at the interrupted CSI, got %q", got)
+	}
+	got, err = StripToTextWithOptions(interrupted, "utf8", StripOptions{RecoverMode: true, DeleteAsControl: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "ab\ncde"; got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestConvertToUTF8CodePages(t *testing.T) {
+	tests := []struct {
+		encoding string
+		input    []byte
+		expected string
+	}{
+		{"cp437", []byte{0x80, 0xE0}, "Çα"},
+		{"cp866", []byte{0x80, 0xE0}, "Ар"},
+		{"windows-1252", []byte{0x80, 0x93, 0x94}, "€“”"},
+		{"iso-8859-1", []byte{0xE9}, "é"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.encoding, func(t *testing.T) {
+			got, err := ConvertToUTF8(tt.input, tt.encoding)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tt.expected {
+				t.Fatalf("expected %q, got %q", tt.expected, got)
+			}
+
+			back, err := ConvertToEncoding(got, tt.encoding)
+			if err != nil {
+				t.Fatalf("unexpected encoding error: %v", err)
+			}
+			if string(back) != string(tt.input) {
+				t.Fatalf("expected round trip %v, got %v", tt.input, back)
+			}
+		})
+	}
+}
+
+func TestConvertToEncodingLossy(t *testing.T) {
+	input := []byte("█ a—b ▒")
+
+	if _, err := ConvertToEncoding(input, "cp437"); err == nil {
+		t.Fatalf("expected the strict conversion to fail on the em-dash")
+	}
+
+	got, err := ConvertToEncodingLossy(input, "cp437", '-')
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if expected := []byte{0xDB, ' ', 'a', '-', 'b', ' ', 0xB1}; string(got) != string(expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	if _, err := ConvertToEncodingLossy(input, "cp437", '—'); err == nil {
+		t.Fatalf("expected an error for a replacement missing from cp437")
+	}
+}
+
+func TestExportFlattenedANSIFromRawInput(t *testing.T) {
+	// Redundant SGR and cursor forward, as produced by BBS era editors
+	raw := []byte("\x1b[0;31m\x1b[31mA\x1b[2CB\r\n\x1b[1;31m\xdb")
+
+	data, err := ConvertToUTF8(raw, "cp437")
+	if err != nil {
+		t.Fatalf("unexpected conversion error: %v", err)
+	}
+
+	tokens := NewANSITokenizer(data).Tokenize()
+	got, err := ExportFlattenedANSI(4, 2, tokens, "utf8", false)
+	if err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+
+	// Cells skipped by ESC[2C keep the default style
+	expected := "\x1b[31;40mA\x1b[0m  \x1b[31mB\n\x1b[1m█\x1b[0m   \n"
+	if got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestVerifyRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		art  string
+	}{
+		{"styles and moves", "\x1b[1;31mHello\x1b[0m \x1b[44mWorld\r\n\x1b[5;32m█▓\x1b[2;8H\x1b[33m░\x1b[0m"},
+		// Written back as 1;33, which looks the same
+		{"bright foreground", "\x1b[93mA"},
+		// Written back as explicit default colors
+		{"default colors", "\x1b[31mA\x1b[39mB\x1b[44mC\x1b[49mD"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			same, diff, err := VerifyRoundTrip([]byte(tt.art), 10)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !same {
+				t.Fatalf("expected a clean round-trip, got %s", diff)
+			}
+		})
+	}
+}
+
+func TestVerifyRoundTripReportsMismatch(t *testing.T) {
+	// ESC[2D moves back on the wide char and ESC[X erases its first half
+	// only, the flattened export writes the orphan second half as a space
+	art := []byte("漢\x1b[2D\x1b[X")
+
+	same, diff, err := VerifyRoundTrip(art, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if same {
+		t.Fatalf("expected a mismatch")
+	}
+	if !strings.HasPrefix(diff, "line 1, column 2: ") {
+		t.Fatalf("unexpected diff: %s", diff)
+	}
+}
+
+func TestDiffANSI(t *testing.T) {
+	left := []byte("\x1b[31mred\x1b[0m text")
+
+	// Same visuals, different sequences
+	diffs, err := DiffANSI(left, []byte("\x1b[31mr\x1b[31med\x1b[m\x1b[Ctext"), 20, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(diffs) != 0 {
+		t.Fatalf("expected no diff for identical buffers, got %v", diffs)
+	}
+
+	diffs, err = DiffANSI(left, []byte("\x1b[31mr\x1b[32me\x1b[31md\x1b[0m text"), 20, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(diffs) != 1 {
+		t.Fatalf("expected 1 diff, got %d: %v", len(diffs), diffs)
+	}
+
+	d := diffs[0]
+	if d.X != 1 || d.Y != 0 || d.AChar != 'e' || d.BChar != 'e' {
+		t.Fatalf("unexpected diff position or chars: %+v", d)
+	}
+	if d.ASGR.FgColor.Index != 1 || d.BSGR.FgColor.Index != 2 {
+		t.Fatalf("expected red vs green, got %s vs %s", d.ASGR.FgColor.String(), d.BSGR.FgColor.String())
+	}
+}
+
+func TestTokenizeStringAndReader(t *testing.T) {
+	input := "\x1b[31mred\x1b[0m"
+
+	tokens, stats := TokenizeStringWithStats("\xef\xbb\xbf" + input)
+	if len(tokens) != 3 {
+		t.Fatalf("expected 3 tokens, got %d: %v", len(tokens), tokens)
+	}
+	if tokens[0].Type != TokenSGR || tokens[1].Type != TokenText || tokens[1].Value != "red" {
+		t.Fatalf("unexpected tokens: %v", tokens)
+	}
+	if stats.TotalTokens != 3 || stats.TokensByType[TokenSGR] != 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	if got := TokenizeString(input); len(got) != len(tokens) {
+		t.Fatalf("TokenizeString returned %d tokens, want %d", len(got), len(tokens))
+	}
+
+	fromReader, err := TokenizeReader(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fromReader) != len(tokens) || fromReader[1].Value != "red" {
+		t.Fatalf("unexpected reader tokens: %v", fromReader)
+	}
+}
+
+func TestExtractTitleNonASCII(t *testing.T) {
+	tokens := TokenizeString("\x1b]2;Über alles\x07Hi")
+
+	if got := ExtractTitle(tokens); got != "Über alles" {
+		t.Fatalf("expected %q, got %q", "Über alles", got)
+	}
+
+	vt := NewVirtualTerminal(10, 1, "utf8", false)
+	if err := vt.ApplyTokens(tokens); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+	html, err := ExportHTMLWithOptions(vt, HTMLOptions{Title: true})
+	if err != nil {
+		t.Fatalf("unexpected export error: %v", err)
+	}
+	if !strings.HasPrefix(html, "<title>Über alles</title>\n") {
+		t.Fatalf("expected the title in the HTML, got %q", html)
+	}
+	_, pre, _ := strings.Cut(html, "<pre")
+	if strings.Contains(pre, "ber") || !strings.Contains(pre, ">Hi") {
+		t.Fatalf("expected only Hi as visible text, got %q", pre)
+	}
+}
@@ -0,0 +1,87 @@
+package splitans
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/badele/splitans/internal/types"
+)
+
+// Writer builds an ANSI stream from styles and text, the inverse of the
+// tokenizer. Styles are applied lazily: only the SGR changes needed by the
+// next text are emitted, so setting the same style twice costs nothing.
+//
+//	w := splitans.NewWriter()
+//	red := splitans.NewSGR()
+//	red.FgColor = splitans.ColorValue{Type: splitans.ColorStandard, Index: 1}
+//	w.SetStyle(red)
+//	w.WriteText("hello")
+//	w.WriteTo(os.Stdout)
+type Writer struct {
+	buf bytes.Buffer
+	// Style set by SetStyle, nil until the first call
+	style *SGR
+	// Style emitted in the stream, nil until the first escape
+	current      *SGR
+	useVGAColors bool
+	legacyMode   bool
+}
+
+// NewWriter returns an empty Writer emitting legacy (ANSI 1990) SGR
+// sequences, like ExportFlattenedANSI.
+func NewWriter() *Writer {
+	return &Writer{legacyMode: true}
+}
+
+// SetLegacyMode selects how attributes are turned off, see
+// VirtualTerminal.SetLegacyMode.
+func (w *Writer) SetLegacyMode(enabled bool) {
+	w.legacyMode = enabled
+}
+
+// SetVGAColors emits standard colors as their VGA RGB values.
+func (w *Writer) SetVGAColors(enabled bool) {
+	w.useVGAColors = enabled
+}
+
+// SetStyle selects the style of the text written next. A nil style is the
+// default style.
+func (w *Writer) SetStyle(style *SGR) {
+	if style == nil {
+		style = types.NewSGR()
+	}
+	w.style = style.Copy()
+}
+
+// WriteText writes text with the current style, preceded by the SGR
+// sequence moving from the previous style when it changed.
+func (w *Writer) WriteText(text string) {
+	if w.style != nil && !w.style.Equals(w.current) {
+		w.buf.WriteString(w.style.DiffToANSI(w.current, w.useVGAColors, w.legacyMode))
+		w.current = w.style.Copy()
+	}
+	w.buf.WriteString(text)
+}
+
+// MoveTo moves the cursor to column x and row y, both starting at 0.
+func (w *Writer) MoveTo(x, y int) {
+	fmt.Fprintf(&w.buf, "\x1b[%d;%dH", max(y, 0)+1, max(x, 0)+1)
+}
+
+// Bytes returns the stream written so far, followed by a reset when the
+// last emitted style is not the default one.
+func (w *Writer) Bytes() []byte {
+	out := bytes.Clone(w.buf.Bytes())
+	if w.current != nil && !w.current.Equals(types.NewSGR()) {
+		out = append(out, "\x1b[0m"...)
+	}
+
+	return out
+}
+
+// WriteTo writes the stream returned by Bytes to dst.
+func (w *Writer) WriteTo(dst io.Writer) (int64, error) {
+	n, err := dst.Write(w.Bytes())
+	return int64(n), err
+}
@@ -0,0 +1,52 @@
+package splitans
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriterSkipsRedundantStyles(t *testing.T) {
+	red := NewSGR()
+	red.FgColor = ColorValue{Type: ColorStandard, Index: 1}
+
+	w := NewWriter()
+	w.SetStyle(red)
+	w.WriteText("ab")
+	w.SetStyle(red.Copy())
+	w.WriteText("cd")
+	w.MoveTo(2, 1)
+	w.SetStyle(nil)
+	w.WriteText("x")
+
+	expected := "\x1b[31;40mabcd\x1b[2;3H\x1b[0mx"
+	if got := string(w.Bytes()); got != expected {
+		t.Fatalf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	bold := NewSGR()
+	bold.Bold = true
+
+	w := NewWriter()
+	w.WriteText("a")
+	w.SetStyle(bold)
+	w.WriteText("b")
+
+	var out strings.Builder
+	if _, err := w.WriteTo(&out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(out.String(), "\x1b[0m") {
+		t.Fatalf("expected a final reset, got %q", out.String())
+	}
+
+	vt := NewVirtualTerminal(4, 1, "utf8", false)
+	if err := vt.ApplyTokens(TokenizeString(out.String())); err != nil {
+		t.Fatalf("unexpected apply error: %v", err)
+	}
+	cells := vt.Cells()
+	if cells[0][0].SGR.Bold || !cells[0][1].SGR.Bold || cells[0][1].Char != 'b' {
+		t.Fatalf("unexpected cells: %+v", cells[0])
+	}
+}